@@ -21,11 +21,15 @@ import (
 
 // File format constants
 const (
-	FORMAT_INI  = "ini"
-	FORMAT_YAML = "yaml"
-	FORMAT_TOML = "toml"
-	FORMAT_JSON = "json"
-	FORMAT_XML  = "xml"
+	FORMAT_INI        = "ini"
+	FORMAT_YAML       = "yaml"
+	FORMAT_TOML       = "toml"
+	FORMAT_JSON       = "json"
+	FORMAT_JSONC      = "jsonc"
+	FORMAT_XML        = "xml"
+	FORMAT_PROPERTIES = "properties"
+	FORMAT_ENV        = "env"
+	FORMAT_WHITESPACE = "whitespace"
 )
 
 // GenerateCmd generates a .cue data file from the diff between two executor targets
@@ -35,7 +39,7 @@ type GenerateCmd struct {
 	Name        string   `short:"n" help:"Name for the generated target"`
 	Output      string   `short:"o" help:"Output file path for the generated .cue data"`
 	Identifiers string   `help:"Target identifiers in flattened format (e.g., options.use_spacing=true,backup=false,metadata.custom=value)"`
-	FileFormat  string   `help:"File format for file targets (e.g., ini, yaml, toml, json, xml). Work only with file targets. Overrides auto-detected format."`
+	FileFormat  string   `help:"File format for file targets (e.g., ini, yaml, toml, json, jsonc, xml, properties, env, whitespace). Work only with file targets. Overrides auto-detected format."`
 	registry    *features.Registry
 }
 
@@ -246,6 +250,11 @@ func (c *GenerateCmd) detectFormat(filePath string) string {
 		return c.FileFormat
 	}
 
+	switch filepath.Base(filePath) {
+	case "sshd_config", "ssh_config":
+		return FORMAT_WHITESPACE
+	}
+
 	ext := strings.ToLower(filepath.Ext(filePath))
 	switch ext {
 	case ".ini", ".conf":
@@ -256,8 +265,14 @@ func (c *GenerateCmd) detectFormat(filePath string) string {
 		return FORMAT_TOML
 	case ".json":
 		return FORMAT_JSON
+	case ".jsonc":
+		return FORMAT_JSONC
 	case ".xml":
 		return FORMAT_XML
+	case ".properties":
+		return FORMAT_PROPERTIES
+	case ".env":
+		return FORMAT_ENV
 	default:
 		return ""
 	}
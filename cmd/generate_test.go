@@ -64,7 +64,12 @@ func TestDetectFormat(t1 *testing.T) {
 		{"yml file", "config.yml", "yaml"},
 		{"toml file", "config.toml", "toml"},
 		{"json file", "config.json", "json"},
+		{"jsonc file", "settings.jsonc", "jsonc"},
 		{"xml file", "config.xml", "xml"},
+		{"properties file", "server.properties", "properties"},
+		{"dotenv file", ".env", "env"},
+		{"sshd config", "/etc/ssh/sshd_config", "whitespace"},
+		{"ssh client config", "/home/user/.ssh/ssh_config", "whitespace"},
 		{"unknown extension", "config.txt", ""},
 		{"no extension", "config", ""},
 	}
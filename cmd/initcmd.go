@@ -5,8 +5,10 @@ import (
 	"slices"
 
 	"github.com/thedataflows/confedit/internal/features"
+	"github.com/thedataflows/confedit/internal/features/block"
 	"github.com/thedataflows/confedit/internal/features/dconf"
 	"github.com/thedataflows/confedit/internal/features/file"
+	"github.com/thedataflows/confedit/internal/features/line"
 	"github.com/thedataflows/confedit/internal/features/sed"
 	"github.com/thedataflows/confedit/internal/features/systemd"
 	"github.com/thedataflows/confedit/internal/loader"
@@ -32,6 +34,8 @@ func initializeFeatureRegistry() *features.Registry {
 	registry.Register(dconf.New())
 	registry.Register(sed.New())
 	registry.Register(systemd.New())
+	registry.Register(block.New())
+	registry.Register(line.New())
 	return registry
 }
 
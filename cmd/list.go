@@ -8,8 +8,10 @@ import (
 
 	"github.com/alecthomas/kong"
 	"github.com/goccy/go-yaml"
+	"github.com/thedataflows/confedit/internal/features/block"
 	"github.com/thedataflows/confedit/internal/features/dconf"
 	"github.com/thedataflows/confedit/internal/features/file"
+	"github.com/thedataflows/confedit/internal/features/line"
 	"github.com/thedataflows/confedit/internal/features/systemd"
 	"github.com/thedataflows/confedit/internal/loader"
 	"github.com/thedataflows/confedit/internal/types"
@@ -138,6 +140,14 @@ func (c *ListCmd) getTargetDetails(target types.AnyTarget) string {
 		if systemdTarget, ok := target.(*systemd.Target); ok {
 			return fmt.Sprintf("unit=%s section=%s", systemdTarget.Config.Unit, systemdTarget.Config.Section)
 		}
+	case types.TYPE_BLOCK:
+		if blockTarget, ok := target.(*block.Target); ok {
+			return fmt.Sprintf("path=%s", blockTarget.Config.Path)
+		}
+	case types.TYPE_LINE:
+		if lineTarget, ok := target.(*line.Target); ok {
+			return fmt.Sprintf("path=%s", lineTarget.Config.Path)
+		}
 	}
 	return ""
 }
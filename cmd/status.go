@@ -4,11 +4,9 @@ import (
 	"fmt"
 
 	"github.com/alecthomas/kong"
+	"github.com/thedataflows/confedit/internal/engine"
 	"github.com/thedataflows/confedit/internal/features"
-	"github.com/thedataflows/confedit/internal/features/dconf"
 	"github.com/thedataflows/confedit/internal/features/file"
-	"github.com/thedataflows/confedit/internal/features/sed"
-	"github.com/thedataflows/confedit/internal/features/systemd"
 	"github.com/thedataflows/confedit/internal/reconciler"
 	"github.com/thedataflows/confedit/internal/state"
 	"github.com/thedataflows/confedit/internal/types"
@@ -79,30 +77,21 @@ func (c *StatusCmd) checkTargetStatus(target types.AnyTarget, rec reconciler.Rec
 
 	// Get the appropriate content based on target type
 	var targetContent map[string]interface{}
-	switch target.GetType() {
-	case types.TYPE_FILE:
-		if fileTarget, ok := target.(*file.Target); ok {
-			targetContent = fileTarget.GetConfig().Content
-		}
-	case types.TYPE_DCONF:
-		if dconfTarget, ok := target.(*dconf.Target); ok {
-			targetContent = dconfTarget.GetConfig().Settings
-		}
-	case types.TYPE_SYSTEMD:
-		if systemdTarget, ok := target.(*systemd.Target); ok {
-			targetContent = systemdTarget.GetConfig().Properties
+	if stater, ok := executor.(engine.DesiredStater); ok {
+		// Executors that know better than the declared content provide the desired state themselves
+		targetContent, err = stater.DesiredState(target)
+		if err != nil {
+			return false, fmt.Errorf("get desired state: %w", err)
 		}
-	case types.TYPE_SED:
-		if sedTarget, ok := target.(*sed.Target); ok {
-			// For sed targets, we check if the commands would result in changes
-			// The current system state contains the file content
-			targetContent = map[string]interface{}{
-				"commands": sedTarget.GetConfig().Commands,
-				"path":     sedTarget.GetConfig().Path,
+	} else {
+		switch target.GetType() {
+		case types.TYPE_FILE:
+			if fileTarget, ok := target.(*file.Target); ok {
+				targetContent = fileTarget.GetConfig().Content
 			}
+		default:
+			return false, fmt.Errorf("unsupported target type: %s", target.GetType())
 		}
-	default:
-		return false, fmt.Errorf("unsupported target type: %s", target.GetType())
 	}
 
 	// Compute diff to check for drift
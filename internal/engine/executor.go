@@ -17,3 +17,11 @@ type Executor interface {
 	// CurrentState retrieves the current state of the target
 	CurrentState(target types.AnyTarget) (map[string]interface{}, error)
 }
+
+// DesiredStater is an optional interface for executors whose desired state is not
+// simply the content declared by the target, e.g. when it includes unit lifecycle
+// state or must be computed from the current system state
+type DesiredStater interface {
+	// DesiredState returns the state the target should be in after Apply
+	DesiredState(target types.AnyTarget) (map[string]interface{}, error)
+}
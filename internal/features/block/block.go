@@ -0,0 +1,59 @@
+package block
+
+import (
+	"fmt"
+
+	"github.com/thedataflows/confedit/internal/engine"
+	"github.com/thedataflows/confedit/internal/features"
+	"github.com/thedataflows/confedit/internal/types"
+)
+
+// Feature implements the features.Feature interface for managed block targets
+type Feature struct {
+	executor engine.Executor
+}
+
+// New creates a new managed block feature
+func New() features.Feature {
+	return &Feature{
+		executor: NewExecutor(),
+	}
+}
+
+// Type returns the feature type identifier
+func (f *Feature) Type() string {
+	return types.TYPE_BLOCK
+}
+
+// Executor returns the executor implementation for this feature
+func (f *Feature) Executor() engine.Executor {
+	return f.executor
+}
+
+// NewTarget creates a new managed block target instance
+func (f *Feature) NewTarget(name string, config interface{}) (types.AnyTarget, error) {
+	blockConfig, ok := config.(*Config)
+	if !ok {
+		return nil, fmt.Errorf("invalid config type for block target, expected *block.Config")
+	}
+
+	return &Target{
+		Name:     name,
+		Type:     types.TYPE_BLOCK,
+		Metadata: make(map[string]interface{}),
+		Config:   blockConfig,
+	}, nil
+}
+
+// Validate validates the block-specific target
+func (f *Feature) Validate(config interface{}) error {
+	blockConfig, ok := config.(*Config)
+	if !ok {
+		return fmt.Errorf("invalid config type for block target, expected *block.Config")
+	}
+
+	return blockConfig.Validate()
+}
+
+// Verify that Feature implements the features.Feature interface at compile time
+var _ features.Feature = (*Feature)(nil)
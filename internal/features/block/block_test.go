@@ -0,0 +1,150 @@
+package block_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thedataflows/confedit/internal/engine"
+	"github.com/thedataflows/confedit/internal/features/block"
+	"github.com/thedataflows/confedit/internal/state"
+	"github.com/thedataflows/confedit/internal/types"
+)
+
+func TestBlockFeature_Type(t *testing.T) {
+	feature := block.New()
+	assert.Equal(t, types.TYPE_BLOCK, feature.Type())
+	assert.NotNil(t, feature.Executor())
+}
+
+func TestBlockFeature_Validate(t *testing.T) {
+	feature := block.New()
+
+	tests := []struct {
+		name    string
+		config  *block.Config
+		wantErr bool
+	}{
+		{"valid config", &block.Config{Path: "/etc/hosts", Content: "127.0.0.1 app"}, false},
+		{"absent block", &block.Config{Path: "/etc/hosts", State: block.StateAbsent}, false},
+		{"missing path", &block.Config{Content: "x"}, true},
+		{"invalid state", &block.Config{Path: "/etc/hosts", State: "gone"}, true},
+		{"invalid anchor", &block.Config{Path: "/etc/hosts", InsertAfter: "("}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(tb *testing.T) {
+			err := feature.Validate(tt.config)
+			if tt.wantErr {
+				assert.Error(tb, err)
+			} else {
+				assert.NoError(tb, err)
+			}
+		})
+	}
+
+	assert.Error(t, feature.Validate("not a config"))
+}
+
+// converge computes the drift of target, applies it and returns the diff
+func converge(t *testing.T, target *block.Target) *state.ConfigDiff {
+	t.Helper()
+	executor := block.NewExecutor()
+
+	current, err := executor.CurrentState(target)
+	require.NoError(t, err)
+	desired, err := executor.(engine.DesiredStater).DesiredState(target)
+	require.NoError(t, err)
+	diff, err := state.NewManager("").ComputeDiffWithCurrent(target.GetName(), desired, current)
+	require.NoError(t, err)
+
+	require.NoError(t, executor.Apply(target, diff))
+	return diff
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return string(content)
+}
+
+func TestBlockExecutor_Lifecycle(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sshd_config")
+	require.NoError(t, os.WriteFile(path, []byte("Port 22\nMatch User backup\n    ForceCommand internal-sftp\n"), 0600))
+
+	target := block.NewTarget("hardening", path, "PermitRootLogin no\nPasswordAuthentication no\n")
+	target.Config.InsertBefore = `^Match\s`
+
+	diff := converge(t, target)
+	assert.Contains(t, diff.Added, "block")
+	assert.Equal(t, `Port 22
+# BEGIN confedit hardening
+PermitRootLogin no
+PasswordAuthentication no
+# END confedit hardening
+Match User backup
+    ForceCommand internal-sftp
+`, readFile(t, path))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// Converged blocks report no drift
+	assert.True(t, converge(t, target).IsEmpty())
+
+	// Changing the content replaces the block in place and shows a line diff
+	target.Config.Content = "PermitRootLogin no\nPasswordAuthentication yes"
+	diff = converge(t, target)
+	assert.Contains(t, diff.FormatPlain(), "-PasswordAuthentication no")
+	assert.Contains(t, diff.FormatPlain(), "+PasswordAuthentication yes")
+	assert.Equal(t, `Port 22
+# BEGIN confedit hardening
+PermitRootLogin no
+PasswordAuthentication yes
+# END confedit hardening
+Match User backup
+    ForceCommand internal-sftp
+`, readFile(t, path))
+
+	// Absent blocks are removed with their markers
+	target.Config.State = block.StateAbsent
+	diff = converge(t, target)
+	assert.Equal(t, []string{"block"}, diff.Removed)
+	assert.Equal(t, "Port 22\nMatch User backup\n    ForceCommand internal-sftp\n", readFile(t, path))
+	assert.True(t, converge(t, target).IsEmpty())
+}
+
+func TestBlockExecutor_CreatesFileAndAnchors(t *testing.T) {
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "bashrc")
+	target := block.NewTarget("aliases", path, "alias ll='ls -l'")
+	converge(t, target)
+	assert.Equal(t, "# BEGIN confedit aliases\nalias ll='ls -l'\n# END confedit aliases\n", readFile(t, path))
+
+	hosts := filepath.Join(dir, "hosts")
+	require.NoError(t, os.WriteFile(hosts, []byte("127.0.0.1 localhost\n::1 localhost\n"), 0644))
+	target = block.NewTarget("lab", hosts, "10.0.0.1 build")
+	target.Config.InsertAfter = `^127\.`
+	target.Config.CommentPrefix = "##"
+	converge(t, target)
+	assert.Equal(t, "127.0.0.1 localhost\n## BEGIN confedit lab\n10.0.0.1 build\n## END confedit lab\n::1 localhost\n", readFile(t, hosts))
+
+	// Absent blocks in missing files need nothing
+	missing := block.NewTarget("lab", filepath.Join(dir, "missing"), "")
+	missing.Config.State = block.StateAbsent
+	assert.True(t, converge(t, missing).IsEmpty())
+	assert.NoFileExists(t, filepath.Join(dir, "missing"))
+}
+
+func TestBlockExecutor_UnterminatedBlock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts")
+	require.NoError(t, os.WriteFile(path, []byte("# BEGIN confedit lab\n10.0.0.1 build\n"), 0644))
+
+	_, err := block.NewExecutor().CurrentState(block.NewTarget("lab", path, "10.0.0.2 cache"))
+	assert.ErrorContains(t, err, "no matching")
+}
@@ -0,0 +1,183 @@
+package block
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/thedataflows/confedit/internal/engine"
+	"github.com/thedataflows/confedit/internal/state"
+	"github.com/thedataflows/confedit/internal/types"
+	"github.com/thedataflows/confedit/internal/utils"
+)
+
+// blockKey is the state key holding the body of the managed block
+const blockKey = "block"
+
+// Executor implements the engine.Executor interface for managed block targets
+type Executor struct{}
+
+// NewExecutor creates a new managed block executor
+func NewExecutor() engine.Executor {
+	return &Executor{}
+}
+
+// Apply renders or removes the managed block, leaving the rest of the file untouched
+func (e *Executor) Apply(target types.AnyTarget, diff *state.ConfigDiff) error {
+	if diff != nil && diff.IsEmpty() {
+		return nil
+	}
+
+	if err := e.Validate(target); err != nil {
+		return err
+	}
+
+	blockTarget := target.(*Target)
+	config := blockTarget.GetConfig()
+
+	original, err := os.ReadFile(config.Path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("read file %s: %w", config.Path, err)
+	}
+	if os.IsNotExist(err) && config.IsAbsent() {
+		return nil
+	}
+
+	content, err := render(blockTarget.GetName(), config, string(original))
+	if err != nil {
+		return err
+	}
+	if content == string(original) {
+		return nil
+	}
+
+	// Create backup if requested
+	if config.Backup {
+		if err := utils.CreateBackup(config.Path); err != nil {
+			return fmt.Errorf("create backup: %w", err)
+		}
+	}
+
+	if err := utils.WriteFileAtomic(config.Path, []byte(content), utils.WriteOptions{ReplaceSymlinks: config.ReplaceSymlinks}); err != nil {
+		return fmt.Errorf("write file %s: %w", config.Path, err)
+	}
+
+	return nil
+}
+
+// render returns content with the block of the named target set or removed
+func render(name string, config *Config, content string) (string, error) {
+	lines := utils.SplitLines(content)
+	begin, end := config.Markers(name)
+
+	if config.IsAbsent() {
+		if err := lines.RemoveBlock(begin, end); err != nil {
+			return "", fmt.Errorf("remove block from %s: %w", config.Path, err)
+		}
+		return lines.String(), nil
+	}
+
+	anchor := utils.LineAnchor{}
+	if config.InsertAfter != "" {
+		anchor.After = regexp.MustCompile(config.InsertAfter)
+	}
+	if config.InsertBefore != "" {
+		anchor.Before = regexp.MustCompile(config.InsertBefore)
+	}
+
+	if err := lines.SetBlock(begin, end, bodyLines(config.Content), anchor); err != nil {
+		return "", fmt.Errorf("set block in %s: %w", config.Path, err)
+	}
+	return lines.String(), nil
+}
+
+// bodyLines splits the block content into lines, ignoring a final newline
+func bodyLines(content string) []string {
+	if content == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+}
+
+// DesiredState returns the block body, or a deletion marker when the block must be absent
+func (e *Executor) DesiredState(target types.AnyTarget) (map[string]interface{}, error) {
+	if err := e.Validate(target); err != nil {
+		return nil, err
+	}
+
+	config := target.(*Target).GetConfig()
+	if config.IsAbsent() {
+		return map[string]interface{}{
+			blockKey: map[string]interface{}{"deleted": true},
+		}, nil
+	}
+
+	return map[string]interface{}{
+		blockKey: blockText(bodyLines(config.Content)),
+	}, nil
+}
+
+// Validate checks if the target is valid
+func (e *Executor) Validate(target types.AnyTarget) error {
+	if target.GetType() != types.TYPE_BLOCK {
+		return fmt.Errorf("expected block target, got %s", target.GetType())
+	}
+
+	blockTarget, ok := target.(*Target)
+	if !ok {
+		return fmt.Errorf("target is not a block target")
+	}
+
+	if blockTarget.GetConfig() == nil {
+		return fmt.Errorf("block target is missing")
+	}
+
+	return blockTarget.GetConfig().Validate()
+}
+
+// CurrentState retrieves the body of the managed block, omitted when the block is absent
+func (e *Executor) CurrentState(target types.AnyTarget) (map[string]interface{}, error) {
+	if err := e.Validate(target); err != nil {
+		return nil, err
+	}
+
+	blockTarget := target.(*Target)
+	config := blockTarget.GetConfig()
+
+	content, err := os.ReadFile(config.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string]interface{}{}, nil
+		}
+		return nil, fmt.Errorf("read file %s: %w", config.Path, err)
+	}
+
+	begin, end := config.Markers(blockTarget.GetName())
+	body, found, err := utils.SplitLines(string(content)).BlockBody(begin, end)
+	if err != nil {
+		return nil, fmt.Errorf("read block from %s: %w", config.Path, err)
+	}
+	if !found {
+		return map[string]interface{}{}, nil
+	}
+
+	return map[string]interface{}{
+		blockKey: blockText(body),
+	}, nil
+}
+
+// blockText joins block lines into newline-terminated text, which the state diff
+// renders as a unified diff when the block spans several lines
+func blockText(lines []string) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// Verify that Executor implements the engine interfaces at compile time
+var (
+	_ engine.Executor      = (*Executor)(nil)
+	_ engine.DesiredStater = (*Executor)(nil)
+)
@@ -0,0 +1,113 @@
+package block
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/thedataflows/confedit/internal/types"
+)
+
+const (
+	// StatePresent keeps the block in the file
+	StatePresent = "present"
+	// StateAbsent removes the block from the file
+	StateAbsent = "absent"
+	// DefaultCommentPrefix starts the marker lines of a block
+	DefaultCommentPrefix = "#"
+)
+
+// Config represents the configuration for a managed block target.
+// The block is delimited by "<comment> BEGIN confedit <name>" and
+// "<comment> END confedit <name>" marker lines, where name is the target name.
+type Config struct {
+	Path            string `json:"path"`
+	Content         string `json:"content,omitempty"`
+	State           string `json:"state,omitempty"`
+	CommentPrefix   string `json:"comment_prefix,omitempty"`
+	InsertAfter     string `json:"insert_after,omitempty"`
+	InsertBefore    string `json:"insert_before,omitempty"`
+	Backup          bool   `json:"backup,omitempty"`
+	ReplaceSymlinks bool   `json:"replace_symlinks,omitempty"` // Replace a symlink at Path instead of writing through it
+}
+
+// Type implements TargetConfig interface
+func (c *Config) Type() string {
+	return types.TYPE_BLOCK
+}
+
+// Validate checks if the block configuration is valid
+func (c *Config) Validate() error {
+	if c.Path == "" {
+		return fmt.Errorf("path is required for block target")
+	}
+	switch c.State {
+	case "", StatePresent, StateAbsent:
+	default:
+		return fmt.Errorf("invalid block state %q, expected %s or %s", c.State, StatePresent, StateAbsent)
+	}
+	for name, pattern := range map[string]string{"insert_after": c.InsertAfter, "insert_before": c.InsertBefore} {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid %s expression: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// IsAbsent reports whether the block must be removed
+func (c *Config) IsAbsent() bool {
+	return c.State == StateAbsent
+}
+
+// Markers returns the begin and end marker lines of the block of the named target
+func (c *Config) Markers(name string) (string, string) {
+	prefix := c.CommentPrefix
+	if prefix == "" {
+		prefix = DefaultCommentPrefix
+	}
+	return fmt.Sprintf("%s BEGIN confedit %s", prefix, name), fmt.Sprintf("%s END confedit %s", prefix, name)
+}
+
+// Target is a type alias for managed block targets
+type Target = types.BaseTarget[*Config]
+
+// NewTarget creates a new managed block target
+func NewTarget(name, path, content string) *Target {
+	return &Target{
+		Name:     name,
+		Type:     types.TYPE_BLOCK,
+		Metadata: make(map[string]interface{}),
+		Config: &Config{
+			Path:    path,
+			Content: content,
+		},
+	}
+}
+
+// MergeConfig merges block target configs, later non-empty values win
+func MergeConfig(existing, newTarget *Config) error {
+	if newTarget.Path != "" {
+		existing.Path = newTarget.Path
+	}
+	if newTarget.Content != "" {
+		existing.Content = newTarget.Content
+	}
+	if newTarget.State != "" {
+		existing.State = newTarget.State
+	}
+	if newTarget.CommentPrefix != "" {
+		existing.CommentPrefix = newTarget.CommentPrefix
+	}
+	if newTarget.InsertAfter != "" {
+		existing.InsertAfter = newTarget.InsertAfter
+	}
+	if newTarget.InsertBefore != "" {
+		existing.InsertBefore = newTarget.InsertBefore
+	}
+	if newTarget.Backup {
+		existing.Backup = true
+	}
+	if newTarget.ReplaceSymlinks {
+		existing.ReplaceSymlinks = true
+	}
+	return nil
+}
@@ -0,0 +1,235 @@
+package dconf
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/thedataflows/confedit/internal/features/file/formats/ini/iniparser"
+	"github.com/thedataflows/confedit/internal/utils"
+)
+
+const (
+	// locksKey is the state key listing the locked settings of a system database
+	locksKey = "_locks"
+	// profileKey is the state key holding the managed profile entry
+	profileKey = "_profile"
+)
+
+// keyFileLocation splits a setting key into the keyfile section and key name.
+// Keyfile sections are dconf directories without the leading and trailing slash.
+func keyFileLocation(schema, key string) (string, string) {
+	dir := strings.Trim(schema, "/")
+	if i := strings.LastIndex(key, "/"); i >= 0 {
+		dir = strings.Trim(dir+"/"+key[:i], "/")
+		key = key[i+1:]
+	}
+	return dir, key
+}
+
+// updateKeyFile renders settings into the system database keyfile at path.
+// Unmanaged keys, comments and other sections are preserved.
+// Returns true when the file content changed.
+func updateKeyFile(path, schema string, settings map[string]interface{}, opts utils.WriteOptions) (bool, error) {
+	original, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return false, fmt.Errorf("read keyfile %s: %w", path, err)
+	}
+
+	parser := iniparser.NewRelaxedINIParser()
+	parser.SetUseSpacing(false)
+
+	lines, err := parser.Parse(original)
+	if err != nil {
+		return false, fmt.Errorf("parse keyfile %s: %w", path, err)
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(settings)) {
+		section, name := keyFileLocation(schema, key)
+		if utils.IsDeletedMarker(settings[key]) {
+			if isDirectoryKey(key) {
+				lines = removeDirectory(lines, section)
+			} else {
+				lines = parser.SetValues(lines, section, name, nil)
+			}
+			continue
+		}
+
+		encoded, err := EncodeGVariant(settings[key])
+		if err != nil {
+			return false, fmt.Errorf("encode dconf key %s: %w", key, err)
+		}
+		lines = parser.SetValues(lines, section, name, []string{encoded})
+	}
+
+	var buf bytes.Buffer
+	if err := parser.Serialize(lines, &buf); err != nil {
+		return false, fmt.Errorf("serialize keyfile %s: %w", path, err)
+	}
+
+	return writeIfChanged(path, original, buf.Bytes(), opts)
+}
+
+// removeDirectory drops the keyfile sections of a dconf directory and its subdirectories,
+// along with blank lines left dangling at the end of the file
+func removeDirectory(lines []iniparser.INILine, dir string) []iniparser.INILine {
+	count := len(lines)
+	lines = slices.DeleteFunc(lines, func(line iniparser.INILine) bool {
+		section := strings.Trim(line.Section, "/")
+		return section == dir || strings.HasPrefix(section, dir+"/")
+	})
+	if len(lines) < count {
+		for len(lines) > 0 && lines[len(lines)-1].IsEmpty {
+			lines = lines[:len(lines)-1]
+		}
+	}
+	return lines
+}
+
+// readKeyFile returns the raw GVariant literals of the keyfile at path that belong to
+// schema, keyed like Config.Settings. A missing keyfile yields no values.
+func readKeyFile(path, schema string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string]string{}, nil
+		}
+		return nil, fmt.Errorf("read keyfile %s: %w", path, err)
+	}
+
+	lines, err := iniparser.NewRelaxedINIParser().Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("parse keyfile %s: %w", path, err)
+	}
+
+	dir := strings.Trim(schema, "/")
+	result := make(map[string]string)
+	for _, line := range lines {
+		if line.IsSection || line.CommentPrefix != "" || line.Key == "" {
+			continue
+		}
+		section := strings.Trim(line.Section, "/")
+		switch {
+		case section == dir:
+			result[line.Key] = line.Value
+		case strings.HasPrefix(section, dir+"/"):
+			result[relativeKey(strings.TrimPrefix(section, dir+"/"), line.Key)] = line.Value
+		}
+	}
+	return result, nil
+}
+
+// updateLockFile writes the lock file at path so that it locks exactly the given setting
+// keys, which the target owns, and removes it when there are none. Returns true when
+// the file changed.
+func updateLockFile(path, schema string, locks []string, opts utils.WriteOptions) (bool, error) {
+	original, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return false, fmt.Errorf("read lock file %s: %w", path, err)
+	}
+
+	if len(locks) == 0 {
+		if original == nil {
+			return false, nil
+		}
+		if err := os.Remove(path); err != nil {
+			return false, fmt.Errorf("remove lock file %s: %w", path, err)
+		}
+		return true, nil
+	}
+
+	var content []byte
+	for _, key := range slices.Sorted(slices.Values(locks)) {
+		content = appendLine(content, keyPath(schema, key))
+	}
+	return writeIfChanged(path, original, content, opts)
+}
+
+// readLocks returns the setting keys locked by the lock file at path, in sorted order.
+// Entries outside the schema directory are kept as absolute paths.
+func readLocks(path, schema string) ([]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("read lock file %s: %w", path, err)
+	}
+
+	entries := readLines(data)
+	for i, entry := range entries {
+		entries[i] = strings.TrimPrefix(entry, schemaDir(schema))
+	}
+	result := make([]interface{}, 0, len(entries))
+	for _, key := range slices.Sorted(slices.Values(entries)) {
+		result = append(result, key)
+	}
+	return result, nil
+}
+
+// profileEntry returns the profile line that enables a system database
+func profileEntry(database string) string {
+	return "system-db:" + database
+}
+
+// updateProfile makes sure the dconf profile at path reads the system database.
+// A new profile also gets the user database first, so users keep their own settings
+// for keys that are not locked. Returns true when the file content changed.
+func updateProfile(path, database string, opts utils.WriteOptions) (bool, error) {
+	original, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return false, fmt.Errorf("read profile %s: %w", path, err)
+	}
+
+	entry := profileEntry(database)
+	if slices.Contains(readLines(original), entry) {
+		return false, nil
+	}
+
+	content := original
+	if len(bytes.TrimSpace(content)) == 0 {
+		content = []byte("user-db:user\n")
+	}
+	return writeIfChanged(path, original, appendLine(content, entry), opts)
+}
+
+// readLines returns the trimmed, non-empty and non-comment lines of data
+func readLines(data []byte) []string {
+	var result []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		result = append(result, line)
+	}
+	return result
+}
+
+// appendLine appends line to content, terminating the previous last line if needed
+func appendLine(content []byte, line string) []byte {
+	result := slices.Clone(content)
+	if len(result) > 0 && result[len(result)-1] != '\n' {
+		result = append(result, '\n')
+	}
+	return append(result, line+"\n"...)
+}
+
+// writeIfChanged writes content to path when it differs from original.
+// Returns true when the file was written.
+func writeIfChanged(path string, original, content []byte, opts utils.WriteOptions) (bool, error) {
+	if bytes.Equal(original, content) {
+		return false, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return false, fmt.Errorf("create directory: %w", err)
+	}
+
+	if err := utils.WriteFileAtomic(path, content, opts); err != nil {
+		return false, fmt.Errorf("write %s: %w", path, err)
+	}
+
+	return true, nil
+}
@@ -1,21 +1,68 @@
 package dconf
 
 import (
+	"bytes"
 	"fmt"
+	"maps"
 	"os"
 	"os/exec"
+	"slices"
+	"strings"
 
 	"github.com/thedataflows/confedit/internal/engine"
 	"github.com/thedataflows/confedit/internal/state"
 	"github.com/thedataflows/confedit/internal/types"
+	"github.com/thedataflows/confedit/internal/utils"
+	log "github.com/thedataflows/go-lib-log"
 )
 
 // Executor implements the engine.Executor interface for dconf targets
-type Executor struct{}
+type Executor struct {
+	// runCommand runs a prepared dconf command, replaceable in tests
+	runCommand func(cmd *exec.Cmd) ([]byte, error)
+	// lookupSession resolves the session context of Config.User, replaceable in tests
+	lookupSession func(name string) (*userSession, error)
+}
 
 // NewExecutor creates a new dconf executor
 func NewExecutor() engine.Executor {
-	return &Executor{}
+	return &Executor{
+		runCommand:    runCommand,
+		lookupSession: lookupSession,
+	}
+}
+
+// runCommand runs cmd and returns its standard output
+func runCommand(cmd *exec.Cmd) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil && stderr.Len() > 0 {
+		return output, fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return output, err
+}
+
+// command prepares dconf with args to run in the session of the configured user, if any
+func (e *Executor) command(config *Config, args ...string) (*exec.Cmd, error) {
+	if config.User == "" {
+		return exec.Command("dconf", args...), nil
+	}
+
+	session, err := e.lookupSession(config.User)
+	if err != nil {
+		return nil, err
+	}
+	return session.command(args...)
+}
+
+// run runs dconf with args in the session of the configured user, if any
+func (e *Executor) run(config *Config, args ...string) ([]byte, error) {
+	cmd, err := e.command(config, args...)
+	if err != nil {
+		return nil, err
+	}
+	return e.runCommand(cmd)
 }
 
 // Apply applies the changes to dconf
@@ -42,30 +89,93 @@ func (e *Executor) Apply(target types.AnyTarget, diff *state.ConfigDiff) error {
 		return fmt.Errorf("dconf schema not specified")
 	}
 
+	if dconfTarget.GetConfig().IsSystem() {
+		return e.applySystem(dconfTarget.GetConfig())
+	}
+
 	// Apply only the changed keys from the target settings
 	settings := dconfTarget.GetConfig().Settings
-	for key := range diff.Changes {
+	changes := settings
+	if diff != nil {
+		changes = diff.Changes
+	}
+	for key := range changes {
 		value, exists := settings[key]
 		if !exists {
 			continue
 		}
 
-		cmd := exec.Command("dconf", "write",
-			fmt.Sprintf("%s/%s", schema, key),
-			fmt.Sprintf("'%v'", value))
-
-		if dconfTarget.GetConfig().User != "" {
-			cmd.Env = append(os.Environ(),
-				fmt.Sprintf("SUDO_USER=%s", dconfTarget.GetConfig().User))
+		args, err := writeArgs(schema, key, value)
+		if err != nil {
+			return err
 		}
 
-		if err := cmd.Run(); err != nil {
+		if _, err := e.run(dconfTarget.GetConfig(), args...); err != nil {
 			return fmt.Errorf("set dconf key %s: %w", key, err)
 		}
 	}
 	return nil
 }
 
+// applySystem renders the settings, locks and profile of a system database and
+// compiles the database with `dconf update` when any of those files changed
+func (e *Executor) applySystem(config *Config) error {
+	opts := utils.WriteOptions{ReplaceSymlinks: config.ReplaceSymlinks}
+	changed, err := updateKeyFile(config.KeyFilePath(), config.Schema, config.Settings, opts)
+	if err != nil {
+		return err
+	}
+
+	locksChanged, err := updateLockFile(config.LockFilePath(), config.Schema, config.Locks, opts)
+	if err != nil {
+		return err
+	}
+	changed = changed || locksChanged
+
+	if config.Profile != "" {
+		profileChanged, err := updateProfile(config.ProfilePath(), config.Database, opts)
+		if err != nil {
+			return err
+		}
+		changed = changed || profileChanged
+	}
+
+	if !changed {
+		log.Debugf("dconf-executor", "System database %s is up to date", config.Database)
+		return nil
+	}
+
+	if _, err := e.run(config, "update"); err != nil {
+		return fmt.Errorf("dconf update: %w", err)
+	}
+	return nil
+}
+
+// DesiredState returns the settings of the target. System databases also declare the
+// locked keys and the profile entry, so that missing or stale locks and missing
+// profiles show as drift.
+func (e *Executor) DesiredState(target types.AnyTarget) (map[string]interface{}, error) {
+	if err := e.Validate(target); err != nil {
+		return nil, err
+	}
+
+	config := target.(*Target).GetConfig()
+	desired := make(map[string]interface{}, len(config.Settings)+2)
+	maps.Copy(desired, config.Settings)
+
+	if config.IsSystem() {
+		locks := make([]interface{}, 0, len(config.Locks))
+		for _, key := range slices.Sorted(slices.Values(config.Locks)) {
+			locks = append(locks, key)
+		}
+		desired[locksKey] = locks
+		if config.Profile != "" {
+			desired[profileKey] = profileEntry(config.Database)
+		}
+	}
+	return desired, nil
+}
+
 // Validate checks if the target is valid
 func (e *Executor) Validate(target types.AnyTarget) error {
 	if target.GetType() != types.TYPE_DCONF {
@@ -87,34 +197,124 @@ func (e *Executor) Validate(target types.AnyTarget) error {
 	return nil
 }
 
-// CurrentState retrieves the current state from dconf
+// CurrentState retrieves the current state from dconf.
+// The `dconf dump` of the schema directory is decoded into per-key values keyed like
+// Config.Settings ("key" or "subdir/key"). Values equal to their desired counterpart
+// are reported in the desired representation so that only real drift shows in the diff.
 func (e *Executor) CurrentState(target types.AnyTarget) (map[string]interface{}, error) {
 	if err := e.Validate(target); err != nil {
 		return nil, err
 	}
 
-	dconfTarget := target.(*Target)
-	schema := dconfTarget.GetConfig().Schema
-
-	// Get current dconf values for the schema
-	cmd := exec.Command("dconf", "dump", schema)
+	config := target.(*Target).GetConfig()
+	if config.IsSystem() {
+		return currentSystemState(config)
+	}
 
-	if dconfTarget.GetConfig().User != "" {
-		cmd.Env = append(os.Environ(),
-			fmt.Sprintf("SUDO_USER=%s", dconfTarget.GetConfig().User))
+	// Get current dconf values for the schema directory
+	cmd, err := e.command(config, "dump", schemaDir(config.Schema))
+	if err != nil {
+		return nil, err
 	}
 
-	output, err := cmd.Output()
+	output, err := e.runCommand(cmd)
 	if err != nil {
+		log.Debugf("dconf-executor", "Cannot dump %s: %v", schemaDir(config.Schema), err)
 		return make(map[string]interface{}), nil // Return empty if can't read
 	}
 
-	// Simple implementation - store raw output
-	result := make(map[string]interface{})
-	result["_raw"] = string(output)
+	return decodeDump(output, config.Settings)
+}
+
+// currentSystemState reads the settings of the managed keyfile of a system database,
+// together with the managed locks and profile entry
+func currentSystemState(config *Config) (map[string]interface{}, error) {
+	raw, err := readKeyFile(config.KeyFilePath(), config.Schema)
+	if err != nil {
+		return nil, err
+	}
+	result := decodeValues(raw, config.Settings)
+
+	locks, err := readLocks(config.LockFilePath(), config.Schema)
+	if err != nil {
+		return nil, err
+	}
+	result[locksKey] = locks
+
+	if config.Profile != "" {
+		data, err := os.ReadFile(config.ProfilePath())
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("read profile %s: %w", config.ProfilePath(), err)
+		}
+		if entry := profileEntry(config.Database); slices.Contains(readLines(data), entry) {
+			result[profileKey] = entry
+		}
+	}
 
 	return result, nil
 }
 
-// Verify that Executor implements the engine.Executor interface at compile time
-var _ engine.Executor = (*Executor)(nil)
+// decodeDump decodes `dconf dump` output, normalizing values against the desired settings
+func decodeDump(output []byte, settings map[string]interface{}) (map[string]interface{}, error) {
+	raw, err := parseDump(output)
+	if err != nil {
+		return nil, fmt.Errorf("parse dconf dump: %w", err)
+	}
+	return decodeValues(raw, settings), nil
+}
+
+// decodeValues decodes raw GVariant literals, normalizing values against the desired settings.
+// Literals that cannot be decoded are kept as text.
+func decodeValues(raw map[string]string, settings map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(raw))
+	for key, literal := range raw {
+		value, err := ParseGVariant(literal)
+		if err != nil {
+			log.Debugf("dconf-executor", "Keeping undecodable value of %s as text: %v", key, err)
+			result[key] = literal
+			continue
+		}
+
+		if desired, ok := settings[key]; ok {
+			value = normalizeSetting(desired, value)
+		}
+		result[key] = value
+	}
+
+	markDirectories(result, settings)
+	return result
+}
+
+// writeArgs returns the dconf arguments that converge key to value: `write` for values,
+// `reset` for keys marked {deleted: true} and `reset -f` for deleted directories
+func writeArgs(schema, key string, value interface{}) ([]string, error) {
+	path := keyPath(schema, key)
+	if utils.IsDeletedMarker(value) {
+		if isDirectoryKey(key) {
+			return []string{"reset", "-f", path}, nil
+		}
+		return []string{"reset", path}, nil
+	}
+
+	encoded, err := EncodeGVariant(value)
+	if err != nil {
+		return nil, fmt.Errorf("encode dconf key %s: %w", key, err)
+	}
+	return []string{"write", path, encoded}, nil
+}
+
+// keyPath returns the absolute dconf path of a setting key or directory of the schema
+func keyPath(schema, key string) string {
+	return schemaDir(schema) + strings.TrimPrefix(key, "/")
+}
+
+// schemaDir returns the dconf directory path of a schema, which always ends with a slash
+func schemaDir(schema string) string {
+	return strings.TrimSuffix(schema, "/") + "/"
+}
+
+// Verify that Executor implements the engine interfaces at compile time
+var (
+	_ engine.Executor      = (*Executor)(nil)
+	_ engine.DesiredStater = (*Executor)(nil)
+)
@@ -0,0 +1,277 @@
+package dconf
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thedataflows/confedit/internal/state"
+	"github.com/thedataflows/confedit/internal/types"
+)
+
+// fakeDconf records dconf invocations and returns canned dump output
+type fakeDconf struct {
+	calls    [][]string
+	commands []*exec.Cmd
+	dump     string
+}
+
+func (f *fakeDconf) run(cmd *exec.Cmd) ([]byte, error) {
+	f.calls = append(f.calls, cmd.Args)
+	f.commands = append(f.commands, cmd)
+	if slices.Contains(cmd.Args, "dump") {
+		return []byte(f.dump), nil
+	}
+	return nil, nil
+}
+
+func newTestExecutor() (*Executor, *fakeDconf) {
+	fake := &fakeDconf{}
+	return &Executor{runCommand: fake.run, lookupSession: lookupSession}, fake
+}
+
+func newSystemTarget(dir string) *Target {
+	target := NewTarget("gnome-defaults", "/org/gnome/desktop/")
+	target.Config.Database = "local"
+	target.Config.ConfigDir = dir
+	target.Config.Settings = map[string]interface{}{
+		"interface/gtk-theme":                  "Adwaita-dark",
+		"interface/clock-show-seconds":         true,
+		"input-sources/sources":                map[string]interface{}{"value": []interface{}{[]interface{}{"xkb", "us"}}, "type": "a(ss)"},
+		"session/idle-delay":                   map[string]interface{}{"value": int64(300), "type": "u"},
+		"screensaver/lock-delay":               int64(0),
+		"wm/keybindings/switch-to-workspace-1": []interface{}{"<Super>1"},
+	}
+	target.Config.Locks = []string{"session/idle-delay", "interface/gtk-theme"}
+	target.Config.Profile = "user"
+	return target
+}
+
+func TestExecutor_ApplyChangedList(t *testing.T) {
+	target := NewTarget("shell", "/org/gnome/shell/")
+	target.Config.Settings = map[string]interface{}{
+		"favorite-apps":           []interface{}{"firefox.desktop", "org.gnome.Terminal.desktop"},
+		"disable-user-extensions": false,
+	}
+
+	executor, fake := newTestExecutor()
+	fake.dump = "[/]\nfavorite-apps=['firefox.desktop', 'org.gnome.Nautilus.desktop']\ndisable-user-extensions=false\n"
+
+	desired, err := executor.DesiredState(target)
+	require.NoError(t, err)
+	current, err := executor.CurrentState(target)
+	require.NoError(t, err)
+	diff, err := state.NewManager(t.TempDir()).ComputeDiffWithCurrent(target.GetName(), desired, current)
+	require.NoError(t, err)
+
+	fake.calls = nil
+	require.NoError(t, executor.Apply(target, diff))
+	assert.Equal(t, [][]string{
+		{"dconf", "write", "/org/gnome/shell/favorite-apps", "['firefox.desktop', 'org.gnome.Terminal.desktop']"},
+	}, fake.calls)
+}
+
+func TestConfig_SystemPaths(t *testing.T) {
+	config := &Config{Schema: "/org/gnome/", Database: "local"}
+	assert.Equal(t, "/etc/dconf/db/local.d/confedit", config.KeyFilePath())
+	assert.Equal(t, "/etc/dconf/db/local.d/locks/confedit", config.LockFilePath())
+	assert.Equal(t, "", config.ProfilePath())
+
+	config.KeyFile = "00-desktop"
+	config.Profile = "user"
+	config.ConfigDir = "/tmp/dconf"
+	assert.Equal(t, "/tmp/dconf/db/local.d/00-desktop", config.KeyFilePath())
+	assert.Equal(t, "/tmp/dconf/db/local.d/locks/00-desktop", config.LockFilePath())
+	assert.Equal(t, "/tmp/dconf/profile/user", config.ProfilePath())
+
+	assert.NoError(t, config.Validate())
+
+	for _, invalid := range []*Config{
+		{Schema: "/org/gnome/", Locks: []string{"key"}},
+		{Schema: "/org/gnome/", Database: "local", User: "alice"},
+		{Schema: "/org/gnome/", Database: "../local"},
+		{Schema: "/org/gnome/", Database: "local", Profile: "a/b"},
+	} {
+		assert.Error(t, invalid.Validate(), "%+v", invalid)
+	}
+}
+
+func TestExecutor_ApplySystemDatabase(t *testing.T) {
+	dir := t.TempDir()
+	target := newSystemTarget(dir)
+	config := target.GetConfig()
+
+	// An administrator-maintained key in the managed section must survive
+	require.NoError(t, os.MkdirAll(filepath.Dir(config.KeyFilePath()), 0755))
+	require.NoError(t, os.WriteFile(config.KeyFilePath(),
+		[]byte("# Site defaults\n[org/gnome/desktop/interface]\nfont-name='Cantarell 11'\ngtk-theme='Adwaita'\n"), 0644))
+
+	executor, fake := newTestExecutor()
+
+	require.NoError(t, executor.Apply(target, nil))
+	assert.Equal(t, [][]string{{"dconf", "update"}}, fake.calls)
+
+	keyfile, err := os.ReadFile(config.KeyFilePath())
+	require.NoError(t, err)
+	assert.Equal(t, `# Site defaults
+[org/gnome/desktop/interface]
+font-name='Cantarell 11'
+gtk-theme='Adwaita-dark'
+clock-show-seconds=true
+
+[org/gnome/desktop/input-sources]
+sources=[('xkb', 'us')]
+
+[org/gnome/desktop/screensaver]
+lock-delay=0
+
+[org/gnome/desktop/session]
+idle-delay=uint32 300
+
+[org/gnome/desktop/wm/keybindings]
+switch-to-workspace-1=['<Super>1']
+`, string(keyfile))
+
+	locks, err := os.ReadFile(config.LockFilePath())
+	require.NoError(t, err)
+	assert.Equal(t, "/org/gnome/desktop/interface/gtk-theme\n/org/gnome/desktop/session/idle-delay\n", string(locks))
+
+	profile, err := os.ReadFile(config.ProfilePath())
+	require.NoError(t, err)
+	assert.Equal(t, "user-db:user\nsystem-db:local\n", string(profile))
+
+	// The applied database matches the desired state and is not rebuilt again
+	desired, err := executor.DesiredState(target)
+	require.NoError(t, err)
+	current, err := executor.CurrentState(target)
+	require.NoError(t, err)
+	diff, err := state.NewManager(dir).ComputeDiffWithCurrent(target.GetName(), desired, current)
+	require.NoError(t, err)
+	assert.True(t, diff.IsEmpty(), "unexpected diff: %+v", diff.Changes)
+
+	require.NoError(t, executor.Apply(target, nil))
+	assert.Len(t, fake.calls, 1, "dconf update must only run when a file changed")
+}
+
+func TestExecutor_SystemDatabaseDrift(t *testing.T) {
+	dir := t.TempDir()
+	target := newSystemTarget(dir)
+	config := target.GetConfig()
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "profile"), 0755))
+	require.NoError(t, os.WriteFile(config.ProfilePath(), []byte("user-db:user\nsystem-db:site\n"), 0644))
+
+	executor, _ := newTestExecutor()
+	current, err := executor.CurrentState(target)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{}, current[locksKey])
+	assert.NotContains(t, current, profileKey)
+	assert.NotContains(t, current, "interface/gtk-theme")
+
+	require.NoError(t, executor.Apply(target, nil))
+
+	// Existing profile databases are kept, the managed one is appended
+	profile, err := os.ReadFile(config.ProfilePath())
+	require.NoError(t, err)
+	assert.Equal(t, "user-db:user\nsystem-db:site\nsystem-db:local\n", string(profile))
+
+	current, err = executor.CurrentState(target)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"interface/gtk-theme", "session/idle-delay"}, current[locksKey])
+	assert.Equal(t, "system-db:local", current[profileKey])
+	assert.Equal(t, config.Settings["session/idle-delay"], current["session/idle-delay"])
+}
+
+func TestExecutor_SystemDatabaseRemovedLocks(t *testing.T) {
+	dir := t.TempDir()
+	target := newSystemTarget(dir)
+	config := target.GetConfig()
+
+	executor, _ := newTestExecutor()
+	require.NoError(t, executor.Apply(target, nil))
+
+	drift := func() *state.ConfigDiff {
+		t.Helper()
+		desired, err := executor.DesiredState(target)
+		require.NoError(t, err)
+		current, err := executor.CurrentState(target)
+		require.NoError(t, err)
+		diff, err := state.NewManager(dir).ComputeDiffWithCurrent(target.GetName(), desired, current)
+		require.NoError(t, err)
+		return diff
+	}
+
+	// A lock dropped from the config unlocks the key again
+	config.Locks = []string{"session/idle-delay"}
+	assert.Contains(t, drift().Modified, locksKey)
+	require.NoError(t, executor.Apply(target, nil))
+	locks, err := os.ReadFile(config.LockFilePath())
+	require.NoError(t, err)
+	assert.Equal(t, "/org/gnome/desktop/session/idle-delay\n", string(locks))
+	assert.True(t, drift().IsEmpty())
+
+	config.Locks = nil
+	require.NoError(t, executor.Apply(target, nil))
+	assert.NoFileExists(t, config.LockFilePath())
+	assert.True(t, drift().IsEmpty())
+}
+
+func TestExecutor_SystemDatabaseTargetType(t *testing.T) {
+	target := newSystemTarget(t.TempDir())
+	assert.Equal(t, types.TYPE_DCONF, target.GetType())
+	assert.True(t, target.GetConfig().IsSystem())
+	assert.False(t, NewTarget("user", "/org/gnome/").GetConfig().IsSystem())
+}
+
+func TestExecutor_SystemDatabaseDeleted(t *testing.T) {
+	dir := t.TempDir()
+	target := NewTarget("gnome-defaults", "/org/gnome/desktop/")
+	config := target.GetConfig()
+	config.Database = "local"
+	config.ConfigDir = dir
+	config.Settings = map[string]interface{}{
+		"interface/gtk-theme":   map[string]interface{}{"deleted": true},
+		"interface/font-name":   "Cantarell 11",
+		"extensions/old-theme/": map[string]interface{}{"deleted": true},
+	}
+	require.NoError(t, config.Validate())
+
+	require.NoError(t, os.MkdirAll(filepath.Dir(config.KeyFilePath()), 0755))
+	require.NoError(t, os.WriteFile(config.KeyFilePath(), []byte(`[org/gnome/desktop/interface]
+gtk-theme='Adwaita'
+
+[org/gnome/desktop/extensions/old-theme]
+accent='blue'
+
+[org/gnome/desktop/extensions/old-theme/panel]
+position='top'
+`), 0644))
+
+	executor, _ := newTestExecutor()
+	desired, err := executor.DesiredState(target)
+	require.NoError(t, err)
+	current, err := executor.CurrentState(target)
+	require.NoError(t, err)
+	diff, err := state.NewManager(dir).ComputeDiffWithCurrent(target.GetName(), desired, current)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"interface/gtk-theme", "extensions/old-theme/"}, diff.Removed)
+
+	require.NoError(t, executor.Apply(target, diff))
+
+	keyfile, err := os.ReadFile(config.KeyFilePath())
+	require.NoError(t, err)
+	assert.Equal(t, "[org/gnome/desktop/interface]\nfont-name='Cantarell 11'\n", string(keyfile))
+
+	current, err = executor.CurrentState(target)
+	require.NoError(t, err)
+	diff, err = state.NewManager(dir).ComputeDiffWithCurrent(target.GetName(), desired, current)
+	require.NoError(t, err)
+	assert.True(t, diff.IsEmpty(), "unexpected diff: %+v", diff.Changes)
+
+	config.Settings["interface/"] = "not a directory reset"
+	assert.Error(t, config.Validate())
+}
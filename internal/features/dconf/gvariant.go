@@ -0,0 +1,631 @@
+package dconf
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// gvariantTypePrefixes maps GVariant text format type keywords to their type codes
+var gvariantTypePrefixes = map[string]string{
+	"boolean":    "b",
+	"byte":       "y",
+	"int16":      "n",
+	"uint16":     "q",
+	"int32":      "i",
+	"uint32":     "u",
+	"int64":      "x",
+	"uint64":     "t",
+	"handle":     "h",
+	"double":     "d",
+	"string":     "s",
+	"objectpath": "o",
+	"signature":  "g",
+}
+
+// ParseGVariant decodes a GVariant text literal as printed by `dconf read` and `dconf dump`.
+// Strings, booleans and numbers become string, bool, int64/uint64 and float64; arrays and
+// tuples become []interface{}; dictionaries become map[string]interface{}. Type keywords
+// (uint32 5) and annotations (@as []) are accepted; variants (<...>) are unwrapped.
+func ParseGVariant(text string) (interface{}, error) {
+	p := &gvariantParser{input: text}
+	value, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpaces()
+	if p.pos < len(p.input) {
+		return nil, fmt.Errorf("unexpected %q at offset %d in GVariant %q", p.input[p.pos:], p.pos, text)
+	}
+	return value, nil
+}
+
+// gvariantParser is a recursive descent parser for the GVariant text format
+type gvariantParser struct {
+	input string
+	pos   int
+}
+
+func (p *gvariantParser) skipSpaces() {
+	for p.pos < len(p.input) && strings.IndexByte(" \t\r\n", p.input[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+func (p *gvariantParser) peek() byte {
+	if p.pos < len(p.input) {
+		return p.input[p.pos]
+	}
+	return 0
+}
+
+// word reads an identifier-like token (keywords, numbers) without consuming it
+func (p *gvariantParser) word() string {
+	end := p.pos
+	for end < len(p.input) {
+		c := p.input[end]
+		if c == ',' || c == ']' || c == ')' || c == '}' || c == '>' || c == ':' || c == ' ' || c == '\t' || c == '\n' {
+			break
+		}
+		end++
+	}
+	return p.input[p.pos:end]
+}
+
+func (p *gvariantParser) parseValue() (interface{}, error) {
+	p.skipSpaces()
+	if p.pos >= len(p.input) {
+		return nil, fmt.Errorf("unexpected end of GVariant %q", p.input)
+	}
+
+	switch c := p.peek(); c {
+	case '\'', '"':
+		return p.parseString()
+	case '[':
+		return p.parseSequence('[', ']')
+	case '(':
+		return p.parseSequence('(', ')')
+	case '{':
+		return p.parseDictionary()
+	case '<':
+		p.pos++
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpaces()
+		if p.peek() != '>' {
+			return nil, fmt.Errorf("unterminated variant in GVariant %q", p.input)
+		}
+		p.pos++
+		return value, nil
+	case '@':
+		// Type annotation such as "@as []": the value carries the information we need
+		p.pos++
+		if err := p.skipType(); err != nil {
+			return nil, err
+		}
+		return p.parseValue()
+	}
+
+	word := p.word()
+	if word == "" {
+		return nil, fmt.Errorf("unexpected %q at offset %d in GVariant %q", p.input[p.pos:], p.pos, p.input)
+	}
+
+	if typeCode, ok := gvariantTypePrefixes[word]; ok {
+		p.pos += len(word)
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		return convertToType(value, typeCode)
+	}
+
+	p.pos += len(word)
+	switch word {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "nothing":
+		return nil, nil
+	case "just":
+		return p.parseValue()
+	}
+	return parseNumber(word)
+}
+
+// skipType consumes one complete GVariant type string (e.g. "as", "a{sv}", "(ii)")
+func (p *gvariantParser) skipType() error {
+	if p.pos >= len(p.input) {
+		return fmt.Errorf("unexpected end of type in GVariant %q", p.input)
+	}
+
+	c := p.input[p.pos]
+	p.pos++
+	switch c {
+	case 'a', 'm':
+		return p.skipType()
+	case '(', '{':
+		closing := byte(')')
+		if c == '{' {
+			closing = '}'
+		}
+		for p.peek() != closing {
+			if err := p.skipType(); err != nil {
+				return err
+			}
+		}
+		p.pos++
+		return nil
+	}
+
+	if strings.IndexByte("bynqiuxthdsogvr*?", c) == -1 {
+		return fmt.Errorf("invalid type character %q in GVariant %q", c, p.input)
+	}
+	return nil
+}
+
+// parseNumber parses an integer or floating point GVariant literal
+func parseNumber(word string) (interface{}, error) {
+	if i, err := strconv.ParseInt(word, 0, 64); err == nil {
+		return i, nil
+	}
+	if u, err := strconv.ParseUint(word, 0, 64); err == nil {
+		return u, nil
+	}
+	if f, err := strconv.ParseFloat(word, 64); err == nil {
+		return f, nil
+	}
+	return nil, fmt.Errorf("invalid GVariant literal %q", word)
+}
+
+// convertToType applies an explicit type keyword to a parsed value (e.g. "double 1")
+func convertToType(value interface{}, typeCode string) (interface{}, error) {
+	switch typeCode {
+	case "d":
+		switch v := value.(type) {
+		case int64:
+			return float64(v), nil
+		case uint64:
+			return float64(v), nil
+		}
+	case "y", "n", "q", "i", "u", "x", "t", "h":
+		switch value.(type) {
+		case int64, uint64:
+			return value, nil
+		}
+		return nil, fmt.Errorf("expected integer after type keyword, got %v", value)
+	}
+	return value, nil
+}
+
+func (p *gvariantParser) parseString() (string, error) {
+	quote := p.input[p.pos]
+	p.pos++
+
+	var b strings.Builder
+	for p.pos < len(p.input) {
+		c := p.input[p.pos]
+		switch {
+		case c == quote:
+			p.pos++
+			return b.String(), nil
+		case c == '\\' && p.pos+1 < len(p.input):
+			p.pos++
+			escaped := p.input[p.pos]
+			p.pos++
+			switch escaped {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			case 'b':
+				b.WriteByte('\b')
+			case 'f':
+				b.WriteByte('\f')
+			case 'v':
+				b.WriteByte('\v')
+			case 'u', 'U':
+				size := 4
+				if escaped == 'U' {
+					size = 8
+				}
+				if p.pos+size > len(p.input) {
+					return "", fmt.Errorf("truncated unicode escape in GVariant %q", p.input)
+				}
+				r, err := strconv.ParseUint(p.input[p.pos:p.pos+size], 16, 32)
+				if err != nil {
+					return "", fmt.Errorf("invalid unicode escape in GVariant %q: %w", p.input, err)
+				}
+				b.WriteRune(rune(r))
+				p.pos += size
+			default:
+				b.WriteByte(escaped)
+			}
+		default:
+			r, size := utf8.DecodeRuneInString(p.input[p.pos:])
+			b.WriteRune(r)
+			p.pos += size
+		}
+	}
+	return "", fmt.Errorf("unterminated string in GVariant %q", p.input)
+}
+
+// parseSequence parses arrays and tuples, both returned as []interface{}
+func (p *gvariantParser) parseSequence(open, close byte) ([]interface{}, error) {
+	p.pos++ // skip open
+	items := []interface{}{}
+	for {
+		p.skipSpaces()
+		if p.peek() == close {
+			p.pos++
+			return items, nil
+		}
+		if len(items) > 0 {
+			if p.peek() != ',' {
+				return nil, fmt.Errorf("expected ',' or '%c' at offset %d in GVariant %q", close, p.pos, p.input)
+			}
+			p.pos++
+			p.skipSpaces()
+			if p.peek() == close {
+				// Trailing comma, e.g. single element tuple "(1,)"
+				p.pos++
+				return items, nil
+			}
+		}
+		item, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+}
+
+// parseDictionary parses "{key: value, ...}" with keys converted to strings
+func (p *gvariantParser) parseDictionary() (map[string]interface{}, error) {
+	p.pos++ // skip {
+	result := make(map[string]interface{})
+	for {
+		p.skipSpaces()
+		if p.peek() == '}' {
+			p.pos++
+			return result, nil
+		}
+		if len(result) > 0 {
+			if p.peek() != ',' {
+				return nil, fmt.Errorf("expected ',' or '}' at offset %d in GVariant %q", p.pos, p.input)
+			}
+			p.pos++
+		}
+		key, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpaces()
+		if p.peek() != ':' {
+			return nil, fmt.Errorf("expected ':' at offset %d in GVariant %q", p.pos, p.input)
+		}
+		p.pos++
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		result[fmt.Sprint(key)] = value
+	}
+}
+
+// gvariantTypeKeywords maps GVariant basic type codes to their text format keywords.
+// Types inferred by default (boolean, int32, double, string) have no keyword.
+var gvariantTypeKeywords = map[string]string{
+	"y": "byte",
+	"n": "int16",
+	"q": "uint16",
+	"u": "uint32",
+	"x": "int64",
+	"t": "uint64",
+	"h": "handle",
+	"o": "objectpath",
+	"g": "signature",
+}
+
+// IsTypedValue reports whether a setting uses the explicit {value: ..., type: "..."} form
+func IsTypedValue(value interface{}) bool {
+	m, ok := value.(map[string]interface{})
+	if !ok || len(m) != 2 {
+		return false
+	}
+	_, hasValue := m["value"]
+	typeCode, hasType := m["type"].(string)
+	return hasValue && hasType && typeCode != ""
+}
+
+// EncodeGVariant renders a setting as a GVariant text literal for `dconf write` and keyfiles.
+// Go values are mapped to their natural GVariant type: string to 's', bool to 'b', integers
+// to 'i' (or 'x' beyond the int32 range), floats to 'd' and lists to arrays whose element type
+// is inferred from the first item. Settings of the form {value: ..., type: "..."} are encoded
+// with the given GVariant type string (e.g. "u", "as", "(ss)", "a(ss)").
+func EncodeGVariant(value interface{}) (string, error) {
+	if IsTypedValue(value) {
+		m := value.(map[string]interface{})
+		return encodeTyped(m["value"], m["type"].(string))
+	}
+
+	switch v := value.(type) {
+	case string:
+		return quoteGVariant(v), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int, int32, int64, uint32, uint64:
+		i, _ := toInt(v)
+		if i < math.MinInt32 || i > math.MaxInt32 {
+			return "int64 " + strconv.FormatInt(i, 10), nil
+		}
+		return strconv.FormatInt(i, 10), nil
+	case float32, float64:
+		return formatDouble(toFloat(v)), nil
+	case []string:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = item
+		}
+		return EncodeGVariant(items)
+	case []interface{}:
+		typeCode, err := inferType(v)
+		if err != nil {
+			return "", err
+		}
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			if IsTypedValue(item) {
+				m := item.(map[string]interface{})
+				if m["type"] != typeCode[1:] {
+					return "", fmt.Errorf("list item %d has type %q, expected %q like the first item", i, m["type"], typeCode[1:])
+				}
+				item = m["value"]
+			}
+			items[i] = item
+		}
+		encoded, err := encodeTyped(items, typeCode)
+		if err != nil {
+			return "", fmt.Errorf("list items must have the type of the first item: %w", err)
+		}
+		return encoded, nil
+	}
+	return "", fmt.Errorf("cannot encode %T value %v as GVariant, use {value: ..., type: \"...\"}", value, value)
+}
+
+// inferType returns the GVariant type string EncodeGVariant uses for an untyped value.
+// Lists take the type of their first item, and empty lists are string arrays.
+func inferType(value interface{}) (string, error) {
+	if IsTypedValue(value) {
+		return value.(map[string]interface{})["type"].(string), nil
+	}
+
+	switch v := value.(type) {
+	case string:
+		return "s", nil
+	case bool:
+		return "b", nil
+	case int, int32, int64, uint32, uint64:
+		if i, _ := toInt(v); i < math.MinInt32 || i > math.MaxInt32 {
+			return "x", nil
+		}
+		return "i", nil
+	case float32, float64:
+		return "d", nil
+	case []string:
+		return "as", nil
+	case []interface{}:
+		if len(v) == 0 {
+			return "as", nil
+		}
+		typeCode, err := inferType(v[0])
+		if err != nil {
+			return "", err
+		}
+		// Widen integer arrays when any later item needs it
+		if typeCode == "i" && slices.ContainsFunc(v[1:], func(item interface{}) bool {
+			itemType, _ := inferType(item)
+			return itemType == "x"
+		}) {
+			typeCode = "x"
+		}
+		return "a" + typeCode, nil
+	}
+	return "", fmt.Errorf("cannot encode %T value %v as GVariant, use {value: ..., type: \"...\"}", value, value)
+}
+
+// encodeTyped renders value as a GVariant of the given type string
+func encodeTyped(value interface{}, typeCode string) (string, error) {
+	switch {
+	case typeCode == "b":
+		if b, ok := value.(bool); ok {
+			return strconv.FormatBool(b), nil
+		}
+	case typeCode == "s":
+		if s, ok := value.(string); ok {
+			return quoteGVariant(s), nil
+		}
+	case typeCode == "o" || typeCode == "g":
+		if s, ok := value.(string); ok {
+			return gvariantTypeKeywords[typeCode] + " " + quoteGVariant(s), nil
+		}
+	case typeCode == "d":
+		if i, ok := toInt(value); ok {
+			return formatDouble(float64(i)), nil
+		}
+		if f, ok := value.(float64); ok {
+			return formatDouble(f), nil
+		}
+	case len(typeCode) == 1 && strings.Contains("ynqiuxth", typeCode):
+		i, ok := toInt(value)
+		if f, isFloat := value.(float64); isFloat && f == math.Trunc(f) {
+			i, ok = int64(f), true
+		}
+		if ok {
+			if keyword := gvariantTypeKeywords[typeCode]; keyword != "" {
+				return keyword + " " + strconv.FormatInt(i, 10), nil
+			}
+			return strconv.FormatInt(i, 10), nil
+		}
+	case typeCode == "v":
+		inner, err := EncodeGVariant(value)
+		if err != nil {
+			return "", err
+		}
+		return "<" + inner + ">", nil
+	case strings.HasPrefix(typeCode, "a{"):
+		return encodeTypedDictionary(value, typeCode)
+	case strings.HasPrefix(typeCode, "a"):
+		items, ok := toList(value)
+		if !ok {
+			break
+		}
+		if len(items) == 0 {
+			return "@" + typeCode + " []", nil
+		}
+		parts := make([]string, 0, len(items))
+		for _, item := range items {
+			part, err := encodeTyped(item, typeCode[1:])
+			if err != nil {
+				return "", err
+			}
+			parts = append(parts, part)
+		}
+		return "[" + strings.Join(parts, ", ") + "]", nil
+	case strings.HasPrefix(typeCode, "("):
+		items, ok := toList(value)
+		if !ok {
+			break
+		}
+		memberTypes, err := splitTupleType(typeCode)
+		if err != nil {
+			return "", err
+		}
+		if len(items) != len(memberTypes) {
+			return "", fmt.Errorf("tuple of type %s needs %d items, got %d", typeCode, len(memberTypes), len(items))
+		}
+		parts := make([]string, 0, len(items))
+		for i, item := range items {
+			part, err := encodeTyped(item, memberTypes[i])
+			if err != nil {
+				return "", err
+			}
+			parts = append(parts, part)
+		}
+		if len(parts) == 1 {
+			return "(" + parts[0] + ",)", nil
+		}
+		return "(" + strings.Join(parts, ", ") + ")", nil
+	default:
+		return "", fmt.Errorf("unsupported GVariant type %q", typeCode)
+	}
+	return "", fmt.Errorf("cannot encode %T value %v as GVariant type %q", value, value, typeCode)
+}
+
+// encodeTypedDictionary renders a map as a GVariant dictionary of type a{kv}
+func encodeTypedDictionary(value interface{}, typeCode string) (string, error) {
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		return "", fmt.Errorf("cannot encode %T value %v as GVariant type %q", value, value, typeCode)
+	}
+	entryTypes, err := splitTupleType("(" + typeCode[2:len(typeCode)-1] + ")")
+	if err != nil || len(entryTypes) != 2 {
+		return "", fmt.Errorf("invalid dictionary type %q", typeCode)
+	}
+
+	if len(m) == 0 {
+		return "@" + typeCode + " {}", nil
+	}
+
+	parts := make([]string, 0, len(m))
+	for _, key := range slices.Sorted(maps.Keys(m)) {
+		encodedKey, err := encodeTyped(key, entryTypes[0])
+		if err != nil {
+			return "", err
+		}
+		encodedValue, err := encodeTyped(m[key], entryTypes[1])
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, encodedKey+": "+encodedValue)
+	}
+	return "{" + strings.Join(parts, ", ") + "}", nil
+}
+
+// splitTupleType splits a tuple type string such as "(sa{sv}i)" into its member types
+func splitTupleType(typeCode string) ([]string, error) {
+	if len(typeCode) < 2 || typeCode[0] != '(' || typeCode[len(typeCode)-1] != ')' {
+		return nil, fmt.Errorf("invalid tuple type %q", typeCode)
+	}
+
+	p := &gvariantParser{input: typeCode[1 : len(typeCode)-1]}
+	var members []string
+	for p.pos < len(p.input) {
+		start := p.pos
+		if err := p.skipType(); err != nil {
+			return nil, fmt.Errorf("invalid tuple type %q: %w", typeCode, err)
+		}
+		members = append(members, p.input[start:p.pos])
+	}
+	return members, nil
+}
+
+// toList converts CUE list values to []interface{}
+func toList(value interface{}) ([]interface{}, bool) {
+	switch v := value.(type) {
+	case []interface{}:
+		return v, true
+	case []string:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = item
+		}
+		return items, true
+	}
+	return nil, false
+}
+
+// quoteGVariant quotes a string for the GVariant text format
+func quoteGVariant(s string) string {
+	var b strings.Builder
+	b.WriteByte('\'')
+	for _, r := range s {
+		switch r {
+		case '\'', '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(&b, `\u%04x`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('\'')
+	return b.String()
+}
+
+// formatDouble renders a float so that GVariant parses it as a double, not an integer
+func formatDouble(f float64) string {
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eE") {
+		s += ".0"
+	}
+	return s
+}
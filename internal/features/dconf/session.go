@@ -0,0 +1,103 @@
+package dconf
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strconv"
+)
+
+// userSession is the session context dconf runs in for a configured user
+type userSession struct {
+	name   string
+	uid    uint32
+	gid    uint32
+	groups []uint32
+	// env is the complete environment of the session: HOME, USER, LOGNAME, PATH,
+	// XDG_RUNTIME_DIR and DBUS_SESSION_BUS_ADDRESS when they could be determined
+	env []string
+	// bus reports whether a running session bus was found
+	bus bool
+}
+
+// lookupSession resolves the account of name and discovers its session bus.
+// The bus of a logged in user is found at $XDG_RUNTIME_DIR/bus, or in the
+// environment of one of the user's processes.
+func lookupSession(name string) (*userSession, error) {
+	account, err := user.Lookup(name)
+	if err != nil {
+		return nil, fmt.Errorf("lookup user %s: %w", name, err)
+	}
+
+	uid, err := strconv.ParseUint(account.Uid, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("parse uid of user %s: %w", name, err)
+	}
+	gid, err := strconv.ParseUint(account.Gid, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("parse gid of user %s: %w", name, err)
+	}
+
+	session := &userSession{
+		name: account.Username,
+		uid:  uint32(uid),
+		gid:  uint32(gid),
+		env: []string{
+			"HOME=" + account.HomeDir,
+			"USER=" + account.Username,
+			"LOGNAME=" + account.Username,
+			"PATH=" + os.Getenv("PATH"),
+		},
+	}
+
+	if groupIDs, err := account.GroupIds(); err == nil {
+		for _, id := range groupIDs {
+			if group, err := strconv.ParseUint(id, 10, 32); err == nil {
+				session.groups = append(session.groups, uint32(group))
+			}
+		}
+	}
+
+	runtimeDir := filepath.Join("/run/user", account.Uid)
+	if info, err := os.Stat(runtimeDir); err == nil && info.IsDir() {
+		session.env = append(session.env, "XDG_RUNTIME_DIR="+runtimeDir)
+	}
+
+	if address := sessionBusAddress(runtimeDir, session.uid); address != "" {
+		session.env = append(session.env, "DBUS_SESSION_BUS_ADDRESS="+address)
+		session.bus = true
+	}
+
+	return session, nil
+}
+
+// sessionBusAddress returns the D-Bus session bus address of the user, or "" when
+// the user has no running session bus
+func sessionBusAddress(runtimeDir string, uid uint32) string {
+	socket := filepath.Join(runtimeDir, "bus")
+	if info, err := os.Stat(socket); err == nil && info.Mode()&os.ModeSocket != 0 {
+		return "unix:path=" + socket
+	}
+	return processBusAddress(uid)
+}
+
+// command builds a dconf command that runs as the session user.
+// Without a session bus, writes go through a private bus started by dbus-run-session,
+// whose dconf service still writes the user's ~/.config/dconf/user database.
+// Reads open the database directly and need no bus.
+func (s *userSession) command(args ...string) (*exec.Cmd, error) {
+	var cmd *exec.Cmd
+	if s.bus || args[0] == "dump" || args[0] == "read" {
+		cmd = exec.Command("dconf", args...)
+	} else {
+		cmd = exec.Command("dbus-run-session", append([]string{"--", "dconf"}, args...)...)
+	}
+	cmd.Env = s.env
+
+	if err := setCredential(cmd, s); err != nil {
+		return nil, fmt.Errorf("run dconf as %s: %w", s.name, err)
+	}
+	return cmd, nil
+}
@@ -0,0 +1,54 @@
+//go:build linux
+
+package dconf
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+)
+
+// setCredential makes cmd run with the uid, gid and groups of the session user.
+// Commands for the user running confedit are left unchanged.
+func setCredential(cmd *exec.Cmd, s *userSession) error {
+	if uint32(os.Geteuid()) == s.uid {
+		return nil
+	}
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Credential: &syscall.Credential{Uid: s.uid, Gid: s.gid, Groups: s.groups},
+	}
+	return nil
+}
+
+// processBusAddress looks for DBUS_SESSION_BUS_ADDRESS in the environment of the
+// processes of uid, which covers sessions with a bus outside of XDG_RUNTIME_DIR
+func processBusAddress(uid uint32) string {
+	environs, err := filepath.Glob("/proc/[0-9]*/environ")
+	if err != nil {
+		return ""
+	}
+
+	prefix := []byte("DBUS_SESSION_BUS_ADDRESS=")
+	for _, path := range environs {
+		info, err := os.Stat(filepath.Dir(path))
+		if err != nil {
+			continue
+		}
+		if stat, ok := info.Sys().(*syscall.Stat_t); !ok || stat.Uid != uid {
+			continue
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		for _, variable := range bytes.Split(data, []byte{0}) {
+			if address, found := bytes.CutPrefix(variable, prefix); found && len(address) > 0 {
+				return string(address)
+			}
+		}
+	}
+	return ""
+}
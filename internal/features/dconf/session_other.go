@@ -0,0 +1,22 @@
+//go:build !linux
+
+package dconf
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// setCredential only supports the user running confedit outside of Linux
+func setCredential(cmd *exec.Cmd, s *userSession) error {
+	if uint32(os.Geteuid()) == s.uid {
+		return nil
+	}
+	return fmt.Errorf("switching users is only supported on linux")
+}
+
+// processBusAddress is only supported on Linux
+func processBusAddress(uid uint32) string {
+	return ""
+}
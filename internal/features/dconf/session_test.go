@@ -0,0 +1,112 @@
+package dconf
+
+import (
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookupSession(t *testing.T) {
+	current, err := user.Current()
+	require.NoError(t, err)
+
+	session, err := lookupSession(current.Username)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(os.Getuid()), session.uid)
+	assert.Contains(t, session.env, "HOME="+current.HomeDir)
+	assert.Contains(t, session.env, "USER="+current.Username)
+
+	_, err = lookupSession("confedit-no-such-user")
+	assert.Error(t, err)
+}
+
+func TestSessionBusAddress(t *testing.T) {
+	runtimeDir := t.TempDir()
+
+	// No socket and no process of an unused uid
+	assert.Equal(t, "", sessionBusAddress(runtimeDir, 3999999999))
+
+	listener, err := net.Listen("unix", filepath.Join(runtimeDir, "bus"))
+	require.NoError(t, err)
+	defer listener.Close()
+
+	assert.Equal(t, "unix:path="+filepath.Join(runtimeDir, "bus"), sessionBusAddress(runtimeDir, 3999999999))
+}
+
+func TestUserSession_Command(t *testing.T) {
+	session := &userSession{
+		name: "alice",
+		uid:  uint32(os.Geteuid()),
+		env:  []string{"HOME=/home/alice", "USER=alice"},
+	}
+
+	// Without a session bus, writes start a private bus for the dconf service
+	cmd, err := session.command("write", "/org/gnome/desktop/interface/clock-format", "'24h'")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dbus-run-session", "--", "dconf", "write", "/org/gnome/desktop/interface/clock-format", "'24h'"}, cmd.Args)
+	assert.Equal(t, session.env, cmd.Env)
+	assert.Nil(t, cmd.SysProcAttr, "no credential switch for the current user")
+
+	// Reads never need a bus
+	cmd, err = session.command("dump", "/org/gnome/desktop/interface/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dconf", "dump", "/org/gnome/desktop/interface/"}, cmd.Args)
+
+	session.bus = true
+	session.env = append(session.env, "DBUS_SESSION_BUS_ADDRESS=unix:path=/run/user/1000/bus")
+	cmd, err = session.command("reset", "-f", "/org/gnome/desktop/interface/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dconf", "reset", "-f", "/org/gnome/desktop/interface/"}, cmd.Args)
+	assert.Contains(t, cmd.Env, "DBUS_SESSION_BUS_ADDRESS=unix:path=/run/user/1000/bus")
+}
+
+func TestExecutor_RunsAsUser(t *testing.T) {
+	session := &userSession{
+		name: "alice",
+		uid:  uint32(os.Geteuid()),
+		env:  []string{"HOME=/home/alice"},
+		bus:  true,
+	}
+
+	executor, fake := newTestExecutor()
+	executor.lookupSession = func(name string) (*userSession, error) {
+		assert.Equal(t, "alice", name)
+		return session, nil
+	}
+	fake.dump = "[/]\nclock-format='12h'\n"
+
+	target := NewTarget("clock", "/org/gnome/desktop/interface")
+	target.Config.User = "alice"
+	target.Config.Settings["clock-format"] = "24h"
+
+	current, err := executor.CurrentState(target)
+	require.NoError(t, err)
+	assert.Equal(t, "12h", current["clock-format"])
+
+	require.NoError(t, executor.Apply(target, nil))
+	require.Len(t, fake.commands, 2)
+	assert.Equal(t, []string{"dconf", "write", "/org/gnome/desktop/interface/clock-format", "'24h'"}, fake.calls[1])
+	for _, cmd := range fake.commands {
+		assert.Equal(t, session.env, cmd.Env)
+	}
+}
+
+func TestUserSession_CommandSwitchesCredential(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("switching users is only supported on linux")
+	}
+
+	session := &userSession{name: "alice", uid: uint32(os.Geteuid()) + 1, gid: 1234, groups: []uint32{27}, bus: true}
+	cmd, err := session.command("dump", "/")
+	require.NoError(t, err)
+	require.NotNil(t, cmd.SysProcAttr)
+	assert.Equal(t, session.uid, cmd.SysProcAttr.Credential.Uid)
+	assert.Equal(t, uint32(1234), cmd.SysProcAttr.Credential.Gid)
+	assert.Equal(t, []uint32{27}, cmd.SysProcAttr.Credential.Groups)
+}
@@ -0,0 +1,175 @@
+package dconf
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/thedataflows/confedit/internal/features/file/formats/ini/iniparser"
+	"github.com/thedataflows/confedit/internal/utils"
+)
+
+// parseDump parses `dconf dump` keyfile output into raw GVariant literals keyed by the
+// key path relative to the dumped directory ("[/]" keys as-is, "[sub/dir]" keys as "sub/dir/key")
+func parseDump(output []byte) (map[string]string, error) {
+	lines, err := iniparser.NewRelaxedINIParser().Parse(output)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]string)
+	for _, line := range lines {
+		if line.IsSection || line.CommentPrefix != "" || line.Key == "" {
+			continue
+		}
+		result[relativeKey(line.Section, line.Key)] = line.Value
+	}
+	return result, nil
+}
+
+// relativeKey joins a dump section and key into a key path relative to the dumped directory
+func relativeKey(section, key string) string {
+	section = strings.Trim(section, "/")
+	if section == "" {
+		return key
+	}
+	return section + "/" + key
+}
+
+// isDirectoryKey reports whether a setting key names a dconf directory ("subdir/")
+// rather than a single key. Directories can only be reset as a whole.
+func isDirectoryKey(key string) bool {
+	return strings.HasSuffix(key, "/")
+}
+
+// markDirectories reports deleted directories of settings that still hold keys in the
+// current state, listing those keys, so that the directory reset shows up as a removal
+func markDirectories(current, settings map[string]interface{}) {
+	for key, value := range settings {
+		if !isDirectoryKey(key) || !utils.IsDeletedMarker(value) {
+			continue
+		}
+
+		prefix := strings.TrimPrefix(key, "/")
+		var keys []string
+		for currentKey := range current {
+			if strings.HasPrefix(currentKey, prefix) {
+				keys = append(keys, currentKey)
+			}
+		}
+		if len(keys) == 0 {
+			continue
+		}
+
+		slices.Sort(keys)
+		present := make([]interface{}, len(keys))
+		for i, currentKey := range keys {
+			present[i] = currentKey
+		}
+		current[key] = present
+	}
+}
+
+// normalizeSetting returns desired when the current value is equal to it, so that the
+// state diff does not report representation-only differences (e.g. int64 vs uint64).
+// Otherwise the decoded current value is returned.
+func normalizeSetting(desired, current interface{}) interface{} {
+	if settingEqual(desired, current) {
+		return desired
+	}
+	return current
+}
+
+// settingEqual compares a desired setting with a decoded GVariant value.
+// Integers match integers of any width, floats match doubles, and arrays and
+// tuples are compared element by element. Typed settings compare their value,
+// with integers declared as doubles ({value: 1, type: "d"}) matching 1.0, as do
+// integers in untyped lists starting with a float.
+func settingEqual(desired, current interface{}) bool {
+	if IsTypedValue(desired) {
+		typed := desired.(map[string]interface{})
+		value := typed["value"]
+		if typed["type"] == "d" {
+			if i, ok := toInt(value); ok {
+				value = float64(i)
+			}
+		}
+		return settingEqual(value, current)
+	}
+
+	switch d := desired.(type) {
+	case string, bool, nil:
+		return desired == current
+	case int, int32, int64, uint32, uint64:
+		want, ok := toInt(d)
+		if !ok {
+			return false
+		}
+		have, ok := toInt(current)
+		return ok && want == have
+	case float32, float64:
+		have, ok := current.(float64)
+		return ok && toFloat(d) == have
+	case []string:
+		items := make([]interface{}, len(d))
+		for i, item := range d {
+			items[i] = item
+		}
+		return settingEqual(items, current)
+	case []interface{}:
+		have, ok := current.([]interface{})
+		if !ok || len(d) != len(have) {
+			return false
+		}
+		typeCode, _ := inferType(d)
+		for i := range d {
+			item := d[i]
+			if n, ok := toInt(item); ok && typeCode == "ad" {
+				item = float64(n)
+			}
+			if !settingEqual(item, have[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]interface{}:
+		have, ok := current.(map[string]interface{})
+		if !ok || len(d) != len(have) {
+			return false
+		}
+		for key, value := range d {
+			if !settingEqual(value, have[key]) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// toInt converts any integer type to int64 for comparison
+func toInt(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case uint32:
+		return int64(v), true
+	case uint64:
+		return int64(v), true
+	}
+	return 0, false
+}
+
+// toFloat converts float types to float64
+func toFloat(value interface{}) float64 {
+	switch v := value.(type) {
+	case float32:
+		return float64(v)
+	case float64:
+		return v
+	}
+	return 0
+}
@@ -0,0 +1,195 @@
+package dconf
+
+import (
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thedataflows/confedit/internal/state"
+)
+
+func TestParseGVariant(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`'Adwaita'`, "Adwaita"},
+		{`"it's"`, "it's"},
+		{`'it\'s \\ é'`, "it's \\ é"},
+		{`true`, true},
+		{`false`, false},
+		{`42`, int64(42)},
+		{`-7`, int64(-7)},
+		{`uint32 5`, int64(5)},
+		{`int64 9000000000`, int64(9000000000)},
+		{`byte 0x10`, int64(16)},
+		{`1.25`, 1.25},
+		{`double 1`, float64(1)},
+		{`['<Super>1', '<Super>2']`, []interface{}{"<Super>1", "<Super>2"}},
+		{`@as []`, []interface{}{}},
+		{`@a(ss) []`, []interface{}{}},
+		{`[('xkb', 'us'), ('xkb', 'de')]`, []interface{}{
+			[]interface{}{"xkb", "us"},
+			[]interface{}{"xkb", "de"},
+		}},
+		{`(1, 'a', true)`, []interface{}{int64(1), "a", true}},
+		{`{'a': <1>, 'b': <'x'>}`, map[string]interface{}{"a": int64(1), "b": "x"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(tb *testing.T) {
+			value, err := ParseGVariant(tt.input)
+			require.NoError(tb, err)
+			assert.Equal(tb, tt.expected, value)
+		})
+	}
+
+	for _, invalid := range []string{`'unterminated`, `[1, 2`, `1 2`, `bogus`} {
+		_, err := ParseGVariant(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestDecodeDump(t *testing.T) {
+	dump := []byte(`[/]
+clock-format='24h'
+enable-animations=false
+cursor-size=24
+text-scaling-factor=1.25
+unmanaged='x'
+
+[keybindings]
+switch-to-workspace-1=['<Super>1']
+`)
+
+	settings := map[string]interface{}{
+		"clock-format":                      "24h",
+		"enable-animations":                 true,
+		"cursor-size":                       int64(24),
+		"text-scaling-factor":               1.25,
+		"keybindings/switch-to-workspace-1": []interface{}{"<Super>1"},
+	}
+
+	current, err := decodeDump(dump, settings)
+	require.NoError(t, err)
+
+	assert.Equal(t, "x", current["unmanaged"])
+
+	diff, err := state.NewManager("").ComputeDiffWithCurrent("dconf", settings, current)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"enable-animations"}, slices.Collect(maps.Keys(diff.Modified)))
+	assert.Equal(t, false, diff.Modified["enable-animations"].Old)
+}
+
+func TestDecodeDump_Deleted(t *testing.T) {
+	dump := []byte(`[/]
+clock-format='24h'
+
+[old-extension]
+enabled=true
+
+[old-extension/panel]
+position='top'
+`)
+
+	settings := map[string]interface{}{
+		"clock-format":   map[string]interface{}{"deleted": true},
+		"show-battery":   map[string]interface{}{"deleted": true},
+		"old-extension/": map[string]interface{}{"deleted": true},
+		"stale-dir/":     map[string]interface{}{"deleted": true},
+	}
+
+	current, err := decodeDump(dump, settings)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"old-extension/enabled", "old-extension/panel/position"}, current["old-extension/"])
+	assert.NotContains(t, current, "stale-dir/")
+
+	// Only keys that are still set are reported as removals
+	diff, err := state.NewManager("").ComputeDiffWithCurrent("dconf", settings, current)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"clock-format", "old-extension/"}, diff.Removed)
+	assert.Empty(t, diff.Added)
+	assert.Contains(t, diff.FormatPlain(), "- old-extension/")
+
+	for key, expected := range map[string][]string{
+		"clock-format":   {"reset", "/org/gnome/desktop/interface/clock-format"},
+		"old-extension/": {"reset", "-f", "/org/gnome/desktop/interface/old-extension/"},
+	} {
+		args, err := writeArgs("/org/gnome/desktop/interface", key, settings[key])
+		require.NoError(t, err)
+		assert.Equal(t, expected, args)
+	}
+
+	args, err := writeArgs("/org/gnome/desktop/interface", "cursor-size", int64(24))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"write", "/org/gnome/desktop/interface/cursor-size", "24"}, args)
+}
+
+func TestSettingEqual(t *testing.T) {
+	assert.True(t, settingEqual(int64(5), int64(5)))
+	assert.True(t, settingEqual(int64(5), uint64(5)))
+	assert.False(t, settingEqual(int64(5), float64(5)), "integers do not match doubles")
+	assert.False(t, settingEqual("5", int64(5)))
+	assert.True(t, settingEqual([]interface{}{"a", int64(1)}, []interface{}{"a", int64(1)}))
+	assert.False(t, settingEqual([]interface{}{"a"}, []interface{}{"a", "b"}))
+}
+
+func TestEncodeGVariant(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    interface{}
+		expected string
+	}{
+		{"string", "Adwaita-dark", `'Adwaita-dark'`},
+		{"string with quotes", `it's a "test" \ ok`, `'it\'s a "test" \\ ok'`},
+		{"bool", true, `true`},
+		{"int", int64(5), `5`},
+		{"large int", int64(1) << 40, `int64 1099511627776`},
+		{"double", 1.5, `1.5`},
+		{"integral double", float64(2), `2.0`},
+		{"string array", []interface{}{"<Super>1", "<Super>2"}, `['<Super>1', '<Super>2']`},
+		{"empty array", []interface{}{}, `@as []`},
+		{"double array", []interface{}{1.5, int64(2)}, `[1.5, 2.0]`},
+		{"int64 array", []interface{}{int64(1), int64(1) << 40}, `[int64 1, int64 1099511627776]`},
+		{"typed item array", []interface{}{map[string]interface{}{"value": int64(1), "type": "u"}, int64(2)}, `[uint32 1, uint32 2]`},
+		{"typed uint32", map[string]interface{}{"value": int64(5), "type": "u"}, `uint32 5`},
+		{"typed double", map[string]interface{}{"value": int64(1), "type": "d"}, `1.0`},
+		{"typed empty array", map[string]interface{}{"value": []interface{}{}, "type": "a(ss)"}, `@a(ss) []`},
+		{"typed uint32 array", map[string]interface{}{"value": []interface{}{int64(1), int64(2)}, "type": "au"}, `[uint32 1, uint32 2]`},
+		{"typed tuple array", map[string]interface{}{
+			"value": []interface{}{[]interface{}{"xkb", "us"}, []interface{}{"xkb", "de"}},
+			"type":  "a(ss)",
+		}, `[('xkb', 'us'), ('xkb', 'de')]`},
+		{"typed dictionary", map[string]interface{}{
+			"value": map[string]interface{}{"b": int64(2), "a": "x"},
+			"type":  "a{sv}",
+		}, `{'a': <'x'>, 'b': <2>}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(tb *testing.T) {
+			encoded, err := EncodeGVariant(tt.value)
+			require.NoError(tb, err)
+			assert.Equal(tb, tt.expected, encoded)
+
+			// Whatever we write must read back as the same setting
+			decoded, err := ParseGVariant(encoded)
+			require.NoError(tb, err)
+			assert.True(tb, settingEqual(tt.value, decoded), "round trip of %s decoded as %#v", encoded, decoded)
+		})
+	}
+
+	for _, invalid := range []interface{}{
+		nil,
+		map[string]interface{}{"nested": "map"},
+		map[string]interface{}{"value": "x", "type": "u"},
+		map[string]interface{}{"value": []interface{}{"a"}, "type": "(ss)"},
+		[]interface{}{int64(1), "a"},
+		[]interface{}{"a", []interface{}{"b"}},
+	} {
+		_, err := EncodeGVariant(invalid)
+		assert.Error(t, err, "%v", invalid)
+	}
+}
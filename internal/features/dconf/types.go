@@ -2,16 +2,38 @@ package dconf
 
 import (
 	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
 
 	"github.com/thedataflows/confedit/internal/types"
 	"github.com/thedataflows/confedit/internal/utils"
 )
 
-// Config represents the configuration for a dconf target
+const (
+	// DefaultConfigDir is where system-wide dconf databases and profiles live
+	DefaultConfigDir = "/etc/dconf"
+	// DefaultKeyFile is the keyfile (and lock file) name used inside a system database
+	DefaultKeyFile = "confedit"
+)
+
+// Config represents the configuration for a dconf target.
+// Without Database, settings are written into the user database with `dconf write`.
+// With Database, settings are rendered into the system-wide keyfile
+// <config_dir>/db/<database>.d/<keyfile>, Locks into locks/<keyfile>, and
+// `dconf update` is run whenever one of those files changed.
 type Config struct {
 	User     string                 `json:"user,omitempty"`
 	Schema   string                 `json:"schema"`
 	Settings map[string]interface{} `json:"settings"`
+	// System-wide database management
+	Database  string   `json:"database,omitempty"`
+	KeyFile   string   `json:"keyfile,omitempty"`
+	Locks     []string `json:"locks,omitempty"`
+	Profile   string   `json:"profile,omitempty"`
+	ConfigDir string   `json:"config_dir,omitempty"`
+	// Replace symlinked database files instead of writing through them
+	ReplaceSymlinks bool `json:"replace_symlinks,omitempty"`
 }
 
 // Type implements TargetConfig interface
@@ -24,9 +46,73 @@ func (c *Config) Validate() error {
 	if c.Schema == "" {
 		return fmt.Errorf("schema is required for dconf target")
 	}
+	for key, value := range c.Settings {
+		if utils.IsDeletedMarker(value) {
+			continue
+		}
+		if isDirectoryKey(key) {
+			return fmt.Errorf("dconf directory %s can only be reset with {deleted: true}", key)
+		}
+		if _, err := EncodeGVariant(value); err != nil {
+			return fmt.Errorf("invalid dconf setting %s: %w", key, err)
+		}
+	}
+	if c.Database == "" {
+		if c.KeyFile != "" || len(c.Locks) > 0 || c.Profile != "" {
+			return fmt.Errorf("keyfile, locks and profile require a dconf database")
+		}
+		return nil
+	}
+	if c.User != "" {
+		return fmt.Errorf("user cannot be combined with a system dconf database")
+	}
+	for field, name := range map[string]string{"database": c.Database, "keyfile": c.KeyFile, "profile": c.Profile} {
+		if strings.Contains(name, "/") {
+			return fmt.Errorf("%s must be a file name, got %q", field, name)
+		}
+	}
 	return nil
 }
 
+// IsSystem reports whether the target manages a system-wide dconf database
+func (c *Config) IsSystem() bool {
+	return c.Database != ""
+}
+
+// configDir returns the dconf configuration directory
+func (c *Config) configDir() string {
+	if c.ConfigDir != "" {
+		return c.ConfigDir
+	}
+	return DefaultConfigDir
+}
+
+// keyFileName returns the name of the managed keyfile and lock file
+func (c *Config) keyFileName() string {
+	if c.KeyFile != "" {
+		return c.KeyFile
+	}
+	return DefaultKeyFile
+}
+
+// KeyFilePath returns the path of the managed keyfile in the system database
+func (c *Config) KeyFilePath() string {
+	return filepath.Join(c.configDir(), "db", c.Database+".d", c.keyFileName())
+}
+
+// LockFilePath returns the path of the managed lock file in the system database
+func (c *Config) LockFilePath() string {
+	return filepath.Join(c.configDir(), "db", c.Database+".d", "locks", c.keyFileName())
+}
+
+// ProfilePath returns the path of the managed dconf profile, or "" when unmanaged
+func (c *Config) ProfilePath() string {
+	if c.Profile == "" {
+		return ""
+	}
+	return filepath.Join(c.configDir(), "profile", c.Profile)
+}
+
 // Target is a type alias for dconf-based configuration targets
 type Target = types.BaseTarget[*Config]
 
@@ -55,6 +141,26 @@ func MergeConfig(existing, newTarget *Config) error {
 	if newTarget.User != "" {
 		existing.User = newTarget.User
 	}
+	if newTarget.Database != "" {
+		existing.Database = newTarget.Database
+	}
+	if newTarget.KeyFile != "" {
+		existing.KeyFile = newTarget.KeyFile
+	}
+	if newTarget.Profile != "" {
+		existing.Profile = newTarget.Profile
+	}
+	if newTarget.ConfigDir != "" {
+		existing.ConfigDir = newTarget.ConfigDir
+	}
+	if newTarget.ReplaceSymlinks {
+		existing.ReplaceSymlinks = true
+	}
+	for _, lock := range newTarget.Locks {
+		if !slices.Contains(existing.Locks, lock) {
+			existing.Locks = append(existing.Locks, lock)
+		}
+	}
 
 	return nil
 }
@@ -87,9 +87,10 @@ func (e *Executor) Apply(target types.AnyTarget, diff *state.ConfigDiff) error {
 		return fmt.Errorf("get current state: %w", err)
 	}
 
-	// Merge desired content into current state to preserve all unmanaged keys
-	if err := utils.DeepMerge(currentState, fileTarget.GetConfig().Content); err != nil {
-		return fmt.Errorf("merge content: %w", err)
+	// Merge desired content and patches into current state to preserve all unmanaged keys
+	currentState, err = e.patchState(fileTarget.GetConfig(), currentState)
+	if err != nil {
+		return err
 	}
 
 	// Marshal and write the patched state
@@ -98,23 +99,45 @@ func (e *Executor) Apply(target types.AnyTarget, diff *state.ConfigDiff) error {
 		return fmt.Errorf("marshal content: %w", err)
 	}
 
-	if err := os.WriteFile(fileTarget.GetConfig().Path, buf.Bytes(), 0644); err != nil {
+	mode, err := e.fileMode(fileTarget.GetConfig())
+	if err != nil {
+		return fmt.Errorf("set permissions: %w", err)
+	}
+
+	if err := utils.WriteFileAtomic(fileTarget.GetConfig().Path, buf.Bytes(), utils.WriteOptions{
+		Mode:            mode,
+		ReplaceSymlinks: fileTarget.GetConfig().ReplaceSymlinks,
+	}); err != nil {
 		return fmt.Errorf("write file: %w", err)
 	}
 
-	// Set ownership and permissions
+	// Set ownership
 	if err := e.setFileOwnership(fileTarget.GetConfig()); err != nil {
 		return fmt.Errorf("set ownership: %w", err)
 	}
 
-	if err := e.setFilePermissions(fileTarget.GetConfig()); err != nil {
-		return fmt.Errorf("set permissions: %w", err)
-	}
-
 	log.Debugf("file-executor", "Successfully updated file: %s", fileTarget.GetConfig().Path)
 	return nil
 }
 
+// contentNormalizer returns the parser of format when it normalizes declared content
+func (e *Executor) contentNormalizer(format string) (formats.ContentNormalizer, bool) {
+	parser, err := e.registry.Get(format)
+	if err != nil {
+		return nil, false
+	}
+	normalizer, ok := parser.(formats.ContentNormalizer)
+	return normalizer, ok
+}
+
+// normalizeContent returns content in the form the parser of format decodes it to
+func (e *Executor) normalizeContent(format string, current, content map[string]interface{}) map[string]interface{} {
+	if normalizer, ok := e.contentNormalizer(format); ok {
+		return normalizer.NormalizeContent(current, content)
+	}
+	return content
+}
+
 // Validate checks if the target is valid
 func (e *Executor) Validate(target types.AnyTarget) error {
 	if target.GetType() != types.TYPE_FILE {
@@ -154,12 +177,18 @@ func (e *Executor) CurrentState(target types.AnyTarget) (map[string]interface{},
 	}
 
 	fileTarget := target.(*Target)
+	format := fileTarget.GetConfig().Format
 
 	if _, err := os.Stat(fileTarget.GetConfig().Path); os.IsNotExist(err) {
+		// Do not patch a document parsed for another target into the new file
+		if parser, err := e.registry.Get(format); err == nil {
+			if documentParser, ok := parser.(formats.DocumentParser); ok {
+				documentParser.Reset()
+			}
+		}
 		return make(map[string]interface{}), nil
 	}
 
-	format := fileTarget.GetConfig().Format
 	if format == "" {
 		return nil, fmt.Errorf("file format is not specified")
 	}
@@ -184,6 +213,78 @@ func (e *Executor) CurrentState(target types.AnyTarget) (map[string]interface{},
 	return parser.Unmarshal(data)
 }
 
+// DesiredState returns the declared content, normalized for formats that need it, with
+// the lists that have a merge strategy combined with the lists currently in the file.
+// With patches, it is the whole patched document, where keys the patches remove are
+// {deleted: true} markers.
+func (e *Executor) DesiredState(target types.AnyTarget) (map[string]interface{}, error) {
+	if err := e.Validate(target); err != nil {
+		return nil, err
+	}
+
+	config := target.(*Target).GetConfig()
+	_, normalize := e.contentNormalizer(config.Format)
+	if !normalize && len(config.Arrays) == 0 && config.MergePatch == nil && len(config.Patch) == 0 {
+		return config.Content, nil
+	}
+
+	currentState, err := e.CurrentState(target)
+	if err != nil {
+		return nil, fmt.Errorf("get current state: %w", err)
+	}
+	if config.MergePatch == nil && len(config.Patch) == 0 {
+		return utils.MergeArrays(currentState, e.normalizeContent(config.Format, currentState, config.Content), config.Arrays)
+	}
+
+	desired, err := e.patchState(config, utils.CopyValue(currentState).(map[string]interface{}))
+	if err != nil {
+		return nil, err
+	}
+	markRemoved(currentState, desired)
+	return desired, nil
+}
+
+// patchState merges the content of config into state, removes the keys marked for
+// deletion and applies the patches
+func (e *Executor) patchState(config *Config, state map[string]interface{}) (map[string]interface{}, error) {
+	// Combine configured lists with the current ones before merging
+	content, err := utils.MergeArrays(state, e.normalizeContent(config.Format, state, config.Content), config.Arrays)
+	if err != nil {
+		return nil, fmt.Errorf("merge arrays: %w", err)
+	}
+	if err := utils.DeepMerge(state, content); err != nil {
+		return nil, fmt.Errorf("merge content: %w", err)
+	}
+	// Keys marked {deleted: true} are removed instead of written
+	utils.RemoveDeleted(state)
+
+	if config.MergePatch != nil {
+		state = utils.ApplyMergePatch(state, config.MergePatch)
+	}
+	if len(config.Patch) > 0 {
+		if state, err = utils.ApplyPatch(state, config.Patch); err != nil {
+			return nil, fmt.Errorf("apply patch: %w", err)
+		}
+	}
+	return state, nil
+}
+
+// markRemoved adds a deletion marker to desired for every key of current it lacks
+func markRemoved(current, desired map[string]interface{}) {
+	for key, value := range current {
+		desiredValue, exists := desired[key]
+		if !exists {
+			desired[key] = map[string]interface{}{"deleted": true}
+			continue
+		}
+		currentMap, currentIsMap := value.(map[string]interface{})
+		desiredMap, desiredIsMap := desiredValue.(map[string]interface{})
+		if currentIsMap && desiredIsMap {
+			markRemoved(currentMap, desiredMap)
+		}
+	}
+}
+
 // setFileOwnership sets the owner and group of the file
 func (e *Executor) setFileOwnership(target *Config) error {
 	owner := target.Owner
@@ -218,20 +319,23 @@ func (e *Executor) setFileOwnership(target *Config) error {
 	return syscall.Chown(target.Path, uid, gid)
 }
 
-// setFilePermissions sets the file permissions
-func (e *Executor) setFilePermissions(target *Config) error {
+// fileMode parses the configured file permissions, zero when the existing mode is kept
+func (e *Executor) fileMode(target *Config) (os.FileMode, error) {
 	modeValue := target.Mode
 	if modeValue == "" {
-		return nil
+		return 0, nil
 	}
 
 	mode, err := strconv.ParseUint(modeValue, 8, 32)
 	if err != nil {
-		return fmt.Errorf("invalid file mode %s: %w", modeValue, err)
+		return 0, fmt.Errorf("invalid file mode %s: %w", modeValue, err)
 	}
 
-	return os.Chmod(target.Path, os.FileMode(mode))
+	return os.FileMode(mode), nil
 }
 
-// Verify that Executor implements the engine.Executor interface at compile time
-var _ engine.Executor = (*Executor)(nil)
+// Verify that Executor implements the engine interfaces at compile time
+var (
+	_ engine.Executor      = (*Executor)(nil)
+	_ engine.DesiredStater = (*Executor)(nil)
+)
@@ -6,9 +6,12 @@ import (
 	"github.com/thedataflows/confedit/internal/engine"
 	"github.com/thedataflows/confedit/internal/features"
 	"github.com/thedataflows/confedit/internal/features/file/formats"
+	"github.com/thedataflows/confedit/internal/features/file/formats/env"
 	"github.com/thedataflows/confedit/internal/features/file/formats/ini"
 	jsonformat "github.com/thedataflows/confedit/internal/features/file/formats/json"
+	"github.com/thedataflows/confedit/internal/features/file/formats/properties"
 	"github.com/thedataflows/confedit/internal/features/file/formats/toml"
+	"github.com/thedataflows/confedit/internal/features/file/formats/whitespace"
 	"github.com/thedataflows/confedit/internal/features/file/formats/xml"
 	"github.com/thedataflows/confedit/internal/features/file/formats/yaml"
 	"github.com/thedataflows/confedit/internal/types"
@@ -29,7 +32,11 @@ func New() features.Feature {
 	registry.Register("yaml", yaml.New())
 	registry.Register("toml", toml.New())
 	registry.Register("json", jsonformat.New())
+	registry.Register("jsonc", jsonformat.NewJSONC())
 	registry.Register("xml", xml.New())
+	registry.Register("properties", properties.New())
+	registry.Register("env", env.New())
+	registry.Register("whitespace", whitespace.New())
 
 	return &Feature{
 		registry: registry,
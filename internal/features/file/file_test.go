@@ -1,10 +1,17 @@
 package file_test
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thedataflows/confedit/internal/engine"
 	"github.com/thedataflows/confedit/internal/features/file"
+	"github.com/thedataflows/confedit/internal/state"
 	"github.com/thedataflows/confedit/internal/types"
+	"github.com/thedataflows/confedit/internal/utils"
 )
 
 func TestFileFeature_Type(t *testing.T) {
@@ -62,6 +69,17 @@ func TestFileFeature_Validate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "merge by key without key",
+			config: &file.Config{
+				Path:   "/tmp/test.yaml",
+				Format: "yaml",
+				Arrays: map[string]utils.ArrayMerge{
+					"users": {Strategy: utils.ARRAY_MERGE_BY_KEY},
+				},
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -99,3 +117,229 @@ func TestFileFeature_NewTarget(t *testing.T) {
 		t.Errorf("expected type %s, got %s", types.TYPE_FILE, target.GetType())
 	}
 }
+
+// converge computes the drift of target, applies it and returns the diff
+func converge(t *testing.T, target *file.Target) *state.ConfigDiff {
+	t.Helper()
+	executor := file.New().Executor()
+
+	current, err := executor.CurrentState(target)
+	require.NoError(t, err)
+	desired, err := executor.(engine.DesiredStater).DesiredState(target)
+	require.NoError(t, err)
+	diff, err := state.NewManager("").ComputeDiffWithCurrent(target.GetName(), desired, current)
+	require.NoError(t, err)
+
+	require.NoError(t, executor.Apply(target, diff))
+	return diff
+}
+
+func TestFileExecutor_Patch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "settings.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+  "servers": ["a", "b"],
+  "legacy": {"timeout": 30},
+  "obsolete": true,
+  "theme": "dark"
+}
+`), 0644))
+
+	target := file.NewTarget("settings", path, "json")
+	target.Config.Content = map[string]interface{}{"theme": "light"}
+	target.Config.MergePatch = map[string]interface{}{"legacy": nil}
+	target.Config.Patch = []utils.PatchOperation{
+		{Op: utils.PATCH_TEST, Path: "/servers/0", Value: "a"},
+		{Op: utils.PATCH_ADD, Path: "/servers/1", Value: "c"},
+		{Op: utils.PATCH_ADD, Path: "/servers/-", Value: "d"},
+		{Op: utils.PATCH_REMOVE, Path: "/obsolete"},
+	}
+
+	diff := converge(t, target)
+	assert.Equal(t, map[string]interface{}{"servers[1]": "c", "servers[3]": "d"}, diff.Items["servers"].Added)
+	assert.ElementsMatch(t, []string{"legacy.timeout", "obsolete"}, diff.Removed)
+	assert.Contains(t, diff.Modified, "theme")
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, `{
+  "servers": [
+    "a",
+    "c",
+    "b",
+    "d"
+  ],
+  "theme": "light"
+}
+`, string(content))
+
+	// The operations converge: patching the patched file changes nothing
+	assert.True(t, converge(t, target).IsEmpty())
+}
+
+func TestFileExecutor_PatchTestFailureAborts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "settings.json")
+	original := "{\"version\": 1}\n"
+	require.NoError(t, os.WriteFile(path, []byte(original), 0644))
+
+	target := file.NewTarget("settings", path, "json")
+	target.Config.Content = map[string]interface{}{"feature": true}
+	target.Config.Patch = []utils.PatchOperation{
+		{Op: utils.PATCH_TEST, Path: "/version", Value: 2},
+	}
+
+	executor := file.New().Executor()
+	_, err := executor.(engine.DesiredStater).DesiredState(target)
+	assert.ErrorIs(t, err, utils.ErrPatchTestFailed)
+	assert.ErrorIs(t, executor.Apply(target, nil), utils.ErrPatchTestFailed)
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, original, string(content))
+}
+
+func TestFileExecutor_DeleteKeys(t *testing.T) {
+	tests := []struct {
+		format  string
+		content string
+		desired map[string]interface{}
+		removed []string
+		want    string
+	}{
+		{
+			format: "yaml",
+			content: `server:
+  host: localhost # bind address
+  legacy:
+    timeout: 30
+  port: 8080
+debug: true
+`,
+			desired: map[string]interface{}{
+				"server": map[string]interface{}{"legacy": map[string]interface{}{"deleted": true}},
+				"debug":  map[string]interface{}{"deleted": true},
+			},
+			removed: []string{"server.legacy.timeout", "debug"},
+			want: `server:
+  host: localhost # bind address
+  port: 8080
+`,
+		},
+		{
+			format: "json",
+			content: `{
+  "server": {
+    "host": "localhost",
+    "port": 8080
+  },
+  "debug": true
+}
+`,
+			desired: map[string]interface{}{
+				"server": map[string]interface{}{"port": map[string]interface{}{"deleted": true}},
+				"debug":  map[string]interface{}{"deleted": true},
+			},
+			removed: []string{"server.port", "debug"},
+			want: `{
+  "server": {
+    "host": "localhost"
+  }
+}
+`,
+		},
+		{
+			format: "toml",
+			content: `title = "app"
+
+[server]
+host = "localhost"
+port = 8080
+
+[server.legacy]
+timeout = 30
+`,
+			desired: map[string]interface{}{
+				"server": map[string]interface{}{
+					"port":   map[string]interface{}{"deleted": true},
+					"legacy": map[string]interface{}{"deleted": true},
+				},
+			},
+			removed: []string{"server.port", "server.legacy.timeout"},
+			want: `title = "app"
+
+[server]
+host = "localhost"
+`,
+		},
+		{
+			format: "xml",
+			content: `<config version="1">
+  <server host="localhost" debug="true">
+    <port>8080</port>
+  </server>
+</config>
+`,
+			desired: map[string]interface{}{
+				"config": map[string]interface{}{
+					"server": map[string]interface{}{
+						"@debug": map[string]interface{}{"deleted": true},
+						"port":   map[string]interface{}{"deleted": true},
+					},
+				},
+			},
+			removed: []string{"config.server.@debug", "config.server.port"},
+			want: `<config version="1">
+  <server host="localhost">
+  </server>
+</config>
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(tb *testing.T) {
+			path := filepath.Join(tb.TempDir(), "config."+tt.format)
+			require.NoError(tb, os.WriteFile(path, []byte(tt.content), 0644))
+
+			target := file.NewTarget("config", path, tt.format)
+			target.Config.Content = tt.desired
+
+			diff := converge(tb, target)
+			assert.ElementsMatch(tb, tt.removed, diff.Removed)
+			assert.Empty(tb, diff.Added)
+			assert.Empty(tb, diff.Modified)
+			assert.Contains(tb, diff.FormatPlain(), "Remove:")
+
+			content, err := os.ReadFile(path)
+			require.NoError(tb, err)
+			assert.Equal(tb, tt.want, string(content))
+
+			// Once the keys are gone the target is in sync
+			assert.True(tb, converge(tb, target).IsEmpty())
+		})
+	}
+}
+
+func TestFileExecutor_WhitespaceKeysIgnoreCase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sshd_config")
+	require.NoError(t, os.WriteFile(path, []byte("PermitRootLogin yes\nHostKey /etc/ssh/ssh_host_ed25519_key\n\nMatch User backup\n\tX11Forwarding yes\n"), 0644))
+
+	target := file.NewTarget("sshd", path, "whitespace")
+	target.Config.Content = map[string]interface{}{
+		"permitrootlogin": "no",
+		"HostKey":         []interface{}{"/etc/ssh/ssh_host_ed25519_key"},
+		"match user backup": map[string]interface{}{
+			"x11forwarding": []interface{}{},
+		},
+	}
+
+	diff := converge(t, target)
+	assert.Equal(t, []string{"Match User backup.X11Forwarding"}, diff.Removed)
+	assert.Equal(t, map[string]state.DiffValue{"PermitRootLogin": {Old: "yes", New: "no"}}, diff.Modified)
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "PermitRootLogin no\nHostKey /etc/ssh/ssh_host_ed25519_key\n\nMatch User backup\n", string(content))
+
+	// Keys spelled differently from the file are in sync once applied
+	assert.True(t, converge(t, target).IsEmpty())
+}
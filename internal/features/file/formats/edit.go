@@ -0,0 +1,44 @@
+package formats
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+)
+
+// Edit replaces the source bytes in [Start, End) with Text
+type Edit struct {
+	Start int
+	End   int
+	Text  string
+}
+
+// Apply returns src with edits applied in offset order. An edit that overlaps an
+// earlier one is skipped; insertions go before a replacement or removal starting at
+// the same offset, so that neither is skipped. With crlf, newlines in the edit
+// texts are written as "\r\n".
+func Apply(src []byte, edits []Edit, crlf bool) []byte {
+	edits = slices.Clone(edits)
+	slices.SortStableFunc(edits, func(a, b Edit) int {
+		if a.Start != b.Start {
+			return a.Start - b.Start
+		}
+		return min(a.End-a.Start, 1) - min(b.End-b.Start, 1)
+	})
+
+	var out bytes.Buffer
+	position := 0
+	for _, ed := range edits {
+		if ed.Start < position {
+			continue
+		}
+		out.Write(src[position:ed.Start])
+		if crlf {
+			ed.Text = strings.ReplaceAll(ed.Text, "\n", "\r\n")
+		}
+		out.WriteString(ed.Text)
+		position = ed.End
+	}
+	out.Write(src[position:])
+	return out.Bytes()
+}
@@ -0,0 +1,59 @@
+package formats
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApply(t *testing.T) {
+	src := []byte("a = 1\nb = 2\nc = 3\n")
+
+	tests := []struct {
+		name     string
+		edits    []Edit
+		crlf     bool
+		expected string
+	}{
+		{
+			name:     "no edits",
+			expected: "a = 1\nb = 2\nc = 3\n",
+		},
+		{
+			name: "edits in any order",
+			edits: []Edit{
+				{Start: 18, End: 18, Text: "d = 4\n"},
+				{Start: 4, End: 5, Text: "one"},
+			},
+			expected: "a = one\nb = 2\nc = 3\nd = 4\n",
+		},
+		{
+			name: "insertion before removal at the same offset",
+			edits: []Edit{
+				{Start: 6, End: 12},
+				{Start: 6, End: 6, Text: "x = 0\n"},
+			},
+			expected: "a = 1\nx = 0\nc = 3\n",
+		},
+		{
+			name: "overlapping edit is skipped",
+			edits: []Edit{
+				{Start: 0, End: 12},
+				{Start: 4, End: 5, Text: "one"},
+			},
+			expected: "c = 3\n",
+		},
+		{
+			name:     "crlf newlines in edit text",
+			edits:    []Edit{{Start: 18, End: 18, Text: "d = 4\n"}},
+			crlf:     true,
+			expected: "a = 1\nb = 2\nc = 3\nd = 4\r\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(tb *testing.T) {
+			assert.Equal(tb, tt.expected, string(Apply(src, tt.edits, tt.crlf)))
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package env
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// entry is a KEY=value assignment of an env document with its byte ranges
+type entry struct {
+	key        string
+	value      string
+	export     bool // the assignment starts with "export "
+	quote      byte // quote character of the value, 0 when unquoted
+	start      int  // offset of the line the entry starts on
+	end        int  // offset after the line ending of the entry
+	valueStart int  // offset of the raw value, including its opening quote
+	valueEnd   int  // offset after the raw value, including its closing quote
+}
+
+// parseDocument scans the assignments of src. Blank lines, comments and lines that
+// are not assignments, such as shell commands in /etc/default files, belong to no entry.
+func parseDocument(src []byte) ([]entry, error) {
+	var entries []entry
+	for offset := 0; offset < len(src); {
+		e, ok, err := parseEntry(src, offset)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", bytes.Count(src[:offset], []byte("\n"))+1, err)
+		}
+		if !ok {
+			offset = nextLine(src, offset)
+			continue
+		}
+		entries = append(entries, e)
+		offset = e.end
+	}
+	return entries, nil
+}
+
+// parseEntry parses the assignment on the line starting at offset
+func parseEntry(src []byte, offset int) (entry, bool, error) {
+	e := entry{start: offset}
+	i := skipSpace(src, offset)
+	if rest := src[i:]; bytes.HasPrefix(rest, []byte("export")) && len(rest) > 6 && (rest[6] == ' ' || rest[6] == '\t') {
+		e.export = true
+		i = skipSpace(src, i+6)
+	}
+
+	keyStart := i
+	for i < len(src) && isKeyChar(src[i], i == keyStart) {
+		i++
+	}
+	if i == keyStart {
+		return e, false, nil
+	}
+	e.key = string(src[keyStart:i])
+	i = skipSpace(src, i)
+	if i >= len(src) || src[i] != '=' {
+		return e, false, nil
+	}
+	i = skipSpace(src, i+1)
+
+	e.valueStart = i
+	var err error
+	if i < len(src) && (src[i] == '\'' || src[i] == '"') {
+		e.quote = src[i]
+		if e.value, e.valueEnd, err = readQuoted(src, i); err != nil {
+			return e, false, fmt.Errorf("%s: %w", e.key, err)
+		}
+	} else {
+		e.value, e.valueEnd = readUnquoted(src, i)
+	}
+	e.end = nextLine(src, e.valueEnd)
+	return e, true, nil
+}
+
+// readQuoted decodes the quoted value starting at i and returns the offset after its
+// closing quote. Single-quoted values are literal; double-quoted values may span
+// lines and decode \\, \", \$, \` and \n.
+func readQuoted(src []byte, i int) (string, int, error) {
+	quote := src[i]
+	var sb strings.Builder
+	for i++; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case c == quote:
+			return sb.String(), i + 1, nil
+		case c == '\\' && quote == '"' && i+1 < len(src):
+			switch next := src[i+1]; next {
+			case '\\', '"', '$', '`':
+				sb.WriteByte(next)
+				i++
+			case 'n':
+				sb.WriteByte('\n')
+				i++
+			default:
+				sb.WriteByte(c)
+			}
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return "", i, fmt.Errorf("unterminated %c quote", quote)
+}
+
+// readUnquoted returns the unquoted value starting at i, which ends at the end of
+// the line or at a # preceded by whitespace, without trailing whitespace
+func readUnquoted(src []byte, i int) (string, int) {
+	start := i
+	for i < len(src) && src[i] != '\n' && src[i] != '\r' {
+		if src[i] == '#' && i > 0 && (src[i-1] == ' ' || src[i-1] == '\t') {
+			break
+		}
+		i++
+	}
+	for i > start && (src[i-1] == ' ' || src[i-1] == '\t') {
+		i--
+	}
+	return string(src[start:i]), i
+}
+
+// renderValue quotes value in the style of quote where possible: single quotes
+// unless the value holds one, double quotes, or no quotes for values that need none
+func renderValue(value string, quote byte) string {
+	switch {
+	case quote == '\'' && !strings.Contains(value, "'"):
+		return "'" + value + "'"
+	case quote == 0 && isPlain(value):
+		return value
+	default:
+		return `"` + doubleQuoteEscaper.Replace(value) + `"`
+	}
+}
+
+// doubleQuoteEscaper escapes the characters readQuoted decodes in double quotes
+var doubleQuoteEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`, "`", "\\`", "\n", `\n`)
+
+// isPlain reports whether value reads back unchanged without quotes
+func isPlain(value string) bool {
+	if value == "" {
+		return true
+	}
+	return !strings.ContainsAny(value, " \t\r\n'\"\\$`#;&|<>(){}*?[]~!")
+}
+
+// isKeyChar reports whether c may appear in a variable name; names do not start with a digit
+func isKeyChar(c byte, first bool) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || !first && (c >= '0' && c <= '9' || c == '.' || c == '-')
+}
+
+// skipSpace returns the offset of the first byte at or after i that is not a space or tab
+func skipSpace(src []byte, i int) int {
+	for i < len(src) && (src[i] == ' ' || src[i] == '\t') {
+		i++
+	}
+	return i
+}
+
+// nextLine returns the offset after the newline at or after i, or the end of src
+func nextLine(src []byte, i int) int {
+	if newline := bytes.IndexByte(src[i:], '\n'); newline >= 0 {
+		return i + newline + 1
+	}
+	return len(src)
+}
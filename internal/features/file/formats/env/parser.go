@@ -0,0 +1,150 @@
+package env
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/thedataflows/confedit/internal/features/file/formats"
+)
+
+// Parser implements the formats.Parser interface for dotenv and shell variable files
+// such as .env, /etc/default/* and /etc/environment. A document decodes to a flat map
+// of variable names to string values; quotes are removed, "export" is ignored, the
+// last of repeated names wins and variables are not expanded.
+// It remembers the last unmarshaled document so that Marshal only rewrites the
+// values that changed, in their original quoting style, keeping comments and other
+// lines as they are.
+type Parser struct {
+	source  []byte
+	entries []entry
+}
+
+// New creates a new env parser
+func New() formats.Parser {
+	return &Parser{}
+}
+
+func (p *Parser) Unmarshal(data []byte) (map[string]interface{}, error) {
+	p.Reset()
+	entries, err := parseDocument(data)
+	if err != nil {
+		return nil, err
+	}
+	p.source = bytes.Clone(data)
+	p.entries = entries
+
+	result := make(map[string]interface{}, len(entries))
+	for _, e := range entries {
+		result[e.key] = e.value
+	}
+	return result, nil
+}
+
+func (p *Parser) Marshal(data map[string]interface{}, writer io.Writer) error {
+	out, err := patchDocument(p.source, p.entries, data)
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(out)
+	return err
+}
+
+// Reset implements DocumentParser to forget the previously parsed document
+func (p *Parser) Reset() {
+	p.source = nil
+	p.entries = nil
+}
+
+// patchDocument rewrites src so that it decodes to desired. Changed values are
+// replaced in place, removed variables lose their lines and new variables are
+// appended in name order, exported when the last assignment is.
+func patchDocument(src []byte, entries []entry, desired map[string]interface{}) ([]byte, error) {
+	last := make(map[string]int, len(entries))
+	for i, e := range entries {
+		last[e.key] = i
+	}
+
+	var edits []formats.Edit
+	for i, e := range entries {
+		value, wanted := desired[e.key]
+		if !wanted {
+			edits = append(edits, formats.Edit{Start: e.start, End: e.end})
+			continue
+		}
+		// Earlier assignments of a repeated variable are overridden by the last one
+		if last[e.key] != i {
+			continue
+		}
+		text, err := formatValue(e.key, value)
+		if err != nil {
+			return nil, err
+		}
+		if text != e.value {
+			edits = append(edits, formats.Edit{Start: e.valueStart, End: e.valueEnd, Text: renderValue(text, e.quote)})
+		}
+	}
+
+	prefix, newline := "", "\n"
+	if len(entries) > 0 && entries[len(entries)-1].export {
+		prefix = "export "
+	}
+	if bytes.Contains(src, []byte("\r\n")) {
+		newline = "\r\n"
+	}
+
+	var added strings.Builder
+	for _, key := range slices.Sorted(maps.Keys(desired)) {
+		if _, exists := last[key]; exists {
+			continue
+		}
+		if !isName(key) {
+			return nil, fmt.Errorf("invalid variable name: %s", key)
+		}
+		text, err := formatValue(key, desired[key])
+		if err != nil {
+			return nil, err
+		}
+		added.WriteString(prefix + key + "=" + renderValue(text, 0) + newline)
+	}
+	if added.Len() > 0 {
+		text := added.String()
+		if len(src) > 0 && src[len(src)-1] != '\n' {
+			text = newline + text
+		}
+		edits = append(edits, formats.Edit{Start: len(src), End: len(src), Text: text})
+	}
+
+	return formats.Apply(src, edits, false), nil
+}
+
+// formatValue returns the text of a scalar value
+func formatValue(key string, value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case bool, int, int64, float64:
+		return fmt.Sprint(v), nil
+	default:
+		return "", fmt.Errorf("value of variable %s is not a scalar: %v", key, value)
+	}
+}
+
+// isName reports whether key is a variable name that parses back
+func isName(key string) bool {
+	for i := 0; i < len(key); i++ {
+		if !isKeyChar(key[i], i == 0) {
+			return false
+		}
+	}
+	return key != ""
+}
+
+// Verify that Parser implements the interfaces at compile time
+var (
+	_ formats.Parser         = (*Parser)(nil)
+	_ formats.DocumentParser = (*Parser)(nil)
+)
@@ -0,0 +1,135 @@
+package env
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thedataflows/confedit/internal/utils"
+)
+
+const defaultsFile = `# Defaults for the app init script
+export APP_HOME=/opt/app
+APP_OPTS="-Xmx512m -Dfile.encoding=UTF-8" # JVM flags
+GREETING='Hello $USER'
+ESCAPED="say \"hi\" for \$5"
+EMPTY=
+MULTILINE="first
+second"
+
+if [ -f /etc/app.local ]; then
+  . /etc/app.local
+fi
+`
+
+// patch unmarshals src, merges content into it like the file executor does and
+// returns the marshaled document
+func patch(t *testing.T, src string, content map[string]interface{}) string {
+	t.Helper()
+	parser := New()
+	current, err := parser.Unmarshal([]byte(src))
+	require.NoError(t, err)
+	require.NoError(t, utils.DeepMerge(current, content))
+	utils.RemoveDeleted(current)
+
+	var buf bytes.Buffer
+	require.NoError(t, parser.Marshal(current, &buf))
+
+	// The patched document must decode to the merged content
+	decoded, err := New().Unmarshal(buf.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, current, decoded)
+	return buf.String()
+}
+
+// replaceOnce replaces old, which must occur exactly once, with new
+func replaceOnce(t *testing.T, s, old, new string) string {
+	t.Helper()
+	require.Equal(t, 1, strings.Count(s, old), "expected exactly one %q", old)
+	return strings.Replace(s, old, new, 1)
+}
+
+func TestParser_Unmarshal(t *testing.T) {
+	data, err := New().Unmarshal([]byte(defaultsFile))
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]interface{}{
+		"APP_HOME":  "/opt/app",
+		"APP_OPTS":  "-Xmx512m -Dfile.encoding=UTF-8",
+		"GREETING":  "Hello $USER",
+		"ESCAPED":   `say "hi" for $5`,
+		"EMPTY":     "",
+		"MULTILINE": "first\nsecond",
+	}, data)
+}
+
+func TestParser_UnmarshalEdgeCases(t *testing.T) {
+	data, err := New().Unmarshal([]byte("PATH=/usr/bin # system path\r\nHASH=a#b\r\nA=1\r\nA=2\r\nNOTE= # empty\r\nexport_ME=x\r\n"))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"PATH":      "/usr/bin",
+		"HASH":      "a#b",
+		"A":         "2",
+		"NOTE":      "",
+		"export_ME": "x",
+	}, data)
+
+	_, err = New().Unmarshal([]byte("BROKEN=\"no end\n"))
+	assert.Error(t, err)
+}
+
+func TestParser_UnchangedDocumentIsIdentical(t *testing.T) {
+	out := patch(t, defaultsFile, map[string]interface{}{"APP_HOME": "/opt/app"})
+	assert.Equal(t, defaultsFile, out)
+}
+
+func TestParser_KeepsQuotingStyle(t *testing.T) {
+	out := patch(t, defaultsFile, map[string]interface{}{
+		"APP_HOME":  "/srv/app",
+		"APP_OPTS":  "-Xmx1g",
+		"GREETING":  "Hi $USER",
+		"ESCAPED":   "back\\slash and `tick`",
+		"EMPTY":     "needs quotes",
+		"MULTILINE": "single",
+	})
+
+	expected := replaceOnce(t, defaultsFile, "export APP_HOME=/opt/app", "export APP_HOME=/srv/app")
+	expected = replaceOnce(t, expected, `APP_OPTS="-Xmx512m -Dfile.encoding=UTF-8" # JVM flags`, `APP_OPTS="-Xmx1g" # JVM flags`)
+	expected = replaceOnce(t, expected, `GREETING='Hello $USER'`, `GREETING='Hi $USER'`)
+	expected = replaceOnce(t, expected, `ESCAPED="say \"hi\" for \$5"`, "ESCAPED=\"back\\\\slash and \\`tick\\`\"")
+	expected = replaceOnce(t, expected, "EMPTY=\n", "EMPTY=\"needs quotes\"\n")
+	expected = replaceOnce(t, expected, "MULTILINE=\"first\nsecond\"", `MULTILINE="single"`)
+	assert.Equal(t, expected, out)
+}
+
+func TestParser_SingleQuotesFallBackToDoubleQuotes(t *testing.T) {
+	out := patch(t, "MSG='plain'\n", map[string]interface{}{"MSG": "it's $HOME"})
+	assert.Equal(t, "MSG=\"it's \\$HOME\"\n", out)
+}
+
+func TestParser_AddsAndRemovesVariables(t *testing.T) {
+	out := patch(t, defaultsFile, map[string]interface{}{
+		"GREETING":  map[string]interface{}{"deleted": true},
+		"MULTILINE": map[string]interface{}{"deleted": true},
+		"LANG":      "C.UTF-8",
+		"JAVA_OPTS": "-server -Xss1m",
+	})
+
+	expected := replaceOnce(t, defaultsFile, "GREETING='Hello $USER'\n", "")
+	expected = replaceOnce(t, expected, "MULTILINE=\"first\nsecond\"\n", "")
+	expected += "JAVA_OPTS=\"-server -Xss1m\"\nLANG=C.UTF-8\n"
+	assert.Equal(t, expected, out)
+}
+
+func TestParser_NewVariablesFollowExport(t *testing.T) {
+	out := patch(t, "export A=1\r\nexport B=2", map[string]interface{}{"C": "3"})
+	assert.Equal(t, "export A=1\r\nexport B=2\r\nexport C=3\r\n", out)
+}
+
+func TestParser_MarshalErrors(t *testing.T) {
+	var buf bytes.Buffer
+	assert.Error(t, New().Marshal(map[string]interface{}{"1BAD": "x"}, &buf))
+	assert.Error(t, New().Marshal(map[string]interface{}{"NESTED": map[string]interface{}{"A": "b"}}, &buf))
+}
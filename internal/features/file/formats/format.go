@@ -3,7 +3,7 @@ package formats
 import "io"
 
 // Parser defines the interface for file format parsers
-// Each format (ini, yaml, toml, json, xml) implements this interface
+// Each format (ini, yaml, toml, json, jsonc, xml, properties, env, whitespace) implements this interface
 type Parser interface {
 	// Unmarshal parses the data from bytes into a map
 	Unmarshal(data []byte) (map[string]interface{}, error)
@@ -20,3 +20,23 @@ type ConfigurableParser interface {
 	// Configure sets parser-specific options (e.g., INI delimiter, comment chars)
 	Configure(options map[string]interface{}) error
 }
+
+// DocumentParser extends Parser for formats that remember the last unmarshaled
+// document, so that Marshal patches it in place and keeps comments and layout
+type DocumentParser interface {
+	Parser
+
+	// Reset forgets the last unmarshaled document, so the next Marshal starts from scratch
+	Reset()
+}
+
+// ContentNormalizer extends Parser for formats where declared content does not
+// decode the same as the document it produces, so that it compares equal once
+// normalized
+type ContentNormalizer interface {
+	Parser
+
+	// NormalizeContent returns content in the form Unmarshal decodes it to once it is
+	// merged into current
+	NormalizeContent(current, content map[string]interface{}) map[string]interface{}
+}
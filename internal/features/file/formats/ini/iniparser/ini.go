@@ -64,6 +64,11 @@ func NewRelaxedINIParser() *RelaxedINIParser {
 	}
 }
 
+// SetUseSpacing controls whether new keys are written with spaces around the delimiter
+func (p *RelaxedINIParser) SetUseSpacing(useSpacing bool) {
+	p.useSpacing = useSpacing
+}
+
 func (p *RelaxedINIParser) Parse(data []byte) ([]INILine, error) {
 	scanner := bufio.NewScanner(bytes.NewReader(data))
 	p.lines = make([]INILine, 0)
@@ -294,6 +299,97 @@ func (p *RelaxedINIParser) AddKey(lines []INILine, section, key, value string) [
 	return lines
 }
 
+// SetValues replaces every active occurrence of key in section with one line per value.
+// This allows keys that may legitimately repeat (e.g. systemd "ExecStart=" resets followed
+// by a new command) to be managed as a whole. The new lines take the position of the first
+// existing occurrence; when the key is absent they are added after the last active key of
+// the section, and the section header is appended when the section does not exist yet.
+// An empty values slice removes the key.
+func (p *RelaxedINIParser) SetValues(lines []INILine, section, key string, values []string) []INILine {
+	first := -1
+	delimiter := ""
+	indent := ""
+	result := make([]INILine, 0, len(lines)+len(values))
+	for _, line := range lines {
+		if !line.IsSection && line.CommentPrefix == "" && line.Section == section && line.Key == key {
+			if first == -1 {
+				first = len(result)
+				delimiter = line.Delimiter
+				indent = line.Indent
+			}
+			continue
+		}
+		result = append(result, line)
+	}
+
+	if delimiter == "" {
+		delimiter = string(p.delimiter)
+		if p.useSpacing {
+			delimiter = " " + delimiter + " "
+		}
+	}
+
+	newLines := make([]INILine, 0, len(values))
+	for _, value := range values {
+		newLines = append(newLines, INILine{
+			Section:   section,
+			Key:       key,
+			Value:     value,
+			Indent:    indent,
+			Delimiter: delimiter,
+		})
+	}
+
+	if first == -1 {
+		first = sectionInsertIndex(result, section)
+		if first == -1 {
+			if len(newLines) == 0 {
+				return result
+			}
+			if len(result) > 0 && !result[len(result)-1].IsEmpty {
+				result = append(result, INILine{Section: section, IsEmpty: true})
+			}
+			result = append(result, INILine{Section: section, IsSection: true})
+			return append(result, newLines...)
+		}
+	}
+
+	return slices.Insert(result, first, newLines...)
+}
+
+// sectionInsertIndex returns the index right after the last active key of section,
+// or right after the section header when it has no keys. For the root section ("")
+// it returns the index after the last root key. It returns -1 if section does not exist.
+func sectionInsertIndex(lines []INILine, section string) int {
+	index := -1
+	if section == "" {
+		index = 0
+	}
+	for i, line := range lines {
+		if line.IsSection {
+			if line.Section == section && index == -1 {
+				index = i + 1
+			}
+			continue
+		}
+		if line.Section == section && line.Key != "" && line.CommentPrefix == "" {
+			index = i + 1
+		}
+	}
+	return index
+}
+
+// GetValues returns the values of every active occurrence of key in section, in file order
+func GetValues(lines []INILine, section, key string) []string {
+	var values []string
+	for _, line := range lines {
+		if !line.IsSection && line.CommentPrefix == "" && line.Section == section && line.Key == key {
+			values = append(values, line.Value)
+		}
+	}
+	return values
+}
+
 func GetValue(lines []INILine, section, key string) (string, bool) {
 	currentSection := ""
 	for _, line := range lines {
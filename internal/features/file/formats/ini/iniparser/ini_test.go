@@ -336,6 +336,112 @@ key3=value3`
 	}
 }
 
+func TestINIParser_SetValues(t *testing.T) {
+	input := `[Unit]
+Description=Demo
+
+[Service]
+ExecStart=/usr/bin/old
+Restart=no
+ExecStart=/usr/bin/other
+`
+
+	tests := []struct {
+		name     string
+		section  string
+		key      string
+		values   []string
+		expected string
+	}{
+		{
+			name:    "replace repeated key in place",
+			section: "Service",
+			key:     "ExecStart",
+			values:  []string{"", "/usr/bin/new --flag"},
+			expected: `[Unit]
+Description=Demo
+
+[Service]
+ExecStart=
+ExecStart=/usr/bin/new --flag
+Restart=no
+`,
+		},
+		{
+			name:    "add key after last key in section",
+			section: "Unit",
+			key:     "After",
+			values:  []string{"network.target"},
+			expected: `[Unit]
+Description=Demo
+After=network.target
+
+[Service]
+ExecStart=/usr/bin/old
+Restart=no
+ExecStart=/usr/bin/other
+`,
+		},
+		{
+			name:    "add missing section",
+			section: "Install",
+			key:     "WantedBy",
+			values:  []string{"multi-user.target"},
+			expected: `[Unit]
+Description=Demo
+
+[Service]
+ExecStart=/usr/bin/old
+Restart=no
+ExecStart=/usr/bin/other
+
+[Install]
+WantedBy=multi-user.target
+`,
+		},
+		{
+			name:    "remove key",
+			section: "Service",
+			key:     "ExecStart",
+			values:  nil,
+			expected: `[Unit]
+Description=Demo
+
+[Service]
+Restart=no
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(tb *testing.T) {
+			parser := NewRelaxedINIParser()
+			parser.SetUseSpacing(false)
+
+			parsed, err := parser.Parse([]byte(input))
+			if err != nil {
+				tb.Fatalf("Parse failed: %v", err)
+			}
+
+			parsed = parser.SetValues(parsed, tt.section, tt.key, tt.values)
+
+			var buf bytes.Buffer
+			if err := parser.Serialize(parsed, &buf); err != nil {
+				tb.Fatalf("Serialize failed: %v", err)
+			}
+
+			if buf.String() != tt.expected {
+				tb.Errorf("unexpected result.\nExpected:\n%s\nGot:\n%s", tt.expected, buf.String())
+			}
+
+			values := GetValues(parsed, tt.section, tt.key)
+			if len(values) != len(tt.values) {
+				tb.Errorf("GetValues returned %v, expected %v", values, tt.values)
+			}
+		})
+	}
+}
+
 // =============================================================================
 // Edge Cases Tests
 // =============================================================================
@@ -2,6 +2,8 @@ package iniparser
 
 import (
 	"io"
+
+	"github.com/thedataflows/confedit/internal/utils"
 )
 
 // INIWrapper implements FormatParser for INI files with structure preservation
@@ -112,6 +114,11 @@ func (w *INIWrapper) Marshal(data map[string]interface{}, writer io.Writer) erro
 	return w.parser.Serialize(lines, writer)
 }
 
+// Reset forgets the lines of the last parse, so the next Marshal builds lines from scratch
+func (w *INIWrapper) Reset() {
+	w.lines = nil
+}
+
 // ensureSection gets or creates a section map in the result
 func (w *INIWrapper) ensureSection(result map[string]interface{}, section string) map[string]interface{} {
 	if sectionMap, ok := result[section].(map[string]interface{}); ok {
@@ -238,12 +245,9 @@ func findValue(data map[string]interface{}, section, key string) interface{} {
 
 // updateLineValue updates a line with new value
 func updateLineValue(line INILine, value interface{}) INILine {
-	// Handle deletion marker
-	if valueMap, ok := value.(map[string]interface{}); ok {
-		if deleted, exists := valueMap["deleted"]; exists && deleted == true {
-			// Marked for deletion - return empty (will be filtered out)
-			return INILine{}
-		}
+	// Marked for deletion - return empty (will be filtered out)
+	if utils.IsDeletedMarker(value) {
+		return INILine{}
 	}
 
 	// Regular value update
@@ -257,12 +261,9 @@ func updateLineValue(line INILine, value interface{}) INILine {
 
 // createLine creates a new INILine from section, key, value
 func createLine(section, key string, value interface{}) INILine {
-	// Handle deletion marker
-	if valueMap, ok := value.(map[string]interface{}); ok {
-		if deleted, exists := valueMap["deleted"]; exists && deleted == true {
-			// Marked for deletion - return empty line (will be filtered out)
-			return INILine{}
-		}
+	// Marked for deletion - return empty line (will be filtered out)
+	if utils.IsDeletedMarker(value) {
+		return INILine{}
 	}
 
 	line := INILine{
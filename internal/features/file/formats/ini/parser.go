@@ -33,6 +33,11 @@ func (p *Parser) Marshal(data map[string]interface{}, writer io.Writer) error {
 	return p.wrapper.Marshal(data, writer)
 }
 
+// Reset implements DocumentParser to forget the previously parsed file
+func (p *Parser) Reset() {
+	p.wrapper.Reset()
+}
+
 // Configure implements ConfigurableParser to accept INI-specific options
 // Supported options:
 //   - use_spacing (bool): Controls delimiter formatting for new keys
@@ -45,8 +50,9 @@ func (p *Parser) Configure(options map[string]interface{}) error {
 	return p.wrapper.Configure(options)
 }
 
-// Verify that Parser implements both interfaces at compile time
+// Verify that Parser implements the interfaces at compile time
 var (
 	_ formats.Parser             = (*Parser)(nil)
 	_ formats.ConfigurableParser = (*Parser)(nil)
+	_ formats.DocumentParser     = (*Parser)(nil)
 )
@@ -0,0 +1,265 @@
+package jsonformat
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/goccy/go-json"
+)
+
+// node is a JSON value with its byte range in the document
+type node struct {
+	start   int
+	end     int
+	kind    byte      // '{' for objects, '[' for arrays, 0 for scalars
+	members []*member // object members in document order
+	items   []*node   // array items in document order
+	// trailingComma is the offset of a comma after the last member or item, -1 if none
+	trailingComma int
+}
+
+// member is a key and value of an object
+type member struct {
+	key      string
+	keyStart int
+	keyEnd   int
+	value    *node
+	comma    int // offset of the comma following the value, -1 if none
+}
+
+// scanner parses the structure of a JSON document. Comments must already be blanked
+// out; trailing commas are accepted when lenient is set.
+type scanner struct {
+	src     []byte
+	pos     int
+	lenient bool
+}
+
+// parseDocument parses src into its root node
+func parseDocument(src []byte, lenient bool) (*node, error) {
+	s := &scanner{src: src, lenient: lenient}
+	root, err := s.value()
+	if err != nil {
+		return nil, err
+	}
+	s.skipSpace()
+	if s.pos != len(src) {
+		return nil, fmt.Errorf("unexpected data after JSON value at offset %d", s.pos)
+	}
+	return root, nil
+}
+
+func (s *scanner) value() (*node, error) {
+	s.skipSpace()
+	if s.pos >= len(s.src) {
+		return nil, fmt.Errorf("unexpected end of JSON")
+	}
+
+	switch s.src[s.pos] {
+	case '{':
+		return s.object()
+	case '[':
+		return s.array()
+	case '"':
+		start := s.pos
+		end, err := s.stringEnd()
+		if err != nil {
+			return nil, err
+		}
+		s.pos = end
+		return &node{start: start, end: end, trailingComma: -1}, nil
+	default:
+		start := s.pos
+		for s.pos < len(s.src) && !bytes.ContainsRune([]byte(" \t\r\n,]}"), rune(s.src[s.pos])) {
+			s.pos++
+		}
+		if s.pos == start {
+			return nil, fmt.Errorf("unexpected %q at offset %d", s.src[start], start)
+		}
+		return &node{start: start, end: s.pos, trailingComma: -1}, nil
+	}
+}
+
+func (s *scanner) object() (*node, error) {
+	n := &node{start: s.pos, kind: '{', trailingComma: -1}
+	s.pos++
+	for {
+		s.skipSpace()
+		if s.pos >= len(s.src) {
+			return nil, fmt.Errorf("unterminated object at offset %d", n.start)
+		}
+		if s.src[s.pos] == '}' {
+			s.pos++
+			n.end = s.pos
+			return n, nil
+		}
+		if len(n.members) > 0 && n.members[len(n.members)-1].comma < 0 {
+			return nil, fmt.Errorf("expected , or } at offset %d", s.pos)
+		}
+		if s.src[s.pos] != '"' {
+			return nil, fmt.Errorf("expected object key at offset %d", s.pos)
+		}
+
+		m := &member{keyStart: s.pos, comma: -1}
+		end, err := s.stringEnd()
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(s.src[m.keyStart:end], &m.key); err != nil {
+			return nil, fmt.Errorf("invalid object key at offset %d: %w", m.keyStart, err)
+		}
+		m.keyEnd = end
+		s.pos = end
+
+		s.skipSpace()
+		if s.pos >= len(s.src) || s.src[s.pos] != ':' {
+			return nil, fmt.Errorf("expected : at offset %d", s.pos)
+		}
+		s.pos++
+		if m.value, err = s.value(); err != nil {
+			return nil, err
+		}
+		n.members = append(n.members, m)
+
+		if s.comma() {
+			m.comma = s.pos - 1
+			if s.closes('}') {
+				if !s.lenient {
+					return nil, fmt.Errorf("trailing comma at offset %d", m.comma)
+				}
+				n.trailingComma = m.comma
+			}
+		}
+	}
+}
+
+func (s *scanner) array() (*node, error) {
+	n := &node{start: s.pos, kind: '[', trailingComma: -1}
+	s.pos++
+	separated := true
+	for {
+		s.skipSpace()
+		if s.pos >= len(s.src) {
+			return nil, fmt.Errorf("unterminated array at offset %d", n.start)
+		}
+		if s.src[s.pos] == ']' {
+			s.pos++
+			n.end = s.pos
+			return n, nil
+		}
+		if !separated {
+			return nil, fmt.Errorf("expected , or ] at offset %d", s.pos)
+		}
+
+		item, err := s.value()
+		if err != nil {
+			return nil, err
+		}
+		n.items = append(n.items, item)
+
+		separated = s.comma()
+		if separated && s.closes(']') {
+			if !s.lenient {
+				return nil, fmt.Errorf("trailing comma at offset %d", s.pos-1)
+			}
+			n.trailingComma = s.pos - 1
+		}
+	}
+}
+
+// comma consumes a comma following optional whitespace
+func (s *scanner) comma() bool {
+	s.skipSpace()
+	if s.pos < len(s.src) && s.src[s.pos] == ',' {
+		s.pos++
+		return true
+	}
+	return false
+}
+
+// closes reports whether the next non-space byte is the closing bracket
+func (s *scanner) closes(bracket byte) bool {
+	i := s.pos
+	for i < len(s.src) && isSpace(s.src[i]) {
+		i++
+	}
+	return i < len(s.src) && s.src[i] == bracket
+}
+
+// stringEnd returns the offset after the string starting at the current position
+func (s *scanner) stringEnd() (int, error) {
+	for i := s.pos + 1; i < len(s.src); i++ {
+		switch s.src[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1, nil
+		case '\n':
+			return 0, fmt.Errorf("unterminated string at offset %d", s.pos)
+		}
+	}
+	return 0, fmt.Errorf("unterminated string at offset %d", s.pos)
+}
+
+func (s *scanner) skipSpace() {
+	for s.pos < len(s.src) && isSpace(s.src[s.pos]) {
+		s.pos++
+	}
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
+
+// blankComments returns a copy of src with // and /* */ comments replaced by spaces,
+// keeping newlines, so that offsets into the copy are valid in src
+func blankComments(src []byte) []byte {
+	out := bytes.Clone(src)
+	for i := 0; i < len(out); i++ {
+		switch {
+		case out[i] == '"':
+			for i++; i < len(out) && out[i] != '"' && out[i] != '\n'; i++ {
+				if out[i] == '\\' {
+					i++
+				}
+			}
+		case out[i] == '/' && i+1 < len(out) && out[i+1] == '/':
+			for ; i < len(out) && out[i] != '\n'; i++ {
+				out[i] = ' '
+			}
+		case out[i] == '/' && i+1 < len(out) && out[i+1] == '*':
+			end := bytes.Index(out[i+2:], []byte("*/"))
+			if end < 0 {
+				end = len(out)
+			} else {
+				end += i + 4
+			}
+			for ; i < end; i++ {
+				if out[i] != '\n' {
+					out[i] = ' '
+				}
+			}
+			i--
+		}
+	}
+	return out
+}
+
+// blankTrailingCommas replaces the trailing commas found in root with spaces
+func blankTrailingCommas(src []byte, root *node) []byte {
+	out := bytes.Clone(src)
+	var walk func(*node)
+	walk = func(n *node) {
+		if n.trailingComma >= 0 {
+			out[n.trailingComma] = ' '
+		}
+		for _, m := range n.members {
+			walk(m.value)
+		}
+		for _, item := range n.items {
+			walk(item)
+		}
+	}
+	walk(root)
+	return out
+}
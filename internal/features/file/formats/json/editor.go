@@ -0,0 +1,322 @@
+package jsonformat
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/goccy/go-json"
+	"github.com/thedataflows/confedit/internal/features/file/formats"
+)
+
+// editor patches a JSON document in place. Only the members whose values change are
+// rewritten; key order, indentation, comments and the trailing newline are kept.
+type editor struct {
+	src   []byte // original document
+	plain []byte // document with comments blanked out, for layout decisions
+	unit  string // indentation unit, empty for single-line documents
+	crlf  bool   // the document ends its lines with \r\n
+	edits []formats.Edit
+}
+
+// patchDocument rewrites src so that it decodes to desired. current is the decoded
+// content of src and tells which values actually changed.
+func patchDocument(src []byte, root *node, plain []byte, current, desired map[string]interface{}) ([]byte, error) {
+	if root.kind != '{' {
+		return nil, fmt.Errorf("json document is not an object")
+	}
+	e := &editor{
+		src:   src,
+		plain: plain,
+		unit:  detectIndent(plain, root),
+		crlf:  bytes.Contains(src, []byte("\r\n")),
+	}
+	if err := e.patchObject(root, current, desired); err != nil {
+		return nil, err
+	}
+	return formats.Apply(e.src, e.edits, e.crlf), nil
+}
+
+// patchObject reconciles the members of object with the desired content
+func (e *editor) patchObject(object *node, current, desired map[string]interface{}) error {
+	seen := make(map[string]bool, len(object.members))
+	var removed []int
+	anchor := -1 // last member that is kept
+	for i, m := range object.members {
+		seen[m.key] = true
+		value, wanted := desired[m.key]
+		if !wanted {
+			removed = append(removed, i)
+			continue
+		}
+		anchor = i
+		if equalValues(current[m.key], value) {
+			continue
+		}
+
+		currentMap, currentIsMap := current[m.key].(map[string]interface{})
+		desiredMap, desiredIsMap := value.(map[string]interface{})
+		if m.value.kind == '{' && currentIsMap && desiredIsMap {
+			if err := e.patchObject(m.value, currentMap, desiredMap); err != nil {
+				return err
+			}
+			continue
+		}
+
+		text, err := e.render(value, e.lineIndent(m.keyStart))
+		if err != nil {
+			return fmt.Errorf("render json key %s: %w", m.key, err)
+		}
+		e.edits = append(e.edits, formats.Edit{Start: m.value.start, End: m.value.end, Text: text})
+	}
+
+	var added []string
+	for _, key := range slices.Sorted(maps.Keys(desired)) {
+		if !seen[key] {
+			added = append(added, key)
+		}
+	}
+	if anchor < 0 && len(added) > 0 {
+		return e.fillObject(object, desired, added)
+	}
+
+	if anchor >= 0 && len(added) > 0 {
+		if err := e.appendMembers(object, anchor, desired, added); err != nil {
+			return err
+		}
+	}
+	// Removals come after the insertions that share their offset
+	for _, i := range removed {
+		e.removeMember(object.members[i])
+	}
+	if anchor < 0 || len(added) > 0 {
+		return nil
+	}
+
+	// The kept member is now the last one and loses its comma, unless the
+	// object uses trailing commas
+	last := object.members[anchor]
+	if anchor < len(object.members)-1 && object.trailingComma < 0 {
+		next := object.members[anchor+1]
+		end := last.comma + 1
+		if e.lineStart(next.keyStart) <= last.comma {
+			// Single-line objects also lose the space after the comma
+			end = next.keyStart
+		}
+		e.edits = append(e.edits, formats.Edit{Start: last.comma, End: end})
+	}
+	return nil
+}
+
+// removeMember deletes a member with its comma. A member on lines of its own goes
+// with its lines, including a trailing comment.
+func (e *editor) removeMember(m *member) {
+	start := m.keyStart
+	ownLine := false
+	if lineStart := e.lineStart(start); e.blank(lineStart, start) {
+		start = lineStart
+		ownLine = true
+	}
+
+	end := m.value.end
+	if m.comma >= 0 {
+		end = m.comma + 1
+	}
+	if lineEnd := e.lineEnd(end); ownLine && e.blank(end, lineEnd) {
+		end = min(lineEnd+1, len(e.src))
+	} else if m.comma >= 0 {
+		for end < len(e.src) && (e.src[end] == ' ' || e.src[end] == '\t') {
+			end++
+		}
+	}
+	e.edits = append(e.edits, formats.Edit{Start: start, End: end})
+}
+
+// appendMembers inserts new members after the anchor-th member of object, copying
+// its indentation and key separator. Members after the anchor are being removed.
+func (e *editor) appendMembers(object *node, anchor int, values map[string]interface{}, keys []string) error {
+	last := object.members[anchor]
+	indent := e.lineIndent(last.keyStart)
+	separator := string(e.plain[last.keyEnd:last.value.start])
+	multiline := e.multiline(object)
+
+	members := make([]string, 0, len(keys))
+	for _, key := range keys {
+		text, err := e.renderMember(key, values[key], indent, separator)
+		if err != nil {
+			return err
+		}
+		members = append(members, text)
+	}
+	join := ", "
+	if multiline {
+		join = ",\n" + indent
+	}
+	text := strings.Join(members, join)
+	if object.trailingComma >= 0 {
+		text += ","
+	}
+
+	switch {
+	case last.comma >= 0 && !multiline:
+		// Take the place of the first removed member
+		next := object.members[anchor+1]
+		e.edits = append(e.edits, formats.Edit{Start: next.keyStart, End: next.keyStart, Text: text})
+	case last.comma >= 0:
+		e.insertAfterLine(last.comma+1, "\n"+indent+text, true)
+	default:
+		e.edits = append(e.edits, formats.Edit{Start: last.value.end, End: last.value.end, Text: ","})
+		if !multiline {
+			text = " " + text
+		} else {
+			text = "\n" + indent + text
+		}
+		e.insertAfterLine(last.value.end, text, multiline)
+	}
+	return nil
+}
+
+// insertAfterLine inserts text at offset, or at the end of its line when only
+// whitespace or a comment follows, so that trailing comments stay with their member
+func (e *editor) insertAfterLine(offset int, text string, multiline bool) {
+	if lineEnd := e.lineEnd(offset); multiline && e.blank(offset, lineEnd) {
+		offset = lineEnd
+		// Keep \r of CRLF line endings before the newline
+		if offset > 0 && e.src[offset-1] == '\r' {
+			offset--
+		}
+	}
+	e.edits = append(e.edits, formats.Edit{Start: offset, End: offset, Text: text})
+}
+
+// fillObject writes the members of an object that has none left
+func (e *editor) fillObject(object *node, values map[string]interface{}, keys []string) error {
+	indent := e.lineIndent(object.start)
+	memberIndent := indent + e.unit
+	separator := ": "
+	if e.unit == "" {
+		separator = ":"
+	}
+
+	members := make([]string, 0, len(keys))
+	for _, key := range keys {
+		text, err := e.renderMember(key, values[key], memberIndent, separator)
+		if err != nil {
+			return err
+		}
+		members = append(members, text)
+	}
+
+	var text string
+	if e.unit == "" {
+		text = strings.Join(members, ",")
+	} else {
+		text = "\n" + memberIndent + strings.Join(members, ",\n"+memberIndent) + "\n" + indent
+	}
+	// Removed members and comments inside the object are dropped with it
+	e.edits = append(e.edits, formats.Edit{Start: object.start + 1, End: object.end - 1, Text: text})
+	return nil
+}
+
+// renderMember renders a "key": value member whose line starts with indent
+func (e *editor) renderMember(key string, value interface{}, indent, separator string) (string, error) {
+	encodedKey, err := marshal(key, "", "")
+	if err != nil {
+		return "", err
+	}
+	text, err := e.render(value, indent)
+	if err != nil {
+		return "", fmt.Errorf("render json key %s: %w", key, err)
+	}
+	return encodedKey + separator + text, nil
+}
+
+// render encodes value for a member whose line starts with indent
+func (e *editor) render(value interface{}, indent string) (string, error) {
+	if e.unit == "" {
+		return marshal(value, "", "")
+	}
+	return marshal(value, indent, e.unit)
+}
+
+// multiline reports whether the members of object are on lines of their own
+func (e *editor) multiline(object *node) bool {
+	if len(object.members) == 0 {
+		return e.unit != ""
+	}
+	return bytes.ContainsRune(e.plain[object.start:object.members[0].keyStart], '\n')
+}
+
+// lineIndent returns the leading whitespace of the line holding offset
+func (e *editor) lineIndent(offset int) string {
+	start := e.lineStart(offset)
+	end := start
+	for end < len(e.src) && (e.src[end] == ' ' || e.src[end] == '\t') {
+		end++
+	}
+	return string(e.src[start:end])
+}
+
+// lineStart returns the offset of the line holding offset
+func (e *editor) lineStart(offset int) int {
+	return bytes.LastIndexByte(e.src[:offset], '\n') + 1
+}
+
+// lineEnd returns the offset of the newline ending the line holding offset
+func (e *editor) lineEnd(offset int) int {
+	if newline := bytes.IndexByte(e.src[offset:], '\n'); newline >= 0 {
+		return offset + newline
+	}
+	return len(e.src)
+}
+
+// blank reports whether [start, end) holds only whitespace and comments
+func (e *editor) blank(start, end int) bool {
+	return len(bytes.TrimSpace(e.plain[start:end])) == 0
+}
+
+// detectIndent returns the indentation unit of the document: the leading whitespace
+// of the first member of the root object, or empty when it shares the line of {
+func detectIndent(plain []byte, root *node) string {
+	if len(root.members) == 0 {
+		return "  "
+	}
+	first := root.members[0].keyStart
+	if !bytes.ContainsRune(plain[root.start:first], '\n') {
+		return ""
+	}
+	lineStart := bytes.LastIndexByte(plain[:first], '\n') + 1
+	rootIndent := bytes.LastIndexByte(plain[:root.start], '\n') + 1
+	indent := string(plain[lineStart:first])
+	return strings.TrimPrefix(indent, string(plain[rootIndent:root.start]))
+}
+
+// marshal encodes value without HTML escaping, indented with prefix and unit when set
+func marshal(value interface{}, prefix, unit string) (string, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if unit != "" {
+		encoder.SetIndent(prefix, unit)
+	}
+	if err := encoder.Encode(value); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// equalValues reports whether two decoded values encode to the same JSON,
+// which ignores differences between numeric types
+func equalValues(a, b interface{}) bool {
+	left, err := marshal(a, "", "")
+	if err != nil {
+		return false
+	}
+	right, err := marshal(b, "", "")
+	if err != nil {
+		return false
+	}
+	return left == right
+}
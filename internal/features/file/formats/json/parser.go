@@ -1,31 +1,106 @@
 package jsonformat
 
 import (
+	"bytes"
 	"io"
 
 	"github.com/goccy/go-json"
 	"github.com/thedataflows/confedit/internal/features/file/formats"
+	"github.com/thedataflows/confedit/internal/utils"
 )
 
-// Parser implements the formats.Parser interface for JSON files
-type Parser struct{}
+// Parser implements the formats.Parser interface for JSON files.
+// It remembers the last unmarshaled document so that Marshal only rewrites the
+// members whose values changed, keeping key order, indentation and the trailing newline.
+// In JSONC mode, // and /* */ comments and trailing commas are accepted and kept.
+type Parser struct {
+	jsonc   bool
+	source  []byte
+	plain   []byte // source with comments blanked out
+	root    *node
+	current map[string]interface{} // decoded source, apart from the map Unmarshal returns
+}
 
 // New creates a new JSON parser
 func New() formats.Parser {
 	return &Parser{}
 }
 
+// NewJSONC creates a new parser for JSON with comments and trailing commas
+func NewJSONC() formats.Parser {
+	return &Parser{jsonc: true}
+}
+
 func (p *Parser) Unmarshal(data []byte) (map[string]interface{}, error) {
-	var result map[string]interface{}
-	err := json.Unmarshal(data, &result)
-	return result, err
+	p.Reset()
+	plain := data
+	if p.jsonc {
+		plain = blankComments(data)
+	}
+	if len(bytes.TrimSpace(plain)) == 0 {
+		return make(map[string]interface{}), nil
+	}
+
+	root, err := parseDocument(plain, p.jsonc)
+	if err != nil {
+		return nil, err
+	}
+	strict := blankTrailingCommas(plain, root)
+	result, err := decode(strict)
+	if err != nil {
+		return nil, err
+	}
+
+	current := utils.CopyValue(result).(map[string]interface{})
+	if root.kind != '{' {
+		// A null document has no layout worth keeping
+		return result, nil
+	}
+	p.source = bytes.Clone(data)
+	p.plain = plain
+	p.root = root
+	p.current = current
+
+	return result, nil
 }
 
 func (p *Parser) Marshal(data map[string]interface{}, writer io.Writer) error {
-	encoder := json.NewEncoder(writer)
-	encoder.SetIndent("", "  ")
-	return encoder.Encode(data)
+	if p.source == nil {
+		encoder := json.NewEncoder(writer)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(data)
+	}
+
+	out, err := patchDocument(p.source, p.root, p.plain, p.current, data)
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(out)
+	return err
+}
+
+// Reset implements DocumentParser to forget the previously parsed document
+func (p *Parser) Reset() {
+	p.source = nil
+	p.plain = nil
+	p.root = nil
+	p.current = nil
 }
 
-// Verify that Parser implements the interface at compile time
-var _ formats.Parser = (*Parser)(nil)
+// decode parses data into a map, empty for a null document
+func decode(data []byte) (map[string]interface{}, error) {
+	var result map[string]interface{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+	if result == nil {
+		result = make(map[string]interface{})
+	}
+	return result, nil
+}
+
+// Verify that Parser implements the interfaces at compile time
+var (
+	_ formats.Parser         = (*Parser)(nil)
+	_ formats.DocumentParser = (*Parser)(nil)
+)
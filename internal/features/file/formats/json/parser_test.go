@@ -0,0 +1,166 @@
+package jsonformat
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thedataflows/confedit/internal/features/file/formats"
+	"github.com/thedataflows/confedit/internal/utils"
+)
+
+const settingsFile = `{
+    "editor.fontSize": 14,
+    "editor.rulers": [80, 120],
+    "files.exclude": {
+        "**/.git": true,
+        "**/node_modules": true
+    },
+    "telemetry": "off"
+}
+`
+
+const jsoncFile = `// Editor settings
+{
+	/* appearance */
+	"theme": "dark", // follows the OS otherwise
+	"fontSize": 12,
+	"plugins": {
+		"git": true,
+	},
+}
+`
+
+// patch unmarshals src, merges content into it like the file executor does and
+// returns the marshaled document
+func patch(t *testing.T, parser formats.Parser, src string, content map[string]interface{}) string {
+	t.Helper()
+	current, err := parser.Unmarshal([]byte(src))
+	require.NoError(t, err)
+	require.NoError(t, utils.DeepMerge(current, content))
+
+	var buf bytes.Buffer
+	require.NoError(t, parser.Marshal(current, &buf))
+	return buf.String()
+}
+
+func TestParser_UnchangedDocumentIsIdentical(t *testing.T) {
+	out := patch(t, New(), settingsFile, map[string]interface{}{"telemetry": "off"})
+	assert.Equal(t, settingsFile, out)
+}
+
+func TestParser_ReplacesValuesInPlace(t *testing.T) {
+	out := patch(t, New(), settingsFile, map[string]interface{}{
+		"editor.fontSize": 16,
+		"editor.rulers":   []interface{}{100},
+		"files.exclude":   map[string]interface{}{"**/node_modules": false},
+	})
+
+	expected := replaceOnce(t, settingsFile, `"editor.fontSize": 14`, `"editor.fontSize": 16`)
+	expected = replaceOnce(t, expected, "[80, 120]", "[\n        100\n    ]")
+	expected = replaceOnce(t, expected, `"**/node_modules": true`, `"**/node_modules": false`)
+	assert.Equal(t, expected, out)
+}
+
+func TestParser_AddsKeysWithSiblingIndentation(t *testing.T) {
+	out := patch(t, New(), settingsFile, map[string]interface{}{
+		"files.exclude": map[string]interface{}{"**/dist": true},
+		"window.zoom":   map[string]interface{}{"level": 1},
+	})
+
+	expected := replaceOnce(t, settingsFile, `"**/node_modules": true`, `"**/node_modules": true,
+        "**/dist": true`)
+	expected = replaceOnce(t, expected, `"telemetry": "off"`, `"telemetry": "off",
+    "window.zoom": {
+        "level": 1
+    }`)
+	assert.Equal(t, expected, out)
+}
+
+func TestParser_RemovesKeysMissingFromData(t *testing.T) {
+	parser := New()
+	current, err := parser.Unmarshal([]byte(settingsFile))
+	require.NoError(t, err)
+	delete(current, "editor.rulers")
+	delete(current, "telemetry")
+
+	var buf bytes.Buffer
+	require.NoError(t, parser.Marshal(current, &buf))
+
+	expected := replaceOnce(t, settingsFile, "    \"editor.rulers\": [80, 120],\n", "")
+	expected = replaceOnce(t, expected, "    },\n    \"telemetry\": \"off\"\n", "    }\n")
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestParser_SingleLineDocument(t *testing.T) {
+	parser := New()
+	current, err := parser.Unmarshal([]byte(`{"a": 1, "b": 2, "c": 3}`))
+	require.NoError(t, err)
+	delete(current, "c")
+	current["d"] = "x"
+
+	var buf bytes.Buffer
+	require.NoError(t, parser.Marshal(current, &buf))
+	assert.Equal(t, `{"a": 1, "b": 2, "d": "x"}`, buf.String())
+}
+
+func TestParser_ReplacesEmptyObject(t *testing.T) {
+	out := patch(t, New(), "{\n  \"a\": {}\n}\n", map[string]interface{}{
+		"a": map[string]interface{}{"b": true},
+	})
+	assert.Equal(t, "{\n  \"a\": {\n    \"b\": true\n  }\n}\n", out)
+}
+
+func TestParser_EmptyDocument(t *testing.T) {
+	out := patch(t, New(), "", map[string]interface{}{"key": "value"})
+	assert.Equal(t, "{\n  \"key\": \"value\"\n}\n", out)
+}
+
+func TestParser_RejectsCommentsInStrictMode(t *testing.T) {
+	_, err := New().Unmarshal([]byte(jsoncFile))
+	assert.Error(t, err)
+}
+
+func TestParser_JSONCKeepsCommentsAndTrailingCommas(t *testing.T) {
+	out := patch(t, NewJSONC(), jsoncFile, map[string]interface{}{
+		"theme":   "light",
+		"plugins": map[string]interface{}{"lsp": true},
+	})
+
+	expected := replaceOnce(t, jsoncFile, `"theme": "dark",`, `"theme": "light",`)
+	expected = replaceOnce(t, expected, "\t\t\"git\": true,\n", "\t\t\"git\": true,\n\t\t\"lsp\": true,\n")
+	assert.Equal(t, expected, out)
+}
+
+func TestParser_JSONCRemovesMemberWithItsComment(t *testing.T) {
+	parser := NewJSONC()
+	current, err := parser.Unmarshal([]byte(jsoncFile))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"git": true}, current["plugins"])
+	delete(current, "theme")
+
+	var buf bytes.Buffer
+	require.NoError(t, parser.Marshal(current, &buf))
+
+	expected := replaceOnce(t, jsoncFile, "\t\"theme\": \"dark\", // follows the OS otherwise\n", "")
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestParser_ResetStartsFromScratch(t *testing.T) {
+	parser := New()
+	_, err := parser.Unmarshal([]byte(settingsFile))
+	require.NoError(t, err)
+	parser.(*Parser).Reset()
+
+	var buf bytes.Buffer
+	require.NoError(t, parser.Marshal(map[string]interface{}{"key": "value"}, &buf))
+	assert.Equal(t, "{\n  \"key\": \"value\"\n}\n", buf.String())
+}
+
+// replaceOnce replaces old, which must occur exactly once, with new
+func replaceOnce(t *testing.T, s, old, new string) string {
+	t.Helper()
+	require.Equal(t, 1, bytes.Count([]byte(s), []byte(old)), "expected exactly one %q", old)
+	return string(bytes.Replace([]byte(s), []byte(old), []byte(new), 1))
+}
@@ -0,0 +1,209 @@
+package properties
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// entry is a key/value pair of a properties document with its byte ranges. A
+// logical line may span several physical lines joined by a trailing backslash.
+type entry struct {
+	key        string
+	value      string
+	start      int // offset of the line the entry starts on
+	end        int // offset after the line ending of the entry
+	keyEnd     int // offset after the raw key
+	valueStart int // offset of the raw value
+	valueEnd   int // offset after the raw value, before the line ending
+}
+
+// parseDocument scans the entries of src. Blank lines and lines starting with # or !
+// are comments and belong to no entry.
+func parseDocument(src []byte) ([]entry, error) {
+	var entries []entry
+	for offset := 0; offset < len(src); {
+		i := skipSpace(src, offset)
+		if i == len(src) || isLineEnd(src[i]) || src[i] == '#' || src[i] == '!' {
+			offset = nextLine(src, i)
+			continue
+		}
+
+		e := entry{start: offset}
+		var err error
+		if e.key, e.keyEnd, err = readToken(src, i, isKeyEnd); err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber(src, offset), err)
+		}
+
+		// The separator is whitespace, = or : with optional whitespace around it
+		i = skipSpace(src, e.keyEnd)
+		if i < len(src) && (src[i] == '=' || src[i] == ':') {
+			i = skipSpace(src, i+1)
+		}
+		e.valueStart = i
+		if e.value, e.valueEnd, err = readToken(src, i, nil); err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber(src, offset), err)
+		}
+		e.end = nextLine(src, e.valueEnd)
+		entries = append(entries, e)
+		offset = e.end
+	}
+	return entries, nil
+}
+
+// readToken decodes the escaped text starting at i up to the end of the logical line
+// or an unescaped byte for which stop reports true, and returns the offset after it
+func readToken(src []byte, i int, stop func(c byte) bool) (string, int, error) {
+	var (
+		sb   strings.Builder
+		high rune // pending high surrogate of a \u escape
+	)
+	for i < len(src) && !isLineEnd(src[i]) && (stop == nil || !stop(src[i])) {
+		c := src[i]
+		if c != '\\' {
+			sb.WriteByte(c)
+			i++
+			continue
+		}
+
+		i++
+		if i == len(src) {
+			break
+		}
+		switch src[i] {
+		case '\r', '\n':
+			// Line continuation: leading whitespace of the next line is dropped
+			i = skipSpace(src, nextLine(src, i))
+			continue
+		case 'u':
+			if i+5 > len(src) {
+				return "", i, fmt.Errorf("malformed \\u escape")
+			}
+			code, err := strconv.ParseUint(string(src[i+1:i+5]), 16, 16)
+			if err != nil {
+				return "", i, fmt.Errorf("malformed \\u escape %s", src[i-1:i+5])
+			}
+			i += 5
+			r := rune(code)
+			switch {
+			case utf16.IsSurrogate(r) && high == 0:
+				high = r
+				continue
+			case high != 0:
+				r = utf16.DecodeRune(high, r)
+				high = 0
+			}
+			sb.WriteRune(r)
+			continue
+		case 't':
+			sb.WriteByte('\t')
+		case 'n':
+			sb.WriteByte('\n')
+		case 'r':
+			sb.WriteByte('\r')
+		case 'f':
+			sb.WriteByte('\f')
+		default:
+			sb.WriteByte(src[i])
+		}
+		i++
+	}
+	if high != 0 {
+		sb.WriteRune(utf16.DecodeRune(high, 0))
+	}
+	return sb.String(), i, nil
+}
+
+// escapeKey escapes a key so that it reads back unchanged
+func escapeKey(key string, unicode bool) string {
+	var sb strings.Builder
+	for i, r := range key {
+		switch {
+		case r == ' ' || r == '=' || r == ':':
+			sb.WriteByte('\\')
+			sb.WriteRune(r)
+		case i == 0 && (r == '#' || r == '!'):
+			sb.WriteByte('\\')
+			sb.WriteRune(r)
+		default:
+			escapeRune(&sb, r, unicode)
+		}
+	}
+	return sb.String()
+}
+
+// escapeValue escapes a value so that it reads back unchanged
+func escapeValue(value string, unicode bool) string {
+	var sb strings.Builder
+	for i, r := range value {
+		// Leading spaces would be taken for the separator
+		if i == 0 && r == ' ' {
+			sb.WriteString(`\ `)
+			continue
+		}
+		escapeRune(&sb, r, unicode)
+	}
+	return sb.String()
+}
+
+// escapeRune writes r, escaping backslashes and control characters. Without unicode,
+// non-ASCII characters are written as \uXXXX escapes.
+func escapeRune(sb *strings.Builder, r rune, unicode bool) {
+	switch r {
+	case '\\':
+		sb.WriteString(`\\`)
+	case '\t':
+		sb.WriteString(`\t`)
+	case '\n':
+		sb.WriteString(`\n`)
+	case '\r':
+		sb.WriteString(`\r`)
+	case '\f':
+		sb.WriteString(`\f`)
+	default:
+		if r < 0x80 || unicode {
+			sb.WriteRune(r)
+			return
+		}
+		for _, unit := range utf16.Encode([]rune{r}) {
+			fmt.Fprintf(sb, `\u%04X`, unit)
+		}
+	}
+}
+
+// skipSpace returns the offset of the first byte at or after i that is not a space,
+// tab or form feed
+func skipSpace(src []byte, i int) int {
+	for i < len(src) && (src[i] == ' ' || src[i] == '\t' || src[i] == '\f') {
+		i++
+	}
+	return i
+}
+
+// nextLine returns the offset after the line ending at or after i, or the end of src
+func nextLine(src []byte, i int) int {
+	for i < len(src) && !isLineEnd(src[i]) {
+		i++
+	}
+	if i < len(src) && src[i] == '\r' {
+		i++
+	}
+	if i < len(src) && src[i] == '\n' {
+		i++
+	}
+	return i
+}
+
+// lineNumber returns the 1-based line number of offset
+func lineNumber(src []byte, offset int) int {
+	return strings.Count(string(src[:offset]), "\n") + 1
+}
+
+func isKeyEnd(c byte) bool {
+	return c == '=' || c == ':' || c == ' ' || c == '\t' || c == '\f'
+}
+
+func isLineEnd(c byte) bool {
+	return c == '\r' || c == '\n'
+}
@@ -0,0 +1,152 @@
+package properties
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/thedataflows/confedit/internal/features/file/formats"
+)
+
+// Parser implements the formats.Parser interface for Java .properties files.
+// A document decodes to a flat map of keys to string values; escapes and line
+// continuations are decoded and the last of repeated keys wins.
+// It remembers the last unmarshaled document so that Marshal only rewrites the
+// values that changed, keeping comments, blank lines and separators elsewhere.
+type Parser struct {
+	source  []byte
+	entries []entry
+}
+
+// New creates a new properties parser
+func New() formats.Parser {
+	return &Parser{}
+}
+
+func (p *Parser) Unmarshal(data []byte) (map[string]interface{}, error) {
+	p.Reset()
+	entries, err := parseDocument(data)
+	if err != nil {
+		return nil, err
+	}
+	p.source = bytes.Clone(data)
+	p.entries = entries
+
+	result := make(map[string]interface{}, len(entries))
+	for _, e := range entries {
+		result[e.key] = e.value
+	}
+	return result, nil
+}
+
+func (p *Parser) Marshal(data map[string]interface{}, writer io.Writer) error {
+	out, err := patchDocument(p.source, p.entries, data)
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(out)
+	return err
+}
+
+// Reset implements DocumentParser to forget the previously parsed document
+func (p *Parser) Reset() {
+	p.source = nil
+	p.entries = nil
+}
+
+// patchDocument rewrites src so that it decodes to desired. Changed values are
+// replaced in place, removed keys lose their lines and new keys are appended in
+// key order with the separator of the last entry that has one. Non-ASCII characters are
+// written as \uXXXX escapes unless the document already holds them unescaped.
+func patchDocument(src []byte, entries []entry, desired map[string]interface{}) ([]byte, error) {
+	unicode := !isASCII(src)
+	last := make(map[string]int, len(entries))
+	for i, e := range entries {
+		last[e.key] = i
+	}
+
+	var edits []formats.Edit
+	for i, e := range entries {
+		value, wanted := desired[e.key]
+		if !wanted {
+			edits = append(edits, formats.Edit{Start: e.start, End: e.end})
+			continue
+		}
+		// Earlier occurrences of a repeated key are overridden by the last one
+		if last[e.key] != i {
+			continue
+		}
+		text, err := formatValue(e.key, value)
+		if err != nil {
+			return nil, err
+		}
+		if text != e.value {
+			edits = append(edits, formats.Edit{Start: e.valueStart, End: e.valueEnd, Text: escapeValue(text, unicode)})
+		}
+	}
+
+	// Bare keys have no separator to copy
+	separator, newline := "=", "\n"
+	for _, e := range slices.Backward(entries) {
+		if e.valueStart > e.keyEnd {
+			separator = string(src[e.keyEnd:e.valueStart])
+			break
+		}
+	}
+	if bytes.Contains(src, []byte("\r\n")) {
+		newline = "\r\n"
+	}
+
+	var added strings.Builder
+	for _, key := range slices.Sorted(maps.Keys(desired)) {
+		if _, exists := last[key]; exists {
+			continue
+		}
+		text, err := formatValue(key, desired[key])
+		if err != nil {
+			return nil, err
+		}
+		added.WriteString(escapeKey(key, unicode) + separator + escapeValue(text, unicode) + newline)
+	}
+	if added.Len() > 0 {
+		text := added.String()
+		if len(src) > 0 && !isLineEnd(src[len(src)-1]) {
+			text = newline + text
+		}
+		edits = append(edits, formats.Edit{Start: len(src), End: len(src), Text: text})
+	}
+
+	return formats.Apply(src, edits, false), nil
+}
+
+// formatValue returns the text of a scalar value
+func formatValue(key string, value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case bool, int, int64, float64:
+		return fmt.Sprint(v), nil
+	default:
+		return "", fmt.Errorf("value of properties key %s is not a scalar: %v", key, value)
+	}
+}
+
+// isASCII reports whether src holds only ASCII characters
+func isASCII(src []byte) bool {
+	for _, c := range src {
+		if c >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// Verify that Parser implements the interfaces at compile time
+var (
+	_ formats.Parser         = (*Parser)(nil)
+	_ formats.DocumentParser = (*Parser)(nil)
+)
@@ -0,0 +1,143 @@
+package properties
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thedataflows/confedit/internal/utils"
+)
+
+const serverFile = `# Kafka broker
+! legacy comment style
+broker.id=0
+listeners = PLAINTEXT://:9092
+log.dirs: /var/lib/kafka
+num.partitions 3
+
+# Long value over several lines
+ssl.cipher.suites=TLS_AES_128_GCM_SHA256,\
+    TLS_AES_256_GCM_SHA384
+greeting=Gr\u00fc\u00dfe\ttab
+path\ with\ spaces=C:\\kafka
+`
+
+// patch unmarshals src, merges content into it like the file executor does and
+// returns the marshaled document
+func patch(t *testing.T, src string, content map[string]interface{}) string {
+	t.Helper()
+	parser := New()
+	current, err := parser.Unmarshal([]byte(src))
+	require.NoError(t, err)
+	require.NoError(t, utils.DeepMerge(current, content))
+	utils.RemoveDeleted(current)
+
+	var buf bytes.Buffer
+	require.NoError(t, parser.Marshal(current, &buf))
+
+	// The patched document must decode to the merged content
+	decoded, err := New().Unmarshal(buf.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, current, decoded)
+	return buf.String()
+}
+
+// replaceOnce replaces old, which must occur exactly once, with new
+func replaceOnce(t *testing.T, s, old, new string) string {
+	t.Helper()
+	require.Equal(t, 1, strings.Count(s, old), "expected exactly one %q", old)
+	return strings.Replace(s, old, new, 1)
+}
+
+func TestParser_Unmarshal(t *testing.T) {
+	data, err := New().Unmarshal([]byte(serverFile))
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]interface{}{
+		"broker.id":         "0",
+		"listeners":         "PLAINTEXT://:9092",
+		"log.dirs":          "/var/lib/kafka",
+		"num.partitions":    "3",
+		"ssl.cipher.suites": "TLS_AES_128_GCM_SHA256,TLS_AES_256_GCM_SHA384",
+		"greeting":          "Grüße\ttab",
+		"path with spaces":  `C:\kafka`,
+	}, data)
+}
+
+func TestParser_UnmarshalEdgeCases(t *testing.T) {
+	data, err := New().Unmarshal([]byte("a=1\r\na=2\r\nempty\r\nkey\\:colon = x\\\r\n  y\r\nemoji=\\uD83D\\uDE00\r\n  # indented comment\r\n"))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"a":         "2",
+		"empty":     "",
+		"key:colon": "xy",
+		"emoji":     "😀",
+	}, data)
+
+	_, err = New().Unmarshal([]byte("bad=\\u12G4\n"))
+	assert.Error(t, err)
+}
+
+func TestParser_UnchangedDocumentIsIdentical(t *testing.T) {
+	out := patch(t, serverFile, map[string]interface{}{"num.partitions": "3"})
+	assert.Equal(t, serverFile, out)
+}
+
+func TestParser_ReplacesValuesInPlace(t *testing.T) {
+	out := patch(t, serverFile, map[string]interface{}{
+		"listeners":         "PLAINTEXT://:9093",
+		"log.dirs":          "/data/kafka",
+		"num.partitions":    "6",
+		"ssl.cipher.suites": "TLS_AES_256_GCM_SHA384",
+		"greeting":          "Grüß Gott",
+	})
+
+	expected := replaceOnce(t, serverFile, "listeners = PLAINTEXT://:9092", "listeners = PLAINTEXT://:9093")
+	expected = replaceOnce(t, expected, "log.dirs: /var/lib/kafka", "log.dirs: /data/kafka")
+	expected = replaceOnce(t, expected, "num.partitions 3", "num.partitions 6")
+	expected = replaceOnce(t, expected, "TLS_AES_128_GCM_SHA256,\\\n    TLS_AES_256_GCM_SHA384", "TLS_AES_256_GCM_SHA384")
+	expected = replaceOnce(t, expected, `Gr\u00fc\u00dfe\ttab`, `Gr\u00FC\u00DF Gott`)
+	assert.Equal(t, expected, out)
+}
+
+func TestParser_AddsAndRemovesKeys(t *testing.T) {
+	out := patch(t, serverFile, map[string]interface{}{
+		"broker.id":         map[string]interface{}{"deleted": true},
+		"ssl.cipher.suites": map[string]interface{}{"deleted": true},
+		"zookeeper.connect": "localhost:2181",
+		"auto.create":       "true",
+		"#comment key":      " leading space",
+	})
+
+	expected := replaceOnce(t, serverFile, "broker.id=0\n", "")
+	expected = replaceOnce(t, expected, "ssl.cipher.suites=TLS_AES_128_GCM_SHA256,\\\n    TLS_AES_256_GCM_SHA384\n", "")
+	expected += "\\#comment\\ key=\\ leading space\nauto.create=true\nzookeeper.connect=localhost:2181\n"
+	assert.Equal(t, expected, out)
+}
+
+func TestParser_AddsKeysAfterBareKey(t *testing.T) {
+	assert.Equal(t, "flag\nb=2\n", patch(t, "flag\n", map[string]interface{}{"b": "2"}))
+	assert.Equal(t, "a: 1\nflag\nb: 2\n", patch(t, "a: 1\nflag\n", map[string]interface{}{"b": "2"}))
+}
+
+func TestParser_KeepsLineEndingsAndUnicode(t *testing.T) {
+	out := patch(t, "name=Jürgen\r\n# no trailing newline\r\nport=1", map[string]interface{}{
+		"port": "2",
+		"city": "Köln",
+	})
+	assert.Equal(t, "name=Jürgen\r\n# no trailing newline\r\nport=2\r\ncity=Köln\r\n", out)
+}
+
+func TestParser_MarshalWithoutSource(t *testing.T) {
+	parser := New()
+	var buf bytes.Buffer
+	require.NoError(t, parser.Marshal(map[string]interface{}{
+		"b.key": "two\nlines",
+		"a.key": "ü",
+	}, &buf))
+	assert.Equal(t, "a.key=\\u00FC\nb.key=two\\nlines\n", buf.String())
+
+	assert.Error(t, parser.Marshal(map[string]interface{}{"nested": map[string]interface{}{"key": "value"}}, &buf))
+}
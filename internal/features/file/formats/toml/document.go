@@ -0,0 +1,313 @@
+package toml
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// statement is a key/value expression of a TOML document
+type statement struct {
+	table      []string // path of the enclosing table header
+	key        []string // dotted key, relative to table
+	arrayTable bool     // part of an array of tables, which is managed as a whole
+	start      int      // offset of the line the statement starts on
+	end        int      // offset after the newline ending the statement
+	valueStart int
+	valueEnd   int
+	indent     string
+	separator  string // text between key and value, e.g. " = "
+}
+
+// path returns the full key path of the statement
+func (s *statement) path() []string {
+	return append(append([]string{}, s.table...), s.key...)
+}
+
+// header is a [table] or [[array]] header line
+type header struct {
+	path       []string
+	array      bool
+	arrayTable bool // a [[array]] header or a table nested in an array of tables
+	start      int
+	end        int
+}
+
+// arrayBlock is the byte range of one element of a top-level array of tables,
+// subtables included
+type arrayBlock struct {
+	path  []string
+	start int
+	end   int
+}
+
+// document is the expression layout of a TOML file
+type document struct {
+	src        []byte
+	statements []statement
+	headers    []header
+	arrays     []arrayBlock
+}
+
+// parseDocument scans src, which must be valid TOML, into statements and headers
+func parseDocument(src []byte) (*document, error) {
+	doc := &document{src: src}
+	var table, arrayRoot []string
+	closeArray := func(at int) {
+		if arrayRoot != nil {
+			doc.arrays[len(doc.arrays)-1].end = at
+			arrayRoot = nil
+		}
+	}
+
+	i := 0
+	for i < len(src) {
+		lineStart := i
+		i = skipSpace(src, i)
+		if i >= len(src) {
+			break
+		}
+
+		switch src[i] {
+		case '\n', '\r', '#':
+			i = nextLine(src, i)
+
+		case '[':
+			array := i+1 < len(src) && src[i+1] == '['
+			j := i + 1
+			if array {
+				j++
+			}
+			path, j, err := parseKey(src, j)
+			if err != nil {
+				return nil, err
+			}
+			j = skipSpace(src, j)
+			closing := "]"
+			if array {
+				closing = "]]"
+			}
+			if !bytes.HasPrefix(src[j:], []byte(closing)) {
+				return nil, fmt.Errorf("unterminated table header at offset %d", lineStart)
+			}
+			i = nextLine(src, j+len(closing))
+
+			// Each element of a top-level array of tables is a block of its own,
+			// holding the subtables that follow it
+			if arrayRoot != nil && (!hasPrefix(path, arrayRoot) || (array && len(path) == len(arrayRoot))) {
+				closeArray(lineStart)
+			}
+			if arrayRoot == nil && array {
+				arrayRoot = path
+				doc.arrays = append(doc.arrays, arrayBlock{path: path, start: lineStart})
+			}
+
+			table = path
+			doc.headers = append(doc.headers, header{
+				path:       path,
+				array:      array,
+				arrayTable: arrayRoot != nil,
+				start:      lineStart,
+				end:        i,
+			})
+
+		default:
+			key, j, err := parseKey(src, i)
+			if err != nil {
+				return nil, err
+			}
+			separatorStart := j
+			j = skipSpace(src, j)
+			if j >= len(src) || src[j] != '=' {
+				return nil, fmt.Errorf("expected = after key %s at offset %d", strings.Join(key, "."), i)
+			}
+			j = skipSpace(src, j+1)
+			valueEnd, err := scanValue(src, j)
+			if err != nil {
+				return nil, err
+			}
+			end := nextLine(src, valueEnd)
+			doc.statements = append(doc.statements, statement{
+				table:      table,
+				key:        key,
+				arrayTable: arrayRoot != nil,
+				start:      lineStart,
+				end:        end,
+				valueStart: j,
+				valueEnd:   valueEnd,
+				indent:     string(src[lineStart:i]),
+				separator:  string(src[separatorStart:j]),
+			})
+			i = end
+		}
+	}
+	closeArray(len(src))
+
+	return doc, nil
+}
+
+// parseKey parses a possibly dotted key of bare, basic and literal parts
+func parseKey(src []byte, i int) ([]string, int, error) {
+	var parts []string
+	for {
+		i = skipSpace(src, i)
+		if i >= len(src) {
+			return nil, i, fmt.Errorf("expected key at end of document")
+		}
+
+		switch src[i] {
+		case '"':
+			end, err := scanValue(src, i)
+			if err != nil {
+				return nil, i, err
+			}
+			part, err := strconv.Unquote(string(src[i:end]))
+			if err != nil {
+				return nil, i, fmt.Errorf("invalid quoted key %s: %w", src[i:end], err)
+			}
+			parts = append(parts, part)
+			i = end
+		case '\'':
+			end, err := scanValue(src, i)
+			if err != nil {
+				return nil, i, err
+			}
+			parts = append(parts, string(src[i+1:end-1]))
+			i = end
+		default:
+			start := i
+			for i < len(src) && isBareKeyChar(src[i]) {
+				i++
+			}
+			if i == start {
+				return nil, i, fmt.Errorf("invalid key at offset %d", start)
+			}
+			parts = append(parts, string(src[start:i]))
+		}
+
+		j := skipSpace(src, i)
+		if j < len(src) && src[j] == '.' {
+			i = j + 1
+			continue
+		}
+		return parts, i, nil
+	}
+}
+
+// scanValue returns the offset after the value starting at i
+func scanValue(src []byte, i int) (int, error) {
+	rest := src[i:]
+	switch {
+	case bytes.HasPrefix(rest, []byte(`"""`)), bytes.HasPrefix(rest, []byte(`'''`)):
+		delimiter := rest[:3]
+		for j := 3; j+3 <= len(rest); j++ {
+			if rest[0] == '"' && rest[j] == '\\' {
+				j++
+				continue
+			}
+			if bytes.HasPrefix(rest[j:], delimiter) {
+				end := j + 3
+				// Up to two quotes may directly precede the closing delimiter
+				for k := 0; k < 2 && end < len(rest) && rest[end] == rest[0]; k++ {
+					end++
+				}
+				return i + end, nil
+			}
+		}
+		return 0, fmt.Errorf("unterminated multi-line string at offset %d", i)
+
+	case len(rest) > 0 && (rest[0] == '"' || rest[0] == '\''):
+		for j := 1; j < len(rest) && rest[j] != '\n'; j++ {
+			if rest[0] == '"' && rest[j] == '\\' {
+				j++
+				continue
+			}
+			if rest[j] == rest[0] {
+				return i + j + 1, nil
+			}
+		}
+		return 0, fmt.Errorf("unterminated string at offset %d", i)
+
+	case len(rest) > 0 && (rest[0] == '[' || rest[0] == '{'):
+		depth := 0
+		for j := 0; j < len(rest); j++ {
+			switch rest[j] {
+			case '"', '\'':
+				end, err := scanValue(src, i+j)
+				if err != nil {
+					return 0, err
+				}
+				j = end - i - 1
+			case '#':
+				for j < len(rest) && rest[j] != '\n' {
+					j++
+				}
+			case '[', '{':
+				depth++
+			case ']', '}':
+				depth--
+				if depth == 0 {
+					return i + j + 1, nil
+				}
+			}
+		}
+		return 0, fmt.Errorf("unterminated collection at offset %d", i)
+
+	default:
+		j := bareValueEnd(rest, 0)
+		// Date-times may separate date and time with a space
+		if j == 10 && len(rest) > 13 && rest[4] == '-' && rest[10] == ' ' && isDigit(rest[11]) && isDigit(rest[12]) && rest[13] == ':' {
+			j = bareValueEnd(rest, 11)
+		}
+		if j == 0 {
+			return 0, fmt.Errorf("expected value at offset %d", i)
+		}
+		return i + j, nil
+	}
+}
+
+// bareValueEnd returns the end of an unquoted value (number, boolean, date-time)
+func bareValueEnd(b []byte, i int) int {
+	for i < len(b) && !strings.ContainsRune(" \t\r\n,]}#", rune(b[i])) {
+		i++
+	}
+	return i
+}
+
+// skipSpace skips spaces and tabs
+func skipSpace(src []byte, i int) int {
+	for i < len(src) && (src[i] == ' ' || src[i] == '\t') {
+		i++
+	}
+	return i
+}
+
+// nextLine returns the offset after the newline following i, or the end of src
+func nextLine(src []byte, i int) int {
+	if newline := bytes.IndexByte(src[i:], '\n'); newline >= 0 {
+		return i + newline + 1
+	}
+	return len(src)
+}
+
+func isBareKeyChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || isDigit(c) || c == '_' || c == '-'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// hasPrefix reports whether path starts with prefix
+func hasPrefix(path, prefix []string) bool {
+	if len(prefix) > len(path) {
+		return false
+	}
+	for i := range prefix {
+		if path[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
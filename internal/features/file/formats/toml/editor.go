@@ -0,0 +1,542 @@
+package toml
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"math"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/thedataflows/confedit/internal/features/file/formats"
+)
+
+// editor patches a TOML document in place. Only the key/value expressions whose values
+// change are rewritten; comments, table order, inline tables and array formatting of
+// all other expressions stay byte-identical.
+type editor struct {
+	doc     *document
+	desired map[string]interface{}
+	edits   []formats.Edit
+}
+
+// patchDocument rewrites src so that it decodes to desired. current is the decoded
+// content of src and tells which values actually changed.
+func patchDocument(src []byte, current, desired map[string]interface{}) ([]byte, error) {
+	doc, err := parseDocument(src)
+	if err != nil {
+		return nil, fmt.Errorf("parse toml: %w", err)
+	}
+	e := &editor{doc: doc, desired: desired}
+
+	for i := range doc.statements {
+		if err := e.patchStatement(&doc.statements[i], current, desired); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, h := range doc.headers {
+		if !h.arrayTable && !e.kept(h) {
+			// The blank line separating the table goes with it
+			e.edits = append(e.edits, formats.Edit{Start: blankLineBefore(src, h.start), End: h.end})
+		}
+	}
+
+	// New root keys are queued before rewritten arrays of tables starting at the same offset
+	if err := e.addKeys(nil, desired); err != nil {
+		return nil, err
+	}
+
+	if err := e.patchArrayTables(current, desired); err != nil {
+		return nil, err
+	}
+
+	return formats.Apply(e.doc.src, e.edits, false), nil
+}
+
+// patchStatement removes the statement or rewrites its value when the desired value differs
+func (e *editor) patchStatement(s *statement, current, desired map[string]interface{}) error {
+	if s.arrayTable {
+		return nil
+	}
+
+	path := s.path()
+	value, wanted := lookupOK(desired, path)
+	if !wanted {
+		e.edits = append(e.edits, formats.Edit{Start: s.start, End: s.end})
+		return nil
+	}
+	if equalValues(lookup(current, path), value) {
+		return nil
+	}
+
+	text, err := renderInline(value, e.doc.src[s.valueStart:s.valueEnd])
+	if err != nil {
+		return fmt.Errorf("render toml key %s: %w", strings.Join(path, "."), err)
+	}
+	e.edits = append(e.edits, formats.Edit{Start: s.valueStart, End: s.valueEnd, Text: text})
+	return nil
+}
+
+// patchArrayTables rewrites arrays of tables whose desired elements differ.
+// The new elements replace the first block of the array, the other blocks are removed.
+func (e *editor) patchArrayTables(current, desired map[string]interface{}) error {
+	done := make(map[string]bool)
+	for _, block := range e.doc.arrays {
+		name := strings.Join(block.path, "\x00")
+		value := lookup(desired, block.path)
+		if equalValues(lookup(current, block.path), value) {
+			continue
+		}
+
+		if !done[name] && isArrayOfTables(value) {
+			text, err := renderArrayTables(block.path, value.([]interface{}))
+			if err != nil {
+				return err
+			}
+			if block.end < len(e.doc.src) {
+				text += "\n"
+			}
+			// Inserted before the removal of the block, which starts at the same offset
+			e.edits = append(e.edits, formats.Edit{Start: block.start, End: block.start, Text: text})
+		}
+		done[name] = true
+		e.edits = append(e.edits, formats.Edit{Start: block.start, End: block.end})
+	}
+	return nil
+}
+
+// addKeys inserts the keys of desired missing from the document into table
+func (e *editor) addKeys(table []string, desired map[string]interface{}) error {
+	var leaves, tables []string
+	for _, key := range slices.Sorted(maps.Keys(desired)) {
+		path := append(slices.Clone(table), key)
+		value := desired[key]
+		if e.hasStatement(path) || (isArrayOfTables(value) && e.hasArrayTable(path)) {
+			continue
+		}
+
+		if nested, isMap := value.(map[string]interface{}); isMap && e.isTable(path) {
+			if err := e.addKeys(path, nested); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if _, isMap := value.(map[string]interface{}); isMap || isArrayOfTables(value) {
+			tables = append(tables, key)
+		} else {
+			leaves = append(leaves, key)
+		}
+	}
+
+	offset, prefix, model, found := e.anchor(table)
+	if found && prefix != nil {
+		// Tables defined by dotted keys only take more dotted keys
+		leaves = append(leaves, tables...)
+		slices.Sort(leaves)
+		tables = nil
+	}
+
+	if len(leaves) > 0 {
+		indent, separator := "", " = "
+		if model != nil {
+			indent, separator = model.indent, model.separator
+		}
+		var sb strings.Builder
+		for _, key := range leaves {
+			text, err := renderInline(desired[key], nil)
+			if err != nil {
+				return fmt.Errorf("render toml key %s: %w", key, err)
+			}
+			sb.WriteString(indent + formatKey(append(slices.Clone(prefix), key)) + separator + text + "\n")
+		}
+
+		switch {
+		case found && offset < len(e.doc.src) && len(table) == 0 && model == nil:
+			// Root keys go before the first table
+			e.insert(offset, sb.String()+"\n")
+		case found:
+			e.insert(offset, sb.String())
+		default:
+			e.appendSection("[" + formatKey(table) + "]\n" + sb.String())
+		}
+	}
+
+	for _, key := range tables {
+		path := append(slices.Clone(table), key)
+		text, err := renderTables(path, desired[key])
+		if err != nil {
+			return err
+		}
+		e.appendSection(text)
+	}
+	return nil
+}
+
+// anchor returns where new keys of table go: after the last statement of the table,
+// after its header, or before the first kept table for the root. prefix is the dotted key
+// path leading to table when it is only defined by dotted keys, and model the
+// statement new keys copy their indentation and separator from.
+func (e *editor) anchor(table []string) (int, []string, *statement, bool) {
+	var last *statement
+	for i := range e.doc.statements {
+		s := &e.doc.statements[i]
+		if !s.arrayTable && slices.Equal(s.table, table) {
+			last = s
+		}
+	}
+	if last != nil {
+		return last.end, nil, last, true
+	}
+
+	for _, h := range e.doc.headers {
+		if !h.arrayTable && slices.Equal(h.path, table) {
+			return h.end, nil, nil, true
+		}
+	}
+
+	if len(table) == 0 {
+		for _, h := range e.doc.headers {
+			if e.kept(h) {
+				return h.start, nil, nil, true
+			}
+		}
+		return len(e.doc.src), nil, nil, true
+	}
+
+	for i := len(e.doc.statements) - 1; i >= 0; i-- {
+		s := &e.doc.statements[i]
+		if !s.arrayTable && len(s.table) < len(table) && hasPrefix(s.path(), table) && len(s.path()) > len(table) {
+			return s.end, slices.Clone(table[len(s.table):]), s, true
+		}
+	}
+	return 0, nil, nil, false
+}
+
+// kept reports whether the table, or array of tables, of header h stays in the document
+func (e *editor) kept(h header) bool {
+	if !h.arrayTable {
+		_, isMap := lookup(e.desired, h.path).(map[string]interface{})
+		return isMap
+	}
+	for _, block := range e.doc.arrays {
+		if h.start >= block.start && h.start < block.end {
+			return isArrayOfTables(lookup(e.desired, block.path))
+		}
+	}
+	return true
+}
+
+// hasStatement reports whether a key/value expression sets path
+func (e *editor) hasStatement(path []string) bool {
+	for _, s := range e.doc.statements {
+		if !s.arrayTable && slices.Equal(s.path(), path) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasArrayTable reports whether path is an array of tables in the document
+func (e *editor) hasArrayTable(path []string) bool {
+	for _, block := range e.doc.arrays {
+		if slices.Equal(block.path, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// isTable reports whether path is a table of the document: a header or a prefix of
+// a header or of a dotted key
+func (e *editor) isTable(path []string) bool {
+	for _, h := range e.doc.headers {
+		if hasPrefix(h.path, path) && (!h.arrayTable || len(h.path) > len(path)) {
+			return true
+		}
+	}
+	for _, s := range e.doc.statements {
+		if !s.arrayTable && len(s.path()) > len(path) && hasPrefix(s.path(), path) {
+			return true
+		}
+	}
+	return false
+}
+
+// insert adds text at offset
+func (e *editor) insert(offset int, text string) {
+	if offset > 0 && offset == len(e.doc.src) && e.doc.src[offset-1] != '\n' {
+		text = "\n" + text
+	}
+	e.edits = append(e.edits, formats.Edit{Start: offset, End: offset, Text: text})
+}
+
+// appendSection adds a table section at the end of the document, separated by a blank line
+func (e *editor) appendSection(text string) {
+	if len(bytes.TrimSpace(e.doc.src)) > 0 {
+		text = "\n" + text
+	}
+	e.insert(len(e.doc.src), text)
+}
+
+// blankLineBefore returns the offset of the blank line right before the line at
+// offset, or offset when there is none
+func blankLineBefore(src []byte, offset int) int {
+	if offset == 0 || src[offset-1] != '\n' {
+		return offset
+	}
+	start := bytes.LastIndexByte(src[:offset-1], '\n') + 1
+	if start == 0 || len(bytes.TrimSpace(src[start:offset])) > 0 {
+		return offset
+	}
+	return start
+}
+
+// renderTables renders a new table, or array of tables, with all nested tables
+func renderTables(path []string, value interface{}) (string, error) {
+	if items, ok := value.([]interface{}); ok {
+		return renderArrayTables(path, items)
+	}
+	body, err := renderTableBody(path, value.(map[string]interface{}))
+	if err != nil {
+		return "", err
+	}
+	return "[" + formatKey(path) + "]\n" + body, nil
+}
+
+// renderArrayTables renders one [[path]] section per item
+func renderArrayTables(path []string, items []interface{}) (string, error) {
+	var sb strings.Builder
+	for i, item := range items {
+		body, err := renderTableBody(path, item.(map[string]interface{}))
+		if err != nil {
+			return "", err
+		}
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString("[[" + formatKey(path) + "]]\n" + body)
+	}
+	return sb.String(), nil
+}
+
+// renderTableBody renders the keys of a table followed by its subtables
+func renderTableBody(path []string, table map[string]interface{}) (string, error) {
+	var sb strings.Builder
+	var nested []string
+	for _, key := range slices.Sorted(maps.Keys(table)) {
+		value := table[key]
+		if _, isMap := value.(map[string]interface{}); isMap || isArrayOfTables(value) {
+			nested = append(nested, key)
+			continue
+		}
+		text, err := renderInline(value, nil)
+		if err != nil {
+			return "", fmt.Errorf("render toml key %s: %w", key, err)
+		}
+		sb.WriteString(formatKey([]string{key}) + " = " + text + "\n")
+	}
+
+	for _, key := range nested {
+		text, err := renderTables(append(slices.Clone(path), key), table[key])
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString("\n" + text)
+	}
+	return sb.String(), nil
+}
+
+// renderInline renders value as an inline TOML value. Strings keep the quoting style
+// of old, the value they replace, when it can represent them.
+func renderInline(value interface{}, old []byte) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", fmt.Errorf("toml has no null value")
+	case string:
+		return renderString(v, old), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case float32:
+		return renderFloat(float64(v)), nil
+	case float64:
+		return renderFloat(v), nil
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	case toml.LocalDate, toml.LocalTime, toml.LocalDateTime:
+		return fmt.Sprint(v), nil
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			text, err := renderInline(item, nil)
+			if err != nil {
+				return "", err
+			}
+			items[i] = text
+		}
+		return "[" + strings.Join(items, ", ") + "]", nil
+	case map[string]interface{}:
+		if len(v) == 0 {
+			return "{}", nil
+		}
+		items := make([]string, 0, len(v))
+		for _, key := range slices.Sorted(maps.Keys(v)) {
+			text, err := renderInline(v[key], nil)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, formatKey([]string{key})+" = "+text)
+		}
+		return "{ " + strings.Join(items, ", ") + " }", nil
+	}
+
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return fmt.Sprint(value), nil
+	}
+	return "", fmt.Errorf("unsupported toml value type %T", value)
+}
+
+// renderString quotes s like old when possible: literal strings without escapes,
+// multi-line strings for text with newlines, basic strings otherwise
+func renderString(s string, old []byte) string {
+	multiline := bytes.HasPrefix(old, []byte(`'''`)) || bytes.HasPrefix(old, []byte(`"""`))
+	if multiline && strings.Contains(s, "\n") && !strings.Contains(s, "'''") && !hasControl(s, true) {
+		return "'''\n" + s + "'''"
+	}
+	if len(old) > 0 && old[0] == '\'' && !strings.Contains(s, "'") && !hasControl(s, false) {
+		return "'" + s + "'"
+	}
+
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			sb.WriteString(`\"`)
+		case '\\':
+			sb.WriteString(`\\`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\b':
+			sb.WriteString(`\b`)
+		case '\f':
+			sb.WriteString(`\f`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&sb, `\u%04X`, r)
+			} else {
+				sb.WriteRune(r)
+			}
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+// hasControl reports whether s holds control characters other than tabs (and
+// newlines, when allowed), which literal strings cannot represent
+func hasControl(s string, newlines bool) bool {
+	for _, r := range s {
+		if r == '\t' || (newlines && r == '\n') {
+			continue
+		}
+		if r < 0x20 || r == 0x7f {
+			return true
+		}
+	}
+	return false
+}
+
+// renderFloat renders f so that it reads back as a float
+func renderFloat(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "nan"
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	}
+	text := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(text, ".eEn") {
+		text += ".0"
+	}
+	return text
+}
+
+// formatKey renders a dotted key, quoting the parts that are not bare keys
+func formatKey(path []string) string {
+	parts := make([]string, len(path))
+	for i, part := range path {
+		bare := part != ""
+		for j := 0; j < len(part); j++ {
+			if !isBareKeyChar(part[j]) {
+				bare = false
+				break
+			}
+		}
+		if bare {
+			parts[i] = part
+		} else {
+			parts[i] = renderString(part, nil)
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+// equalValues reports whether two decoded values render to the same TOML,
+// which ignores differences between numeric types
+func equalValues(a, b interface{}) bool {
+	left, leftErr := renderInline(a, nil)
+	right, rightErr := renderInline(b, nil)
+	if leftErr != nil || rightErr != nil {
+		return reflect.DeepEqual(a, b)
+	}
+	return left == right
+}
+
+// isArrayOfTables reports whether value is a non-empty list of tables
+func isArrayOfTables(value interface{}) bool {
+	items, ok := value.([]interface{})
+	if !ok || len(items) == 0 {
+		return false
+	}
+	for _, item := range items {
+		if _, isMap := item.(map[string]interface{}); !isMap {
+			return false
+		}
+	}
+	return true
+}
+
+// lookup returns the value at path, or nil when it does not exist
+func lookup(data map[string]interface{}, path []string) interface{} {
+	value, _ := lookupOK(data, path)
+	return value
+}
+
+// lookupOK returns the value at path and whether it exists
+func lookupOK(data map[string]interface{}, path []string) (interface{}, bool) {
+	var value interface{} = data
+	for _, key := range path {
+		table, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = table[key]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
@@ -1,14 +1,22 @@
 package toml
 
 import (
+	"bytes"
 	"io"
 
 	"github.com/pelletier/go-toml/v2"
 	"github.com/thedataflows/confedit/internal/features/file/formats"
+	"github.com/thedataflows/confedit/internal/utils"
 )
 
-// Parser implements the formats.Parser interface for TOML files
-type Parser struct{}
+// Parser implements the formats.Parser interface for TOML files.
+// It remembers the last unmarshaled document so that Marshal only rewrites the
+// key/value expressions whose values changed, keeping comments, table order,
+// inline tables and array formatting elsewhere.
+type Parser struct {
+	source  []byte
+	current map[string]interface{} // decoded source, apart from the map Unmarshal returns
+}
 
 // New creates a new TOML parser
 func New() formats.Parser {
@@ -21,13 +29,27 @@ func (p *Parser) Unmarshal(data []byte) (map[string]interface{}, error) {
 	if err != nil {
 		return nil, err
 	}
+
+	if result == nil {
+		result = make(map[string]interface{})
+	}
+
+	p.source = bytes.Clone(data)
+	p.current = utils.CopyValue(result).(map[string]interface{})
+
 	return result, nil
 }
 
 func (p *Parser) Marshal(data map[string]interface{}, writer io.Writer) error {
-	// Use the stable API for reliable serialization
-	// Comments will be lost, but the data structure will be preserved correctly
-	encoded, err := toml.Marshal(data)
+	var (
+		encoded []byte
+		err     error
+	)
+	if p.source == nil {
+		encoded, err = toml.Marshal(data)
+	} else {
+		encoded, err = patchDocument(p.source, p.current, data)
+	}
 	if err != nil {
 		return err
 	}
@@ -36,5 +58,14 @@ func (p *Parser) Marshal(data map[string]interface{}, writer io.Writer) error {
 	return err
 }
 
-// Verify that Parser implements the interface at compile time
-var _ formats.Parser = (*Parser)(nil)
+// Reset implements DocumentParser to forget the previously parsed document
+func (p *Parser) Reset() {
+	p.source = nil
+	p.current = nil
+}
+
+// Verify that Parser implements the interfaces at compile time
+var (
+	_ formats.Parser         = (*Parser)(nil)
+	_ formats.DocumentParser = (*Parser)(nil)
+)
@@ -0,0 +1,206 @@
+package toml
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thedataflows/confedit/internal/utils"
+)
+
+const serverFile = `# Server configuration
+title = 'My server' # shown in the UI
+
+[server]
+  host = "0.0.0.0"
+  port = 8080
+  tags = [
+    "web", # public
+    "api",
+  ]
+  limits = { cpu = 2, memory = "1G" }
+
+[[backends]]
+name = "primary"
+url = "http://10.0.0.1"
+
+[[backends]]
+name = "secondary"
+url = "http://10.0.0.2"
+
+[logging]
+level = "info"
+`
+
+// patch unmarshals src, merges content into it like the file executor does and
+// returns the marshaled document
+func patch(t *testing.T, src string, content map[string]interface{}) string {
+	t.Helper()
+	parser := New()
+	current, err := parser.Unmarshal([]byte(src))
+	require.NoError(t, err)
+	require.NoError(t, utils.DeepMerge(current, content))
+
+	var buf bytes.Buffer
+	require.NoError(t, parser.Marshal(current, &buf))
+
+	// The patched document must decode to the merged content
+	decoded, err := New().Unmarshal(buf.Bytes())
+	require.NoError(t, err)
+	assert.True(t, equalValues(current, decoded), "patched document decodes to %v", decoded)
+	return buf.String()
+}
+
+// replaceOnce replaces old, which must occur exactly once, with new
+func replaceOnce(t *testing.T, s, old, new string) string {
+	t.Helper()
+	require.Equal(t, 1, strings.Count(s, old), "expected exactly one %q", old)
+	return strings.Replace(s, old, new, 1)
+}
+
+func TestParser_UnchangedDocumentIsIdentical(t *testing.T) {
+	out := patch(t, serverFile, map[string]interface{}{
+		"server": map[string]interface{}{"port": 8080, "tags": []interface{}{"web", "api"}},
+	})
+	assert.Equal(t, serverFile, out)
+}
+
+func TestParser_ReplacesValuesInPlace(t *testing.T) {
+	out := patch(t, serverFile, map[string]interface{}{
+		"title":   "Edge server",
+		"server":  map[string]interface{}{"port": 9090, "limits": map[string]interface{}{"cpu": 4}},
+		"logging": map[string]interface{}{"level": "debug"},
+	})
+
+	expected := replaceOnce(t, serverFile, "title = 'My server'", "title = 'Edge server'")
+	expected = replaceOnce(t, expected, "port = 8080", "port = 9090")
+	expected = replaceOnce(t, expected, `{ cpu = 2, memory = "1G" }`, `{ cpu = 4, memory = "1G" }`)
+	expected = replaceOnce(t, expected, `level = "info"`, `level = "debug"`)
+	assert.Equal(t, expected, out)
+}
+
+func TestParser_AddsKeysAndTables(t *testing.T) {
+	out := patch(t, serverFile, map[string]interface{}{
+		"debug":   false,
+		"server":  map[string]interface{}{"timeout": "30s"},
+		"logging": map[string]interface{}{"file": map[string]interface{}{"path": "/var/log/app.log"}},
+		"metrics": map[string]interface{}{"enabled": true},
+	})
+
+	expected := replaceOnce(t, serverFile, "title = 'My server' # shown in the UI\n", "title = 'My server' # shown in the UI\ndebug = false\n")
+	expected = replaceOnce(t, expected, "  limits = { cpu = 2, memory = \"1G\" }\n", "  limits = { cpu = 2, memory = \"1G\" }\n  timeout = \"30s\"\n")
+	expected += "\n[logging.file]\npath = \"/var/log/app.log\"\n\n[metrics]\nenabled = true\n"
+	assert.Equal(t, expected, out)
+}
+
+func TestParser_RemovesKeysAndTables(t *testing.T) {
+	parser := New()
+	current, err := parser.Unmarshal([]byte(serverFile))
+	require.NoError(t, err)
+	delete(current, "logging")
+	delete(current["server"].(map[string]interface{}), "tags")
+
+	var buf bytes.Buffer
+	require.NoError(t, parser.Marshal(current, &buf))
+
+	expected := replaceOnce(t, serverFile, "  tags = [\n    \"web\", # public\n    \"api\",\n  ]\n", "")
+	expected = replaceOnce(t, expected, "\n[logging]\nlevel = \"info\"\n", "")
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestParser_RewritesChangedArrayOfTables(t *testing.T) {
+	out := patch(t, serverFile, map[string]interface{}{
+		"backends": []interface{}{
+			map[string]interface{}{"name": "primary", "url": "http://10.0.0.3"},
+		},
+	})
+
+	expected := replaceOnce(t, serverFile,
+		"[[backends]]\nname = \"primary\"\nurl = \"http://10.0.0.1\"\n\n[[backends]]\nname = \"secondary\"\nurl = \"http://10.0.0.2\"\n",
+		"[[backends]]\nname = \"primary\"\nurl = \"http://10.0.0.3\"\n")
+	assert.Equal(t, expected, out)
+}
+
+func TestParser_DottedKeys(t *testing.T) {
+	src := "site.name = \"blog\"\n\n[owner]\nname = \"Tom\"\n"
+	out := patch(t, src, map[string]interface{}{
+		"site": map[string]interface{}{"lang": "en"},
+	})
+	assert.Equal(t, "site.name = \"blog\"\nsite.lang = \"en\"\n\n[owner]\nname = \"Tom\"\n", out)
+}
+
+func TestParser_AtuinConfig(t *testing.T) {
+	original, err := os.ReadFile("../../../../../testdata/atuin/config-original.toml")
+	require.NoError(t, err)
+
+	out := patch(t, string(original), map[string]interface{}{
+		"update_check": true,
+		"show_help":    true,
+		"style":        "compact1",
+	})
+
+	expected := replaceOnce(t, string(original), "update_check = false", "update_check = true")
+	expected = replaceOnce(t, expected, "show_help = false", "show_help = true")
+	expected = replaceOnce(t, expected, `style = "compact"`, `style = "compact1"`)
+	assert.Equal(t, expected, out)
+}
+
+func TestParser_InsertsWhereTablesAreRemoved(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		removed  []string
+		content  map[string]interface{}
+		expected string
+	}{
+		{
+			name:     "key added where the only table is removed",
+			src:      "[t]\nx = 1\n",
+			removed:  []string{"t"},
+			content:  map[string]interface{}{"u": 1},
+			expected: "u = 1\n",
+		},
+		{
+			name:     "emptied array of tables",
+			src:      "a = 1\n[[p]]\nn = 1\n",
+			content:  map[string]interface{}{"p": []interface{}{}},
+			expected: "a = 1\np = []\n",
+		},
+		{
+			name:     "key added where a table after a blank line is removed",
+			src:      "# settings\n\n[t]\nx = 1\n\n[k]\ny = 2\n",
+			removed:  []string{"t"},
+			content:  map[string]interface{}{"u": 1},
+			expected: "# settings\n\nu = 1\n\n[k]\ny = 2\n",
+		},
+		{
+			name:     "key added before a rewritten array of tables",
+			src:      "[[p]]\nn = 1\n",
+			content:  map[string]interface{}{"p": []interface{}{map[string]interface{}{"n": 2}}, "u": 1},
+			expected: "u = 1\n\n[[p]]\nn = 2\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(tb *testing.T) {
+			parser := New()
+			current, err := parser.Unmarshal([]byte(tt.src))
+			require.NoError(tb, err)
+			for _, key := range tt.removed {
+				delete(current, key)
+			}
+			require.NoError(tb, utils.DeepMerge(current, tt.content))
+
+			var buf bytes.Buffer
+			require.NoError(tb, parser.Marshal(current, &buf))
+			assert.Equal(tb, tt.expected, buf.String())
+
+			decoded, err := New().Unmarshal(buf.Bytes())
+			require.NoError(tb, err)
+			assert.True(tb, equalValues(current, decoded), "patched document decodes to %v", decoded)
+		})
+	}
+}
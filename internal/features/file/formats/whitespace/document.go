@@ -0,0 +1,191 @@
+package whitespace
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+
+	"github.com/thedataflows/confedit/internal/features/file/formats/ini/iniparser"
+)
+
+// blockKeywords start a block of keys that lasts until the next block keyword
+var blockKeywords = []string{"match", "host"}
+
+// parseLines splits data into lines. Key lines hold the block they belong to in
+// Section; a block header holds its own block name.
+func parseLines(data []byte) []iniparser.INILine {
+	raw := bytes.Split(bytes.Clone(data), []byte("\n"))
+	if len(raw) > 0 && len(raw[len(raw)-1]) == 0 {
+		raw = raw[:len(raw)-1]
+	}
+
+	lines := make([]iniparser.INILine, 0, len(raw))
+	block := ""
+	for _, r := range raw {
+		line := parseLine(bytes.TrimSuffix(r, []byte("\r")), block)
+		if isHeader(line) {
+			block = blockName(line.Key, line.Value)
+			line.Section = block
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// parseLine parses a "Key value" or "Key=value" line. Comment and blank lines keep
+// their original bytes only.
+func parseLine(raw []byte, block string) iniparser.INILine {
+	line := iniparser.INILine{Original: raw, Section: block}
+	i := skipSpace(raw, 0)
+	if i == len(raw) {
+		line.IsEmpty = true
+		return line
+	}
+	if raw[i] == '#' {
+		line.CommentPrefix = "#"
+		return line
+	}
+	line.Indent = string(raw[:i])
+
+	keyStart := i
+	for i < len(raw) && !isSpace(raw[i]) && raw[i] != '=' {
+		i++
+	}
+	line.Key = string(raw[keyStart:i])
+
+	delimiterStart := i
+	j := skipSpace(raw, i)
+	if j < len(raw) && raw[j] == '=' {
+		j = skipSpace(raw, j+1)
+	}
+	if j == len(raw) {
+		line.Suffix = string(raw[delimiterStart:])
+		return line
+	}
+	line.Delimiter = string(raw[delimiterStart:j])
+
+	end := len(raw)
+	for end > j && isSpace(raw[end-1]) {
+		end--
+	}
+	line.Value = string(raw[j:end])
+	line.Suffix = string(raw[end:])
+	return line
+}
+
+// decode converts lines to a map of global keys and blocks. Keys repeated within
+// a block become a list of values, under the spelling of their first occurrence.
+func decode(lines []iniparser.INILine) map[string]interface{} {
+	result := make(map[string]interface{})
+	for _, line := range lines {
+		if !isKey(line) {
+			continue
+		}
+		if isHeader(line) {
+			if _, exists := result[line.Section]; !exists {
+				result[line.Section] = make(map[string]interface{})
+			}
+			continue
+		}
+
+		target := result
+		if line.Section != "" {
+			target = result[line.Section].(map[string]interface{})
+		}
+		name := findKey(target, line.Key)
+		switch existing := target[name].(type) {
+		case nil:
+			target[line.Key] = line.Value
+		case []interface{}:
+			target[name] = append(existing, line.Value)
+		default:
+			target[name] = []interface{}{existing, line.Value}
+		}
+	}
+	return result
+}
+
+// isKey reports whether line is an active key line
+func isKey(line iniparser.INILine) bool {
+	return line.Key != "" && line.CommentPrefix == ""
+}
+
+// isHeader reports whether line starts a block
+func isHeader(line iniparser.INILine) bool {
+	return isKey(line) && slices.Contains(blockKeywords, strings.ToLower(line.Key))
+}
+
+// isBlockName reports whether a map key names a block, e.g. "Match User deploy"
+func isBlockName(name string) bool {
+	fields := strings.Fields(name)
+	return len(fields) > 0 && slices.Contains(blockKeywords, strings.ToLower(fields[0]))
+}
+
+// blockName returns the map key of the block started by keyword and its criteria
+func blockName(keyword, criteria string) string {
+	if criteria == "" {
+		return keyword
+	}
+	return keyword + " " + strings.Join(strings.Fields(criteria), " ")
+}
+
+// sameBlock reports whether two block names denote the same block, with their
+// whitespace collapsed. Keywords, Match criteria names and Host patterns are
+// case-insensitive; the values of Match criteria are not.
+func sameBlock(a, b string) bool {
+	left, right := strings.Fields(a), strings.Fields(b)
+	if len(left) == 0 || len(left) != len(right) || !strings.EqualFold(left[0], right[0]) {
+		return false
+	}
+	host := strings.EqualFold(left[0], "host")
+	for i := 1; i < len(left); i++ {
+		// Match criteria alternate names and values: "User deploy Address 10.0.0.0/8"
+		if host || i%2 == 1 || len(left) == 2 {
+			if !strings.EqualFold(left[i], right[i]) {
+				return false
+			}
+		} else if left[i] != right[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// findKey returns the key of data equal to key ignoring case, or key when there is none
+func findKey(data map[string]interface{}, key string) string {
+	if _, exists := data[key]; exists {
+		return key
+	}
+	for name := range data {
+		if !isBlockName(name) && strings.EqualFold(name, key) {
+			return name
+		}
+	}
+	return key
+}
+
+// findBlock returns the block of data that is the same block as name, or name with
+// its whitespace collapsed when there is none
+func findBlock(data map[string]interface{}, name string) string {
+	if _, exists := data[name]; exists {
+		return name
+	}
+	for existing := range data {
+		if sameBlock(existing, name) {
+			return existing
+		}
+	}
+	keyword, criteria, _ := strings.Cut(strings.TrimSpace(name), " ")
+	return blockName(keyword, criteria)
+}
+
+func skipSpace(raw []byte, i int) int {
+	for i < len(raw) && isSpace(raw[i]) {
+		i++
+	}
+	return i
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t'
+}
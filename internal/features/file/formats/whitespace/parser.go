@@ -0,0 +1,327 @@
+package whitespace
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/thedataflows/confedit/internal/features/file/formats"
+	"github.com/thedataflows/confedit/internal/features/file/formats/ini/iniparser"
+	"github.com/thedataflows/confedit/internal/utils"
+)
+
+// Parser implements the formats.Parser interface for whitespace-delimited files such
+// as sshd_config, ssh_config and chrony.conf. Lines are "Key value" or "Key=value";
+// keys are case-insensitive and repeated keys decode to a list of values. "Match"
+// and "Host" lines start a block, decoded as a map under the header line, e.g.
+// "Match User deploy", that lasts until the next block.
+// It remembers the lines of the last unmarshaled document so that Marshal only
+// rewrites the values that changed, keeping comments and the layout of other lines.
+type Parser struct {
+	lines  []iniparser.INILine
+	writer *iniparser.RelaxedINIParser
+}
+
+// New creates a new whitespace-delimited parser
+func New() formats.Parser {
+	return &Parser{
+		writer: iniparser.NewRelaxedINIParser(),
+	}
+}
+
+func (p *Parser) Unmarshal(data []byte) (map[string]interface{}, error) {
+	p.lines = parseLines(data)
+	return decode(p.lines), nil
+}
+
+func (p *Parser) Marshal(data map[string]interface{}, writer io.Writer) error {
+	lines, err := patchLines(p.lines, data)
+	if err != nil {
+		return err
+	}
+	return p.writer.Serialize(lines, writer)
+}
+
+// Reset implements DocumentParser to forget the previously parsed document
+func (p *Parser) Reset() {
+	p.lines = nil
+}
+
+// NormalizeContent implements ContentNormalizer: keys and block names of content are
+// respelled like their case-insensitive matches in current, lists of one value
+// become the value and empty lists remove the key
+func (p *Parser) NormalizeContent(current, content map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(content))
+	for key, value := range content {
+		if !isBlockName(key) {
+			result[findKey(current, key)] = normalizeValue(value)
+			continue
+		}
+		name := findBlock(current, key)
+		block, isMap := value.(map[string]interface{})
+		if !isMap || utils.IsDeletedMarker(value) {
+			result[name] = value
+			continue
+		}
+		currentBlock, _ := current[name].(map[string]interface{})
+		result[name] = p.NormalizeContent(currentBlock, block)
+	}
+	return result
+}
+
+// normalizeValue returns value as decoded from the lines it is written to
+func normalizeValue(value interface{}) interface{} {
+	items, isList := value.([]interface{})
+	switch {
+	case !isList:
+		return value
+	case len(items) == 0:
+		return map[string]interface{}{"deleted": true}
+	case len(items) == 1:
+		return items[0]
+	default:
+		return value
+	}
+}
+
+// slot identifies the occurrences of a key within a block
+type slot struct {
+	block string
+	key   string // lower case
+}
+
+// patchLines rewrites lines so that they decode to data. The occurrences of a key
+// take its values in order: extra occurrences are removed and extra values follow
+// the last occurrence. New keys follow the last key of their block with its indentation,
+// new blocks are appended with the first indentation of the file, and blocks missing
+// from data are removed with their lines.
+func patchLines(lines []iniparser.INILine, data map[string]interface{}) ([]iniparser.INILine, error) {
+	// Values are set on a copy: the parsed lines stay as they are in the file
+	lines = slices.Clone(lines)
+	occurrences := make(map[slot][]int)
+	lastKey := make(map[string]int) // last key or header line of each block
+	blockIndent := make(map[string]string)
+	firstHeader := -1
+	indent, delimiter := "", " "
+	for i, line := range lines {
+		if !isKey(line) {
+			continue
+		}
+		lastKey[line.Section] = i
+		if line.Delimiter != "" {
+			delimiter = line.Delimiter
+		}
+		if isHeader(line) {
+			if firstHeader < 0 {
+				firstHeader = i
+			}
+			continue
+		}
+		if line.Section != "" {
+			blockIndent[line.Section] = line.Indent
+			if indent == "" {
+				indent = line.Indent
+			}
+		}
+		s := slot{block: line.Section, key: strings.ToLower(line.Key)}
+		occurrences[s] = append(occurrences[s], i)
+	}
+	if indent == "" {
+		indent = "\t"
+	}
+
+	// Lines inserted before the line at each index, len(lines) for the end
+	inserts := make(map[int][]iniparser.INILine)
+	drop := make(map[int]bool)
+
+	// Blocks missing from data go with all their lines
+	for i, line := range lines {
+		if line.Section == "" {
+			continue
+		}
+		if _, isMap := data[line.Section].(map[string]interface{}); !isMap {
+			drop[i] = true
+		}
+	}
+
+	for s, indexes := range occurrences {
+		if drop[indexes[0]] {
+			continue
+		}
+		values, err := blockValues(data, s.block, s.key)
+		if err != nil {
+			return nil, err
+		}
+		for j, i := range indexes {
+			if j >= len(values) {
+				drop[i] = true
+				continue
+			}
+			lines[i] = setValue(lines[i], values[j], delimiter)
+		}
+		last := lines[indexes[len(indexes)-1]]
+		for _, value := range values[min(len(indexes), len(values)):] {
+			line := iniparser.INILine{Section: s.block, Indent: last.Indent, Key: last.Key}
+			after := indexes[len(indexes)-1] + 1
+			inserts[after] = append(inserts[after], setValue(line, value, last.Delimiter))
+		}
+	}
+
+	// New global keys follow the last global key. Without one they precede the
+	// first block and the comments right above it, as keys after a block belong to it.
+	global, err := newLines(data, "", occurrences, "", delimiter)
+	if err != nil {
+		return nil, err
+	}
+	position := len(lines)
+	if i, exists := lastKey[""]; exists {
+		position = i + 1
+	} else if firstHeader >= 0 {
+		position = firstHeader
+		for position > 0 && lines[position-1].CommentPrefix != "" {
+			position--
+		}
+	}
+	inserts[position] = append(inserts[position], global...)
+
+	for name, i := range lastKey {
+		block, isMap := data[name].(map[string]interface{})
+		if name == "" || !isMap {
+			continue
+		}
+		keysIndent, exists := blockIndent[name]
+		if !exists {
+			keysIndent = indent
+		}
+		keys, err := newLines(block, name, occurrences, keysIndent, delimiter)
+		if err != nil {
+			return nil, err
+		}
+		inserts[i+1] = append(inserts[i+1], keys...)
+	}
+
+	result := make([]iniparser.INILine, 0, len(lines))
+	for i, line := range lines {
+		result = append(result, inserts[i]...)
+		if !drop[i] {
+			result = append(result, line)
+		}
+	}
+	result = append(result, inserts[len(lines)]...)
+	// A block removed from the end of the file leaves no blank lines behind
+	if len(lines) > 0 && drop[len(lines)-1] {
+		for len(result) > 0 && result[len(result)-1].IsEmpty {
+			result = result[:len(result)-1]
+		}
+	}
+
+	// New blocks are appended, separated by a blank line
+	for _, name := range slices.Sorted(maps.Keys(data)) {
+		if !isBlockName(name) {
+			continue
+		}
+		block, ok := data[name].(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("block %s is not a map", name)
+		}
+		if _, exists := lastKey[name]; exists {
+			continue
+		}
+		fields := strings.Fields(name)
+		header := setValue(iniparser.INILine{Section: name, Key: fields[0]}, strings.Join(fields[1:], " "), delimiter)
+		keys, err := newLines(block, name, occurrences, indent, delimiter)
+		if err != nil {
+			return nil, err
+		}
+		if len(result) > 0 && !result[len(result)-1].IsEmpty {
+			result = append(result, iniparser.INILine{IsEmpty: true})
+		}
+		result = append(result, header)
+		result = append(result, keys...)
+	}
+	return result, nil
+}
+
+// newLines returns the lines of the keys of values that the block does not have
+// yet, in key order
+func newLines(values map[string]interface{}, block string, occurrences map[slot][]int, indent, delimiter string) ([]iniparser.INILine, error) {
+	var lines []iniparser.INILine
+	for _, key := range slices.Sorted(maps.Keys(values)) {
+		if isBlockName(key) {
+			if block != "" {
+				return nil, fmt.Errorf("block %s cannot be nested in block %s", key, block)
+			}
+			continue
+		}
+		if _, exists := occurrences[slot{block: block, key: strings.ToLower(key)}]; exists {
+			continue
+		}
+		items, err := formatValues(key, values[key])
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			lines = append(lines, setValue(iniparser.INILine{Section: block, Indent: indent, Key: key}, item, delimiter))
+		}
+	}
+	return lines, nil
+}
+
+// blockValues returns the values of key, in lower case, in a block of data, "" for
+// global keys. A missing key has no values.
+func blockValues(data map[string]interface{}, block, key string) ([]string, error) {
+	values := data
+	if block != "" {
+		values = data[block].(map[string]interface{})
+	}
+	for name, value := range values {
+		if strings.ToLower(name) == key && !isBlockName(name) {
+			return formatValues(name, value)
+		}
+	}
+	return nil, nil
+}
+
+// setValue returns line with value. A line that had no value gets delimiter.
+func setValue(line iniparser.INILine, value, delimiter string) iniparser.INILine {
+	if line.Value == value {
+		return line
+	}
+	line.Value = value
+	line.Suffix = ""
+	if value == "" {
+		line.Delimiter = ""
+	} else if line.Delimiter == "" {
+		line.Delimiter = delimiter
+	}
+	return line
+}
+
+// formatValues returns the text of each value of a key: a scalar or a list of scalars
+func formatValues(key string, value interface{}) ([]string, error) {
+	items, isList := value.([]interface{})
+	if !isList {
+		items = []interface{}{value}
+	}
+	result := make([]string, 0, len(items))
+	for _, item := range items {
+		switch v := item.(type) {
+		case string:
+			result = append(result, v)
+		case bool, int, int64, float64:
+			result = append(result, fmt.Sprint(v))
+		default:
+			return nil, fmt.Errorf("value of key %s is not a scalar or a list of scalars: %v", key, value)
+		}
+	}
+	return result, nil
+}
+
+// Verify that Parser implements the interfaces at compile time
+var (
+	_ formats.Parser            = (*Parser)(nil)
+	_ formats.DocumentParser    = (*Parser)(nil)
+	_ formats.ContentNormalizer = (*Parser)(nil)
+)
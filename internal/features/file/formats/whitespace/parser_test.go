@@ -0,0 +1,173 @@
+package whitespace
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thedataflows/confedit/internal/utils"
+)
+
+const sshdFile = `# Hardened sshd configuration
+Port 22
+HostKey /etc/ssh/ssh_host_ed25519_key
+HostKey /etc/ssh/ssh_host_rsa_key
+PermitRootLogin=yes
+#PasswordAuthentication yes
+Subsystem	sftp	/usr/lib/openssh/sftp-server
+
+# Restrict the backup user
+Match User backup
+	ForceCommand internal-sftp
+	X11Forwarding no
+
+Match Address 10.0.0.0/8
+	PasswordAuthentication yes
+`
+
+// patch unmarshals src, merges content into it like the file executor does and
+// returns the marshaled document
+func patch(t *testing.T, src string, content map[string]interface{}) string {
+	t.Helper()
+	parser := New().(*Parser)
+	current, err := parser.Unmarshal([]byte(src))
+	require.NoError(t, err)
+	require.NoError(t, utils.DeepMerge(current, parser.NormalizeContent(current, content)))
+	utils.RemoveDeleted(current)
+
+	var buf bytes.Buffer
+	require.NoError(t, parser.Marshal(current, &buf))
+
+	// The patched document must decode to the merged content
+	decoded, err := New().Unmarshal(buf.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, current, decoded)
+	return buf.String()
+}
+
+// replaceOnce replaces old, which must occur exactly once, with new
+func replaceOnce(t *testing.T, s, old, new string) string {
+	t.Helper()
+	require.Equal(t, 1, strings.Count(s, old), "expected exactly one %q", old)
+	return strings.Replace(s, old, new, 1)
+}
+
+func TestParser_Unmarshal(t *testing.T) {
+	data, err := New().Unmarshal([]byte(sshdFile))
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]interface{}{
+		"Port":            "22",
+		"HostKey":         []interface{}{"/etc/ssh/ssh_host_ed25519_key", "/etc/ssh/ssh_host_rsa_key"},
+		"PermitRootLogin": "yes",
+		"Subsystem":       "sftp\t/usr/lib/openssh/sftp-server",
+		"Match User backup": map[string]interface{}{
+			"ForceCommand":  "internal-sftp",
+			"X11Forwarding": "no",
+		},
+		"Match Address 10.0.0.0/8": map[string]interface{}{
+			"PasswordAuthentication": "yes",
+		},
+	}, data)
+}
+
+func TestParser_RepeatedKeysIgnoreCase(t *testing.T) {
+	data, err := New().Unmarshal([]byte("AcceptEnv LANG\nacceptenv LC_*\nHost  *.example.com   \n  User admin\n"))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"AcceptEnv":          []interface{}{"LANG", "LC_*"},
+		"Host *.example.com": map[string]interface{}{"User": "admin"},
+	}, data)
+}
+
+func TestParser_UnchangedDocumentIsIdentical(t *testing.T) {
+	out := patch(t, sshdFile, map[string]interface{}{"port": "22"})
+	assert.Equal(t, sshdFile, out)
+}
+
+func TestParser_ReplacesValuesIgnoringCase(t *testing.T) {
+	out := patch(t, sshdFile, map[string]interface{}{
+		"permitrootlogin": "no",
+		"PORT":            "2222",
+		"match user backup": map[string]interface{}{
+			"x11forwarding": "yes",
+		},
+	})
+
+	expected := replaceOnce(t, sshdFile, "PermitRootLogin=yes", "PermitRootLogin=no")
+	expected = replaceOnce(t, expected, "Port 22\n", "Port 2222\n")
+	expected = replaceOnce(t, expected, "X11Forwarding no", "X11Forwarding yes")
+	assert.Equal(t, expected, out)
+}
+
+func TestParser_MultiValuedKeys(t *testing.T) {
+	out := patch(t, sshdFile, map[string]interface{}{
+		"HostKey":   []interface{}{"/etc/ssh/ssh_host_ed25519_key"},
+		"AcceptEnv": []interface{}{"LANG", "LC_*"},
+		"Match Address 10.0.0.0/8": map[string]interface{}{
+			"PasswordAuthentication": []interface{}{"no"},
+			"AllowUsers":             []interface{}{"alice", "bob"},
+		},
+	})
+
+	expected := replaceOnce(t, sshdFile, "HostKey /etc/ssh/ssh_host_rsa_key\n", "")
+	expected = replaceOnce(t, expected, "Subsystem\tsftp\t/usr/lib/openssh/sftp-server\n",
+		"Subsystem\tsftp\t/usr/lib/openssh/sftp-server\nAcceptEnv LANG\nAcceptEnv LC_*\n")
+	expected = replaceOnce(t, expected, "\tPasswordAuthentication yes\n",
+		"\tPasswordAuthentication no\n\tAllowUsers alice\n\tAllowUsers bob\n")
+	assert.Equal(t, expected, out)
+
+	out = patch(t, "HostKey a\nPort 22\n", map[string]interface{}{"HostKey": []interface{}{"a", "b", "c"}})
+	assert.Equal(t, "HostKey a\nHostKey b\nHostKey c\nPort 22\n", out)
+}
+
+func TestParser_AddsAndRemovesBlocks(t *testing.T) {
+	out := patch(t, sshdFile, map[string]interface{}{
+		"Match Address 10.0.0.0/8": map[string]interface{}{"deleted": true},
+		"Match User backup":        map[string]interface{}{"X11Forwarding": map[string]interface{}{"deleted": true}},
+		"Match Group  admins": map[string]interface{}{
+			"AllowTcpForwarding": "yes",
+			"PermitTTY":          "yes",
+		},
+	})
+
+	expected := replaceOnce(t, sshdFile, "\tX11Forwarding no\n", "")
+	expected = replaceOnce(t, expected, "\nMatch Address 10.0.0.0/8\n\tPasswordAuthentication yes\n", "")
+	expected += "\nMatch Group admins\n\tAllowTcpForwarding yes\n\tPermitTTY yes\n"
+	assert.Equal(t, expected, out)
+}
+
+func TestParser_NewKeysFollowBlockIndentation(t *testing.T) {
+	src := "Host tabs\n\tUser a\n\nHost spaces\n  User b\n\nHost empty\n"
+	out := patch(t, src, map[string]interface{}{
+		"Host tabs":   map[string]interface{}{"Port": "22"},
+		"Host spaces": map[string]interface{}{"Port": "2222"},
+		"Host empty":  map[string]interface{}{"Port": "22"},
+		"Host new":    map[string]interface{}{"Port": "22"},
+	})
+	assert.Equal(t, "Host tabs\n\tUser a\n\tPort 22\n\nHost spaces\n  User b\n  Port 2222\n\nHost empty\n\tPort 22\n\nHost new\n\tPort 22\n", out)
+}
+
+func TestParser_NewGlobalKeysPrecedeBlocks(t *testing.T) {
+	src := "# Defaults\n\n# Admin access\nMatch Group admins\n    AllowTcpForwarding yes\n"
+	out := patch(t, src, map[string]interface{}{"MaxAuthTries": "3"})
+	assert.Equal(t, "# Defaults\n\nMaxAuthTries 3\n# Admin access\nMatch Group admins\n    AllowTcpForwarding yes\n", out)
+}
+
+func TestParser_MarshalWithoutSource(t *testing.T) {
+	parser := New()
+	var buf bytes.Buffer
+	require.NoError(t, parser.Marshal(map[string]interface{}{
+		"server":       []interface{}{"0.pool.ntp.org iburst", "1.pool.ntp.org iburst"},
+		"driftfile":    "/var/lib/chrony/drift",
+		"Host bastion": map[string]interface{}{"User": "ops"},
+	}, &buf))
+	assert.Equal(t, "driftfile /var/lib/chrony/drift\nserver 0.pool.ntp.org iburst\nserver 1.pool.ntp.org iburst\n\nHost bastion\n\tUser ops\n", buf.String())
+
+	assert.Error(t, parser.Marshal(map[string]interface{}{"Port": map[string]interface{}{"value": "22"}}, &buf))
+	assert.Error(t, parser.Marshal(map[string]interface{}{
+		"Host a": map[string]interface{}{"Match all": map[string]interface{}{}},
+	}, &buf))
+}
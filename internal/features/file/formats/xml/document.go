@@ -0,0 +1,220 @@
+package xml
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Keys of an element map that are not child elements
+const (
+	attributePrefix = "@"
+	textKey         = "#text"
+)
+
+// element is an XML element with its byte ranges in the document
+type element struct {
+	name        string // qualified name as written, e.g. "mvn:settings"
+	start       int
+	end         int
+	tagEnd      int // offset after the start tag
+	closeStart  int // offset of the end tag, equal to end for self-closing elements
+	selfClosing bool
+	attributes  []attribute
+	children    []*element
+	texts       []segment // character data directly inside the element
+}
+
+// attribute is an attribute of a start tag
+type attribute struct {
+	name       string
+	value      string
+	start      int
+	valueStart int // offset after the opening quote
+	valueEnd   int // offset of the closing quote
+	quote      byte
+}
+
+// segment is a run of character data or a CDATA section
+type segment struct {
+	start int
+	end   int
+	text  string // unescaped character data
+	cdata bool
+}
+
+// parseDocument parses src into its root element, nil for documents without one.
+// Comments, processing instructions and the XML declaration are left to the source.
+func parseDocument(src []byte) (*element, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(src))
+	var (
+		root  *element
+		stack []*element
+	)
+	offset := 0
+	for {
+		token, err := decoder.RawToken()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		start := offset
+		offset = int(decoder.InputOffset())
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			el := &element{name: qualifiedName(t.Name), start: start, tagEnd: offset}
+			if el.attributes, err = scanAttributes(src, start, offset, t.Attr); err != nil {
+				return nil, err
+			}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, el)
+			} else if root != nil {
+				return nil, fmt.Errorf("xml document has more than one root element")
+			} else {
+				root = el
+			}
+			stack = append(stack, el)
+
+		case xml.EndElement:
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("unexpected end element </%s>", qualifiedName(t.Name))
+			}
+			el := stack[len(stack)-1]
+			if name := qualifiedName(t.Name); name != el.name {
+				return nil, fmt.Errorf("element <%s> closed by </%s>", el.name, name)
+			}
+			stack = stack[:len(stack)-1]
+			// The decoder reports self-closing tags as an end element without input
+			el.selfClosing = start == offset
+			el.closeStart = start
+			el.end = offset
+
+		case xml.CharData:
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.texts = append(parent.texts, segment{
+					start: start,
+					end:   offset,
+					text:  string(t),
+					cdata: bytes.HasPrefix(src[start:offset], []byte("<![CDATA[")),
+				})
+			}
+		}
+	}
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("element <%s> is not closed", stack[len(stack)-1].name)
+	}
+	return root, nil
+}
+
+// scanAttributes locates the attributes of the start tag in src[start:end]
+func scanAttributes(src []byte, start, end int, attrs []xml.Attr) ([]attribute, error) {
+	result := make([]attribute, 0, len(attrs))
+	i := start + 1
+	for i < end && !isSpace(src[i]) && src[i] != '/' && src[i] != '>' {
+		i++
+	}
+	for _, attr := range attrs {
+		for i < end && isSpace(src[i]) {
+			i++
+		}
+		a := attribute{name: qualifiedName(attr.Name), value: attr.Value, start: i}
+		for i < end && src[i] != '=' {
+			i++
+		}
+		for i++; i < end && isSpace(src[i]); i++ {
+		}
+		if i >= end || (src[i] != '"' && src[i] != '\'') {
+			return nil, fmt.Errorf("unquoted value of attribute %s at offset %d", a.name, a.start)
+		}
+		a.quote = src[i]
+		a.valueStart = i + 1
+		closing := bytes.IndexByte(src[a.valueStart:end], a.quote)
+		if closing < 0 {
+			return nil, fmt.Errorf("unterminated value of attribute %s at offset %d", a.name, a.start)
+		}
+		a.valueEnd = a.valueStart + closing
+		i = a.valueEnd + 1
+		result = append(result, a)
+	}
+	return result, nil
+}
+
+// decodeElement converts an element to its map representation: the text of elements
+// without attributes and children, otherwise a map of "@attribute" keys, child
+// elements (a list when repeated) and "#text"
+func decodeElement(src []byte, el *element) interface{} {
+	text := el.text()
+	if len(el.attributes) == 0 && len(el.children) == 0 {
+		return text
+	}
+
+	result := make(map[string]interface{}, len(el.attributes)+len(el.children)+1)
+	for _, attr := range el.attributes {
+		result[attributePrefix+attr.name] = attr.value
+	}
+	for _, child := range el.children {
+		value := decodeElement(src, child)
+		switch existing := result[child.name].(type) {
+		case nil:
+			result[child.name] = value
+		case []interface{}:
+			result[child.name] = append(existing, value)
+		default:
+			result[child.name] = []interface{}{existing, value}
+		}
+	}
+	if text != "" {
+		result[textKey] = text
+	}
+	return result
+}
+
+// text returns the character data directly inside the element without the
+// surrounding whitespace
+func (el *element) text() string {
+	var sb strings.Builder
+	for _, s := range el.texts {
+		sb.WriteString(s.text)
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// content returns the trimmed range of the segment, inside the CDATA markers
+func (s segment) content(src []byte) (int, int) {
+	if s.cdata {
+		return s.start + len("<![CDATA["), s.end - len("]]>")
+	}
+	start, end := s.start, s.end
+	for start < end && isSpace(src[start]) {
+		start++
+	}
+	for end > start && isSpace(src[end-1]) {
+		end--
+	}
+	return start, end
+}
+
+// blank reports whether the segment holds only whitespace
+func (s segment) blank() bool {
+	return !s.cdata && strings.TrimSpace(s.text) == ""
+}
+
+// qualifiedName returns the name as written in the document
+func qualifiedName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
@@ -0,0 +1,560 @@
+package xml
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/thedataflows/confedit/internal/features/file/formats"
+)
+
+// header starts documents written from scratch
+const header = `<?xml version="1.0" encoding="UTF-8"?>` + "\n"
+
+// editor patches an XML document in place. Only the attributes, text and elements
+// whose values change are rewritten; the XML declaration, comments, processing
+// instructions and formatting elsewhere are kept.
+type editor struct {
+	src   []byte
+	unit  string // indentation unit of nested elements
+	crlf  bool   // the document ends its lines with \r\n
+	edits []formats.Edit
+}
+
+// patchDocument rewrites src so that it decodes to desired. current is the decoded
+// content of src and tells which values actually changed.
+func patchDocument(src []byte, root *element, current, desired map[string]interface{}) ([]byte, error) {
+	if len(desired) != 1 {
+		return nil, fmt.Errorf("xml document must have exactly one root element, got %d", len(desired))
+	}
+	e := &editor{
+		src:  src,
+		unit: detectIndent(src, root),
+		crlf: bytes.Contains(src, []byte("\r\n")),
+	}
+
+	name := slices.Collect(maps.Keys(desired))[0]
+	switch {
+	case root == nil:
+		// Documents holding only a declaration or comments get the root appended
+		text, err := e.renderElement(name, desired[name], "")
+		if err != nil {
+			return nil, err
+		}
+		if len(src) > 0 && src[len(src)-1] != '\n' {
+			text = "\n" + text
+		}
+		e.edits = append(e.edits, formats.Edit{Start: len(src), End: len(src), Text: text + "\n"})
+
+	case root.name != name:
+		text, err := e.renderElement(name, desired[name], e.lineIndent(root.start))
+		if err != nil {
+			return nil, err
+		}
+		e.edits = append(e.edits, formats.Edit{Start: root.start, End: root.end, Text: text})
+
+	default:
+		currentFields, err := fields(current[name])
+		if err != nil {
+			return nil, err
+		}
+		desiredFields, err := fields(desired[name])
+		if err != nil {
+			return nil, fmt.Errorf("element %s: %w", name, err)
+		}
+		if err := e.patchElement(root, currentFields, desiredFields); err != nil {
+			return nil, err
+		}
+	}
+	return formats.Apply(e.src, e.edits, e.crlf), nil
+}
+
+// patchElement reconciles the attributes, text and children of el with the desired fields
+func (e *editor) patchElement(el *element, current, desired map[string]interface{}) error {
+	indent := e.lineIndent(el.start)
+
+	// Attributes are replaced inside their quotes, new ones follow the last one
+	tagContentEnd := el.start + 1 + len(el.name)
+	existing := make(map[string]bool, len(el.attributes))
+	for _, attr := range el.attributes {
+		existing[attr.name] = true
+		end := attr.valueEnd + 1
+		value, wanted := desired[attributePrefix+attr.name]
+		if !wanted {
+			start := attr.start
+			for start > 0 && isSpace(e.src[start-1]) {
+				start--
+			}
+			e.edits = append(e.edits, formats.Edit{Start: start, End: end})
+			continue
+		}
+		tagContentEnd = end
+
+		text, err := scalar(value)
+		if err != nil {
+			return fmt.Errorf("attribute %s of %s: %w", attr.name, el.name, err)
+		}
+		if text != attr.value {
+			e.edits = append(e.edits, formats.Edit{Start: attr.valueStart, End: attr.valueEnd, Text: escapeAttribute(text, attr.quote)})
+		}
+	}
+	var added strings.Builder
+	for _, key := range slices.Sorted(maps.Keys(desired)) {
+		name, isAttribute := strings.CutPrefix(key, attributePrefix)
+		if !isAttribute || existing[name] {
+			continue
+		}
+		text, err := scalar(desired[key])
+		if err != nil {
+			return fmt.Errorf("attribute %s of %s: %w", name, el.name, err)
+		}
+		fmt.Fprintf(&added, ` %s="%s"`, name, escapeAttribute(text, '"'))
+	}
+	if added.Len() > 0 {
+		e.edits = append(e.edits, formats.Edit{Start: tagContentEnd, End: tagContentEnd, Text: added.String()})
+	}
+
+	if el.selfClosing {
+		content, err := e.renderContent(desired, indent)
+		if err != nil {
+			return fmt.Errorf("element %s: %w", el.name, err)
+		}
+		if content != "" {
+			// Drop the space before "/>" along with it
+			start := tagContentEnd
+			if !e.blank(start, el.end-2) {
+				start = el.end - 2
+			}
+			e.edits = append(e.edits, formats.Edit{Start: start, End: el.end, Text: ">" + content + "</" + el.name + ">"})
+		}
+		return nil
+	}
+
+	if err := e.patchText(el, current, desired); err != nil {
+		return err
+	}
+	return e.patchChildren(el, current, desired, indent)
+}
+
+// patchText replaces the character data of el when its text changes
+func (e *editor) patchText(el *element, current, desired map[string]interface{}) error {
+	have, err := scalar(current[textKey])
+	if err != nil {
+		return err
+	}
+	want, err := scalar(desired[textKey])
+	if err != nil {
+		return fmt.Errorf("text of %s: %w", el.name, err)
+	}
+	if have == want {
+		return nil
+	}
+
+	var segments []segment
+	for _, s := range el.texts {
+		if !s.blank() {
+			segments = append(segments, s)
+		}
+	}
+	if len(segments) == 0 {
+		if len(el.children) == 0 && !hasChildren(desired) && e.blank(el.tagEnd, el.closeStart) {
+			e.edits = append(e.edits, formats.Edit{Start: el.tagEnd, End: el.closeStart, Text: escapeText(want)})
+		} else {
+			e.edits = append(e.edits, formats.Edit{Start: el.tagEnd, End: el.tagEnd, Text: escapeText(want)})
+		}
+		return nil
+	}
+
+	// The first run of text takes the new value, keeping the whitespace around it
+	for i, s := range segments {
+		start, end := s.content(e.src)
+		text := ""
+		if i == 0 {
+			text = escapeText(want)
+			if s.cdata && !strings.Contains(want, "]]>") {
+				text = want
+			} else if s.cdata {
+				start, end = s.start, s.end
+			}
+		}
+		e.edits = append(e.edits, formats.Edit{Start: start, End: end, Text: text})
+	}
+	return nil
+}
+
+// patchChildren reconciles the child elements of el. Repeated elements are matched
+// by position; extra ones are removed or appended after the last one of their name.
+func (e *editor) patchChildren(el *element, current, desired map[string]interface{}, indent string) error {
+	groups := make(map[string][]*element)
+	removed := make(map[*element]bool)
+	var order []string
+	for _, child := range el.children {
+		if _, ok := groups[child.name]; !ok {
+			order = append(order, child.name)
+		}
+		groups[child.name] = append(groups[child.name], child)
+	}
+
+	for _, name := range order {
+		children := groups[name]
+		value, wanted := desired[name]
+		if !wanted {
+			for _, child := range children {
+				e.removeElement(child)
+				removed[child] = true
+			}
+			continue
+		}
+
+		have, want := items(current[name]), items(value)
+		for i, child := range children {
+			if i >= len(want) {
+				e.removeElement(child)
+				removed[child] = true
+				continue
+			}
+			if i < len(have) && equalValues(have[i], want[i]) {
+				continue
+			}
+			var currentFields map[string]interface{}
+			if i < len(have) {
+				currentFields, _ = fields(have[i])
+			}
+			desiredFields, err := fields(want[i])
+			if err != nil {
+				return fmt.Errorf("element %s: %w", name, err)
+			}
+			if err := e.patchElement(child, currentFields, desiredFields); err != nil {
+				return err
+			}
+		}
+
+		if len(want) > len(children) {
+			last := children[len(children)-1]
+			text, err := e.renderSiblings(name, want[len(children):], e.lineIndent(last.start))
+			if err != nil {
+				return err
+			}
+			e.insertAfter(last.end, text)
+		}
+	}
+
+	var added []string
+	for _, key := range slices.Sorted(maps.Keys(desired)) {
+		if _, ok := groups[key]; !ok && isChild(key) {
+			added = append(added, key)
+		}
+	}
+	if len(added) == 0 {
+		return nil
+	}
+
+	// New elements follow the last child that is kept, with its indentation
+	var last *element
+	for _, child := range el.children {
+		if !removed[child] {
+			last = child
+		}
+	}
+	childIndent := indent + e.unit
+	if len(el.children) > 0 {
+		childIndent = e.lineIndent(el.children[len(el.children)-1].start)
+	}
+
+	var sb strings.Builder
+	for _, name := range added {
+		text, err := e.renderSiblings(name, items(desired[name]), childIndent)
+		if err != nil {
+			return err
+		}
+		sb.WriteString(text)
+	}
+	switch {
+	case last != nil:
+		e.insertAfter(last.end, sb.String())
+	case len(el.children) > 0 && el.text() == "", e.blank(el.tagEnd, el.closeStart):
+		// Only removed children or whitespace are left between the tags
+		e.edits = append(e.edits, formats.Edit{Start: el.tagEnd, End: el.closeStart, Text: sb.String() + "\n" + indent})
+	default:
+		e.edits = append(e.edits, formats.Edit{Start: el.closeStart, End: el.closeStart, Text: sb.String() + "\n" + indent})
+	}
+	return nil
+}
+
+// removeElement deletes an element, with its line when it stands on a line of its own
+func (e *editor) removeElement(el *element) {
+	start, end := el.start, el.end
+	lineStart := e.lineStart(start)
+	lineEnd := e.lineEnd(end)
+	if e.blank(lineStart, start) && e.blank(end, lineEnd) {
+		start = lineStart
+		end = min(lineEnd+1, len(e.src))
+	}
+	e.edits = append(e.edits, formats.Edit{Start: start, End: end})
+}
+
+// insertAfter inserts text after an element, at the end of its line when only
+// whitespace follows it
+func (e *editor) insertAfter(offset int, text string) {
+	if lineEnd := e.lineEnd(offset); e.blank(offset, lineEnd) {
+		offset = lineEnd
+		// Keep \r of CRLF line endings before the newline
+		if offset > 0 && e.src[offset-1] == '\r' {
+			offset--
+		}
+	}
+	e.edits = append(e.edits, formats.Edit{Start: offset, End: offset, Text: text})
+}
+
+// renderSiblings renders an element per item, each on a new line starting with indent
+func (e *editor) renderSiblings(name string, values []interface{}, indent string) (string, error) {
+	var sb strings.Builder
+	for _, value := range values {
+		text, err := e.renderElement(name, value, indent)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString("\n" + indent + text)
+	}
+	return sb.String(), nil
+}
+
+// renderElement renders an element whose line starts with indent
+func (e *editor) renderElement(name string, value interface{}, indent string) (string, error) {
+	elementFields, err := fields(value)
+	if err != nil {
+		return "", fmt.Errorf("element %s: %w", name, err)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("<" + name)
+	for _, key := range slices.Sorted(maps.Keys(elementFields)) {
+		attr, isAttribute := strings.CutPrefix(key, attributePrefix)
+		if !isAttribute {
+			continue
+		}
+		text, err := scalar(elementFields[key])
+		if err != nil {
+			return "", fmt.Errorf("attribute %s of %s: %w", attr, name, err)
+		}
+		fmt.Fprintf(&sb, ` %s="%s"`, attr, escapeAttribute(text, '"'))
+	}
+
+	content, err := e.renderContent(elementFields, indent)
+	if err != nil {
+		return "", fmt.Errorf("element %s: %w", name, err)
+	}
+	if content == "" {
+		sb.WriteString("/>")
+	} else {
+		sb.WriteString(">" + content + "</" + name + ">")
+	}
+	return sb.String(), nil
+}
+
+// renderContent renders the text and child elements of an element whose line
+// starts with indent
+func (e *editor) renderContent(elementFields map[string]interface{}, indent string) (string, error) {
+	text, err := scalar(elementFields[textKey])
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	sb.WriteString(escapeText(text))
+	if !hasChildren(elementFields) {
+		return sb.String(), nil
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(elementFields)) {
+		if !isChild(key) {
+			continue
+		}
+		children, err := e.renderSiblings(key, items(elementFields[key]), indent+e.unit)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(children)
+	}
+	sb.WriteString("\n" + indent)
+	return sb.String(), nil
+}
+
+// lineIndent returns the leading whitespace of the line holding offset
+func (e *editor) lineIndent(offset int) string {
+	start := e.lineStart(offset)
+	end := start
+	for end < len(e.src) && (e.src[end] == ' ' || e.src[end] == '\t') {
+		end++
+	}
+	return string(e.src[start:end])
+}
+
+// lineStart returns the offset of the line holding offset
+func (e *editor) lineStart(offset int) int {
+	return bytes.LastIndexByte(e.src[:offset], '\n') + 1
+}
+
+// lineEnd returns the offset of the newline ending the line holding offset
+func (e *editor) lineEnd(offset int) int {
+	if newline := bytes.IndexByte(e.src[offset:], '\n'); newline >= 0 {
+		return offset + newline
+	}
+	return len(e.src)
+}
+
+// blank reports whether [start, end) holds only whitespace
+func (e *editor) blank(start, end int) bool {
+	return len(bytes.TrimSpace(e.src[start:end])) == 0
+}
+
+// detectIndent returns the indentation unit of the document: the difference between
+// the first nested element on a line of its own and its parent, two spaces by default
+func detectIndent(src []byte, root *element) string {
+	queue := []*element{}
+	if root != nil {
+		queue = append(queue, root)
+	}
+	for len(queue) > 0 {
+		parent := queue[0]
+		queue = append(queue[1:], parent.children...)
+		if len(parent.children) == 0 {
+			continue
+		}
+		child := parent.children[0]
+		childLine := bytes.LastIndexByte(src[:child.start], '\n') + 1
+		if childLine <= parent.start || !isIndent(src[childLine:child.start]) {
+			continue
+		}
+		parentLine := bytes.LastIndexByte(src[:parent.start], '\n') + 1
+		if unit, ok := strings.CutPrefix(string(src[childLine:child.start]), string(src[parentLine:parent.start])); ok && unit != "" {
+			return unit
+		}
+	}
+	return "  "
+}
+
+// isIndent reports whether b holds only spaces and tabs
+func isIndent(b []byte) bool {
+	return len(bytes.Trim(b, " \t")) == 0
+}
+
+// fields returns the map form of an element value: scalars become its text
+func fields(value interface{}) (map[string]interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return v, nil
+	case []interface{}:
+		return nil, fmt.Errorf("nested lists are not supported")
+	default:
+		text, err := scalar(v)
+		if err != nil {
+			return nil, err
+		}
+		if text == "" {
+			return map[string]interface{}{}, nil
+		}
+		return map[string]interface{}{textKey: text}, nil
+	}
+}
+
+// items returns the values of a possibly repeated element
+func items(value interface{}) []interface{} {
+	if list, ok := value.([]interface{}); ok {
+		return list
+	}
+	return []interface{}{value}
+}
+
+// hasChildren reports whether the element fields hold child elements
+func hasChildren(elementFields map[string]interface{}) bool {
+	for key := range elementFields {
+		if isChild(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// isChild reports whether an element map key names a child element
+func isChild(key string) bool {
+	return key != textKey && !strings.HasPrefix(key, attributePrefix)
+}
+
+// scalar returns the text of an attribute or text value
+func scalar(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32), nil
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprint(v), nil
+	default:
+		return "", fmt.Errorf("expected a scalar value, got %T", value)
+	}
+}
+
+// normalize turns scalars into their text and single-item lists into the item,
+// matching how values decode from a document
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			result[key] = normalize(item)
+		}
+		if len(result) == 0 {
+			return ""
+		}
+		if text, ok := result[textKey]; ok && len(result) == 1 {
+			return text
+		}
+		return result
+	case []interface{}:
+		if len(v) == 1 {
+			return normalize(v[0])
+		}
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = normalize(item)
+		}
+		return result
+	default:
+		text, _ := scalar(v)
+		return text
+	}
+}
+
+// equalValues reports whether two element values describe the same XML
+func equalValues(a, b interface{}) bool {
+	return reflect.DeepEqual(normalize(a), normalize(b))
+}
+
+var (
+	textEscaper            = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	doubleQuotedAttributes = strings.NewReplacer("&", "&amp;", "<", "&lt;", `"`, "&quot;", "\n", "&#xA;", "\t", "&#x9;")
+	singleQuotedAttributes = strings.NewReplacer("&", "&amp;", "<", "&lt;", "'", "&apos;", "\n", "&#xA;", "\t", "&#x9;")
+)
+
+// escapeText escapes character data
+func escapeText(s string) string {
+	return textEscaper.Replace(s)
+}
+
+// escapeAttribute escapes an attribute value enclosed in quote
+func escapeAttribute(s string, quote byte) string {
+	if quote == '\'' {
+		return singleQuotedAttributes.Replace(s)
+	}
+	return doubleQuotedAttributes.Replace(s)
+}
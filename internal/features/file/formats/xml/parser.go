@@ -1,14 +1,25 @@
 package xml
 
 import (
-	"encoding/xml"
+	"bytes"
 	"io"
 
 	"github.com/thedataflows/confedit/internal/features/file/formats"
+	"github.com/thedataflows/confedit/internal/utils"
 )
 
-// Parser implements the formats.Parser interface for XML files
-type Parser struct{}
+// Parser implements the formats.Parser interface for XML files.
+// A document decodes to a map holding its root element. Elements with neither
+// attributes nor children decode to their text; others to a map of "@name"
+// attributes, child elements (a list when repeated) and "#text". Names keep their
+// namespace prefix as written, e.g. "mvn:settings" and "@xmlns:mvn".
+// It remembers the last unmarshaled document so that Marshal only rewrites what
+// changed, keeping the XML declaration, comments and processing instructions.
+type Parser struct {
+	source  []byte
+	root    *element
+	current map[string]interface{} // decoded source, apart from the map Unmarshal returns
+}
 
 // New creates a new XML parser
 func New() formats.Parser {
@@ -16,20 +27,76 @@ func New() formats.Parser {
 }
 
 func (p *Parser) Unmarshal(data []byte) (map[string]interface{}, error) {
-	var result map[string]interface{}
-	err := xml.Unmarshal(data, &result)
-	return result, err
+	p.Reset()
+	if len(bytes.TrimSpace(data)) == 0 {
+		return make(map[string]interface{}), nil
+	}
+
+	root, err := parseDocument(data)
+	if err != nil {
+		return nil, err
+	}
+	p.source = bytes.Clone(data)
+	p.root = root
+	if root == nil {
+		p.current = make(map[string]interface{})
+		return make(map[string]interface{}), nil
+	}
+
+	result := map[string]interface{}{root.name: decodeElement(data, root)}
+	p.current = utils.CopyValue(result).(map[string]interface{})
+	return result, nil
 }
 
 func (p *Parser) Marshal(data map[string]interface{}, writer io.Writer) error {
-	encoder := xml.NewEncoder(writer)
-	encoder.Indent("", "  ")
-	err := encoder.Encode(data)
+	var (
+		out []byte
+		err error
+	)
+	if p.source == nil {
+		out, err = patchDocument([]byte(header), nil, nil, data)
+	} else {
+		out, err = patchDocument(p.source, p.root, p.current, data)
+	}
 	if err != nil {
 		return err
 	}
-	return encoder.Flush()
+	_, err = writer.Write(out)
+	return err
+}
+
+// NormalizeContent implements ContentNormalizer: an element of content that gets
+// attributes or children while current holds only its text keeps that text as "#text"
+func (p *Parser) NormalizeContent(current, content map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(content))
+	for key, value := range content {
+		element, isMap := value.(map[string]interface{})
+		if !isMap || utils.IsDeletedMarker(value) {
+			result[key] = value
+			continue
+		}
+		currentElement, _ := current[key].(map[string]interface{})
+		element = p.NormalizeContent(currentElement, element)
+		if text, isText := current[key].(string); isText && text != "" {
+			if _, hasText := element[textKey]; !hasText {
+				element[textKey] = text
+			}
+		}
+		result[key] = element
+	}
+	return result
 }
 
-// Verify that Parser implements the interface at compile time
-var _ formats.Parser = (*Parser)(nil)
+// Reset implements DocumentParser to forget the previously parsed document
+func (p *Parser) Reset() {
+	p.source = nil
+	p.root = nil
+	p.current = nil
+}
+
+// Verify that Parser implements the interfaces at compile time
+var (
+	_ formats.Parser            = (*Parser)(nil)
+	_ formats.DocumentParser    = (*Parser)(nil)
+	_ formats.ContentNormalizer = (*Parser)(nil)
+)
@@ -0,0 +1,205 @@
+package xml
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thedataflows/confedit/internal/utils"
+)
+
+const fontsFile = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE fontconfig SYSTEM "urn:fontconfig:fonts.dtd">
+<fontconfig>
+  <!-- Font directories -->
+  <dir>/usr/share/fonts</dir>
+  <dir prefix="xdg">fonts</dir>
+  <match target="font">
+    <edit name="antialias" mode="assign">
+      <bool>true</bool>
+    </edit>
+  </match>
+  <?custom instruction?>
+  <cachedir/>
+</fontconfig>
+`
+
+// patch unmarshals src, merges content into it like the file executor does and
+// returns the marshaled document
+func patch(t *testing.T, src string, content map[string]interface{}) string {
+	t.Helper()
+	parser := New().(*Parser)
+	current, err := parser.Unmarshal([]byte(src))
+	require.NoError(t, err)
+	require.NoError(t, utils.DeepMerge(current, parser.NormalizeContent(current, content)))
+
+	var buf bytes.Buffer
+	require.NoError(t, parser.Marshal(current, &buf))
+	return buf.String()
+}
+
+func TestParser_DecodesElementsAttributesAndText(t *testing.T) {
+	current, err := New().Unmarshal([]byte(fontsFile))
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]interface{}{
+		"fontconfig": map[string]interface{}{
+			"dir": []interface{}{
+				"/usr/share/fonts",
+				map[string]interface{}{"@prefix": "xdg", "#text": "fonts"},
+			},
+			"match": map[string]interface{}{
+				"@target": "font",
+				"edit": map[string]interface{}{
+					"@name": "antialias",
+					"@mode": "assign",
+					"bool":  "true",
+				},
+			},
+			"cachedir": "",
+		},
+	}, current)
+}
+
+func TestParser_UnchangedDocumentIsIdentical(t *testing.T) {
+	out := patch(t, fontsFile, map[string]interface{}{
+		"fontconfig": map[string]interface{}{
+			"match": map[string]interface{}{"@target": "font"},
+		},
+	})
+	assert.Equal(t, fontsFile, out)
+}
+
+func TestParser_ReplacesTextAndAttributesInPlace(t *testing.T) {
+	out := patch(t, fontsFile, map[string]interface{}{
+		"fontconfig": map[string]interface{}{
+			"match": map[string]interface{}{
+				"@target": "pattern",
+				"edit": map[string]interface{}{
+					"@binding": "strong",
+					"bool":     false,
+				},
+			},
+		},
+	})
+
+	expected := replaceOnce(t, fontsFile, `<match target="font">`, `<match target="pattern">`)
+	expected = replaceOnce(t, expected, `mode="assign">`, `mode="assign" binding="strong">`)
+	expected = replaceOnce(t, expected, `<bool>true</bool>`, `<bool>false</bool>`)
+	assert.Equal(t, expected, out)
+}
+
+func TestParser_KeepsTextOfElementGainingAttributes(t *testing.T) {
+	out := patch(t, "<config>\n  <r>hello</r>\n</config>\n", map[string]interface{}{
+		"config": map[string]interface{}{
+			"r": map[string]interface{}{"@id": "5"},
+		},
+	})
+	assert.Equal(t, "<config>\n  <r id=\"5\">hello</r>\n</config>\n", out)
+}
+
+func TestParser_RepeatedElements(t *testing.T) {
+	out := patch(t, fontsFile, map[string]interface{}{
+		"fontconfig": map[string]interface{}{
+			"dir": []interface{}{
+				"/usr/share/fonts",
+				map[string]interface{}{"@prefix": "xdg", "#text": "fonts"},
+				"/opt/fonts",
+			},
+		},
+	})
+
+	expected := replaceOnce(t, fontsFile, "  <dir prefix=\"xdg\">fonts</dir>\n", "  <dir prefix=\"xdg\">fonts</dir>\n  <dir>/opt/fonts</dir>\n")
+	assert.Equal(t, expected, out)
+}
+
+func TestParser_AddsElementsWithSiblingIndentation(t *testing.T) {
+	out := patch(t, fontsFile, map[string]interface{}{
+		"fontconfig": map[string]interface{}{
+			"cachedir": map[string]interface{}{"#text": "~/.cache/fontconfig"},
+			"alias": map[string]interface{}{
+				"@binding": "same",
+				"family":   "Helvetica",
+				"prefer":   map[string]interface{}{"family": "Arial"},
+			},
+		},
+	})
+
+	expected := replaceOnce(t, fontsFile, "  <cachedir/>\n", `  <cachedir>~/.cache/fontconfig</cachedir>
+  <alias binding="same">
+    <family>Helvetica</family>
+    <prefer>
+      <family>Arial</family>
+    </prefer>
+  </alias>
+`)
+	assert.Equal(t, expected, out)
+}
+
+func TestParser_RemovesElementsAndAttributes(t *testing.T) {
+	parser := New()
+	current, err := parser.Unmarshal([]byte(fontsFile))
+	require.NoError(t, err)
+	root := current["fontconfig"].(map[string]interface{})
+	root["dir"] = "/usr/share/fonts"
+	delete(root["match"].(map[string]interface{})["edit"].(map[string]interface{}), "@mode")
+	delete(root, "cachedir")
+
+	var buf bytes.Buffer
+	require.NoError(t, parser.Marshal(current, &buf))
+
+	expected := replaceOnce(t, fontsFile, "  <dir prefix=\"xdg\">fonts</dir>\n", "")
+	expected = replaceOnce(t, expected, ` mode="assign"`, "")
+	expected = replaceOnce(t, expected, "  <cachedir/>\n", "")
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestParser_NamespacesAndEscaping(t *testing.T) {
+	const settings = `<settings xmlns="http://maven.apache.org/SETTINGS/1.2.0" xmlns:xsi='http://www.w3.org/2001/XMLSchema-instance'>
+	<localRepository><![CDATA[/srv/m2]]></localRepository>
+	<xsi:note>a &amp; b</xsi:note>
+</settings>`
+
+	current, err := New().Unmarshal([]byte(settings))
+	require.NoError(t, err)
+	root := current["settings"].(map[string]interface{})
+	assert.Equal(t, "http://www.w3.org/2001/XMLSchema-instance", root["@xmlns:xsi"])
+	assert.Equal(t, "/srv/m2", root["localRepository"])
+	assert.Equal(t, "a & b", root["xsi:note"])
+
+	out := patch(t, settings, map[string]interface{}{
+		"settings": map[string]interface{}{
+			"localRepository": "/data/m2",
+			"xsi:note":        "<c>",
+			"offline":         true,
+		},
+	})
+	expected := replaceOnce(t, settings, "/srv/m2", "/data/m2")
+	expected = replaceOnce(t, expected, "a &amp; b</xsi:note>", "&lt;c&gt;</xsi:note>\n\t<offline>true</offline>")
+	assert.Equal(t, expected, out)
+}
+
+func TestParser_NewDocument(t *testing.T) {
+	out := patch(t, "", map[string]interface{}{
+		"config": map[string]interface{}{"@version": 2, "name": "demo"},
+	})
+	assert.Equal(t, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<config version=\"2\">\n  <name>demo</name>\n</config>\n", out)
+}
+
+func TestParser_RejectsMultipleRoots(t *testing.T) {
+	parser := New()
+	_, err := parser.Unmarshal([]byte(fontsFile))
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	err = parser.Marshal(map[string]interface{}{"a": "", "b": ""}, &buf)
+	assert.Error(t, err)
+}
+
+// replaceOnce replaces old, which must occur exactly once, with new
+func replaceOnce(t *testing.T, s, old, new string) string {
+	t.Helper()
+	require.Equal(t, 1, bytes.Count([]byte(s), []byte(old)), "expected exactly one %q", old)
+	return string(bytes.Replace([]byte(s), []byte(old), []byte(new), 1))
+}
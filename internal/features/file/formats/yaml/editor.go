@@ -0,0 +1,441 @@
+package yaml
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/goccy/go-yaml"
+	"github.com/goccy/go-yaml/ast"
+	"github.com/goccy/go-yaml/parser"
+	"github.com/thedataflows/confedit/internal/features/file/formats"
+)
+
+// editor patches a YAML document in place. Only the entries whose values change are
+// rewritten; comments, key order, quoting and blank lines elsewhere stay byte-identical.
+type editor struct {
+	src        []byte
+	lineStarts []int
+	edits      []formats.Edit
+	indent     int
+	indentSeq  bool
+}
+
+// patchDocument rewrites src so that it decodes to desired. current is the decoded
+// content of src and tells which values actually changed.
+func patchDocument(src []byte, current, desired map[string]interface{}) ([]byte, error) {
+	file, err := parser.ParseBytes(src, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("parse yaml: %w", err)
+	}
+
+	e := &editor{src: src, lineStarts: []int{0}, indent: 2, indentSeq: true}
+	for i, b := range src {
+		if b == '\n' {
+			e.lineStarts = append(e.lineStarts, i+1)
+		}
+	}
+
+	var body ast.Node
+	if len(file.Docs) > 0 {
+		body = file.Docs[0].Body
+	}
+	if _, isComment := body.(*ast.CommentGroupNode); body == nil || isComment {
+		// Nothing but comments yet: the content goes below them
+		if err := e.appendEntries(len(src), "", desired, keysOf(desired)); err != nil {
+			return nil, err
+		}
+		return formats.Apply(e.src, e.edits, false), nil
+	}
+
+	entries, ok := mappingEntries(body)
+	if !ok || len(entries) == 0 {
+		// Flow style documents cannot be patched line by line
+		return yaml.Marshal(desired)
+	}
+
+	e.detectStyle(entries)
+	if err := e.patchMapping(entries, current, desired); err != nil {
+		return nil, err
+	}
+	return formats.Apply(e.src, e.edits, false), nil
+}
+
+// patchMapping reconciles the entries of a block mapping with the desired content
+func (e *editor) patchMapping(entries []*ast.MappingValueNode, current, desired map[string]interface{}) error {
+	seen := make(map[string]bool, len(entries))
+	merges := false
+	for _, entry := range entries {
+		if entry.Key.Type() == ast.MergeKeyType {
+			// Merged keys are decoded into the mapping, so the merge itself stays
+			merges = true
+			continue
+		}
+		key := entry.Key.GetToken().Value
+		seen[key] = true
+
+		value, wanted := desired[key]
+		if !wanted {
+			e.removeEntry(entry)
+			continue
+		}
+		if equalValues(current[key], value) {
+			continue
+		}
+
+		currentMap, currentIsMap := current[key].(map[string]interface{})
+		desiredMap, desiredIsMap := value.(map[string]interface{})
+		if nested, ok := mappingEntries(entry.Value); ok && len(nested) > 0 && currentIsMap && desiredIsMap {
+			if err := e.patchMapping(nested, currentMap, desiredMap); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := e.replaceValue(entry, key, value); err != nil {
+			return err
+		}
+	}
+
+	var added []string
+	for _, key := range keysOf(desired) {
+		if seen[key] {
+			continue
+		}
+		// A key decoded without an entry of its own comes from a merge, and only
+		// needs writing out when it overrides the merged value
+		if merged, ok := current[key]; ok && merges && equalValues(merged, desired[key]) {
+			continue
+		}
+		added = append(added, key)
+	}
+	if len(added) == 0 {
+		return nil
+	}
+
+	last := entries[len(entries)-1]
+	indent := strings.Repeat(" ", last.Key.GetToken().Position.Column-1)
+	return e.appendEntries(e.lineEnd(e.entryLastLine(last))+1, indent, desired, added)
+}
+
+// replaceValue rewrites the value of entry. Single-line values are replaced in place,
+// keeping the key, quoting style and trailing comment; anything else rewrites the entry.
+func (e *editor) replaceValue(entry *ast.MappingValueNode, key string, value interface{}) error {
+	keyLine := entry.Key.GetToken().Position.Line
+	if start, end, ok := e.inlineValue(entry); ok {
+		if text, ok := e.renderInline(value, e.src[start:end]); ok {
+			e.edits = append(e.edits, formats.Edit{Start: start, End: end, Text: text})
+			return nil
+		}
+	}
+
+	indent := strings.Repeat(" ", entry.Key.GetToken().Position.Column-1)
+	text, err := e.renderEntries(indent, map[string]interface{}{key: value}, []string{key})
+	if err != nil {
+		return err
+	}
+	start := e.offset(keyLine, entry.Key.GetToken().Position.Column)
+	end := e.lineEnd(e.entryLastLine(entry))
+	e.edits = append(e.edits, formats.Edit{Start: start, End: end, Text: strings.TrimPrefix(strings.TrimSuffix(text, "\n"), indent)})
+	return nil
+}
+
+// removeEntry deletes the lines of entry, from its key to its last value line
+func (e *editor) removeEntry(entry *ast.MappingValueNode) {
+	start := e.lineStarts[entry.Key.GetToken().Position.Line-1]
+	end := min(e.lineEnd(e.entryLastLine(entry))+1, len(e.src))
+	e.edits = append(e.edits, formats.Edit{Start: start, End: end})
+}
+
+// appendEntries inserts the given keys of values as new entries at offset
+func (e *editor) appendEntries(offset int, indent string, values map[string]interface{}, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	text, err := e.renderEntries(indent, values, keys)
+	if err != nil {
+		return err
+	}
+	offset = min(offset, len(e.src))
+	if offset > 0 && e.src[offset-1] != '\n' {
+		text = "\n" + text
+	}
+	e.edits = append(e.edits, formats.Edit{Start: offset, End: offset, Text: text})
+	return nil
+}
+
+// inlineValue returns the byte range of a value written on the same line as its key
+func (e *editor) inlineValue(entry *ast.MappingValueNode) (int, int, bool) {
+	keyLine := entry.Key.GetToken().Position.Line
+	if e.entryLastLine(entry) != keyLine {
+		return 0, 0, false
+	}
+
+	switch node := entry.Value.(type) {
+	case *ast.StringNode, *ast.IntegerNode, *ast.FloatNode, *ast.BoolNode, *ast.NullNode, *ast.InfinityNode, *ast.NanNode:
+	case *ast.SequenceNode:
+		if !node.IsFlowStyle {
+			return 0, 0, false
+		}
+	case *ast.MappingNode:
+		if !node.IsFlowStyle {
+			return 0, 0, false
+		}
+	default:
+		return 0, 0, false
+	}
+
+	position := entry.Value.GetToken().Position
+	if position.Line != keyLine {
+		return 0, 0, false
+	}
+	start := e.offset(position.Line, position.Column)
+	line := e.src[start:e.lineEnd(keyLine)]
+	if len(line) == 0 {
+		return 0, 0, false
+	}
+
+	var length int
+	switch line[0] {
+	case '\'', '"':
+		length = quotedLength(line)
+	case '[', '{':
+		length = flowLength(line)
+	case '#', '|', '>', '&', '*', '!':
+		return 0, 0, false
+	default:
+		length = len(line)
+		if comment := bytes.Index(line, []byte(" #")); comment >= 0 {
+			length = comment
+		}
+		length = len(bytes.TrimRight(line[:length], " \t"))
+	}
+	if length <= 0 {
+		return 0, 0, false
+	}
+	return start, start + length, true
+}
+
+// renderInline renders value for the place of the old single-line value text.
+// Strings keep the quoting style of the old value and flow collections stay flow.
+func (e *editor) renderInline(value interface{}, old []byte) (string, bool) {
+	if s, ok := value.(string); ok && !strings.ContainsAny(s, "\n\r") {
+		switch old[0] {
+		case '\'':
+			return "'" + strings.ReplaceAll(s, "'", "''") + "'", true
+		case '"':
+			quoted, err := yaml.MarshalWithOptions(s, yaml.JSON())
+			if err != nil {
+				return "", false
+			}
+			return strings.TrimSpace(string(quoted)), true
+		}
+	}
+
+	options := []yaml.EncodeOption{}
+	switch value.(type) {
+	case map[string]interface{}, []interface{}:
+		if old[0] != '[' && old[0] != '{' {
+			return "", false
+		}
+		options = append(options, yaml.Flow(true))
+	}
+	out, err := yaml.MarshalWithOptions(value, options...)
+	if err != nil {
+		return "", false
+	}
+	text := strings.TrimSuffix(string(out), "\n")
+	if strings.Contains(text, "\n") {
+		return "", false
+	}
+	return text, true
+}
+
+// renderEntries renders the given keys of values as block entries prefixed with indent
+func (e *editor) renderEntries(indent string, values map[string]interface{}, keys []string) (string, error) {
+	var sb strings.Builder
+	for _, key := range keys {
+		out, err := yaml.MarshalWithOptions(
+			map[string]interface{}{key: values[key]},
+			yaml.Indent(e.indent),
+			yaml.IndentSequence(e.indentSeq),
+			yaml.UseLiteralStyleIfMultiline(true),
+		)
+		if err != nil {
+			return "", fmt.Errorf("marshal yaml key %s: %w", key, err)
+		}
+		for _, line := range strings.SplitAfter(string(out), "\n") {
+			if line == "" {
+				continue
+			}
+			if line != "\n" {
+				sb.WriteString(indent)
+			}
+			sb.WriteString(line)
+		}
+	}
+	return sb.String(), nil
+}
+
+// entryLastLine returns the last line holding the value of entry: every following
+// line indented deeper than its key, plus block sequence items at the key indentation.
+// Trailing comments and blank lines are left to what follows.
+func (e *editor) entryLastLine(entry *ast.MappingValueNode) int {
+	keyLine := entry.Key.GetToken().Position.Line
+	keyIndent := entry.Key.GetToken().Position.Column - 1
+	sequence, ok := entry.Value.(*ast.SequenceNode)
+	blockSequence := ok && !sequence.IsFlowStyle
+
+	last := keyLine
+	for line := keyLine + 1; line <= len(e.lineStarts); line++ {
+		text := e.src[e.lineStarts[line-1]:e.lineEnd(line)]
+		trimmed := bytes.TrimLeft(text, " ")
+		if len(bytes.TrimSpace(trimmed)) == 0 || trimmed[0] == '#' {
+			continue
+		}
+		indent := len(text) - len(trimmed)
+		if indent > keyIndent || (indent == keyIndent && blockSequence && trimmed[0] == '-' && (len(trimmed) == 1 || trimmed[1] == ' ')) {
+			last = line
+			continue
+		}
+		break
+	}
+	return last
+}
+
+// detectStyle takes the mapping indentation and whether block sequences are indented
+// under their key from the existing document, so new entries look like their neighbors
+func (e *editor) detectStyle(entries []*ast.MappingValueNode) {
+	foundIndent, foundSeq := false, false
+	var walk func([]*ast.MappingValueNode)
+	walk = func(entries []*ast.MappingValueNode) {
+		for _, entry := range entries {
+			if foundIndent && foundSeq {
+				return
+			}
+			column := entry.Key.GetToken().Position.Column
+			switch value := entry.Value.(type) {
+			case *ast.SequenceNode:
+				if !value.IsFlowStyle && !foundSeq {
+					e.indentSeq = value.GetToken().Position.Column > column
+					foundSeq = true
+				}
+			default:
+				if nested, ok := mappingEntries(value); ok && len(nested) > 0 {
+					if !foundIndent {
+						if delta := nested[0].Key.GetToken().Position.Column - column; delta > 0 {
+							e.indent = delta
+							foundIndent = true
+						}
+					}
+					walk(nested)
+				}
+			}
+		}
+	}
+	walk(entries)
+}
+
+// offset converts a 1-based line and rune column into a byte offset
+func (e *editor) offset(line, column int) int {
+	offset := e.lineStarts[line-1]
+	end := e.lineEnd(line)
+	for i := 1; i < column && offset < end; i++ {
+		_, size := utf8.DecodeRune(e.src[offset:end])
+		offset += size
+	}
+	return offset
+}
+
+// lineEnd returns the byte offset of the newline ending line, or the end of the source
+func (e *editor) lineEnd(line int) int {
+	if line < len(e.lineStarts) {
+		return e.lineStarts[line] - 1
+	}
+	return len(e.src)
+}
+
+// mappingEntries returns the entries of a block mapping node
+func mappingEntries(node ast.Node) ([]*ast.MappingValueNode, bool) {
+	switch n := node.(type) {
+	case *ast.MappingNode:
+		if n.IsFlowStyle {
+			return nil, false
+		}
+		return n.Values, true
+	case *ast.MappingValueNode:
+		if n.IsFlowStyle {
+			return nil, false
+		}
+		return []*ast.MappingValueNode{n}, true
+	case *ast.AnchorNode:
+		return mappingEntries(n.Value)
+	case *ast.TagNode:
+		return mappingEntries(n.Value)
+	default:
+		return nil, false
+	}
+}
+
+// quotedLength returns the length of the quoted scalar at the start of line, or 0
+// when it does not end on the same line
+func quotedLength(line []byte) int {
+	quote := line[0]
+	for i := 1; i < len(line); i++ {
+		switch {
+		case quote == '"' && line[i] == '\\':
+			i++
+		case line[i] == quote && quote == '\'' && i+1 < len(line) && line[i+1] == '\'':
+			i++
+		case line[i] == quote:
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// flowLength returns the length of the flow collection at the start of line, or 0
+// when it does not end on the same line
+func flowLength(line []byte) int {
+	depth := 0
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\'', '"':
+			n := quotedLength(line[i:])
+			if n == 0 {
+				return 0
+			}
+			i += n - 1
+		case '[', '{':
+			depth++
+		case ']', '}':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return 0
+}
+
+// equalValues reports whether two decoded values serialize to the same YAML,
+// which ignores differences between numeric types
+func equalValues(a, b interface{}) bool {
+	left, err := yaml.Marshal(a)
+	if err != nil {
+		return false
+	}
+	right, err := yaml.Marshal(b)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(left, right)
+}
+
+// keysOf returns the keys of values in sorted order
+func keysOf(values map[string]interface{}) []string {
+	return slices.Sorted(maps.Keys(values))
+}
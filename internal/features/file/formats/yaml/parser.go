@@ -1,14 +1,21 @@
 package yaml
 
 import (
+	"bytes"
 	"io"
 
 	"github.com/goccy/go-yaml"
 	"github.com/thedataflows/confedit/internal/features/file/formats"
+	"github.com/thedataflows/confedit/internal/utils"
 )
 
-// Parser implements the formats.Parser interface for YAML files
-type Parser struct{}
+// Parser implements the formats.Parser interface for YAML files.
+// It remembers the last unmarshaled document so that Marshal only rewrites the
+// entries whose values changed, keeping comments, key order and quoting elsewhere.
+type Parser struct {
+	source  []byte
+	current map[string]interface{} // decoded source, apart from the map Unmarshal returns
+}
 
 // New creates a new YAML parser
 func New() formats.Parser {
@@ -16,19 +23,54 @@ func New() formats.Parser {
 }
 
 func (p *Parser) Unmarshal(data []byte) (map[string]interface{}, error) {
-	var result map[string]interface{}
-	err := yaml.Unmarshal(data, &result)
-	return result, err
+	result, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	p.source = bytes.Clone(data)
+	p.current = utils.CopyValue(result).(map[string]interface{})
+
+	return result, nil
 }
 
 func (p *Parser) Marshal(data map[string]interface{}, writer io.Writer) error {
-	bytes, err := yaml.Marshal(data)
+	var (
+		out []byte
+		err error
+	)
+	if p.source == nil {
+		out, err = yaml.Marshal(data)
+	} else {
+		out, err = patchDocument(p.source, p.current, data)
+	}
 	if err != nil {
 		return err
 	}
-	_, err = writer.Write(bytes)
+	_, err = writer.Write(out)
 	return err
 }
 
-// Verify that Parser implements the interface at compile time
-var _ formats.Parser = (*Parser)(nil)
+// Reset implements DocumentParser to forget the previously parsed document
+func (p *Parser) Reset() {
+	p.source = nil
+	p.current = nil
+}
+
+// decode parses data into a map, empty for documents without content
+func decode(data []byte) (map[string]interface{}, error) {
+	var result map[string]interface{}
+	if err := yaml.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+	if result == nil {
+		result = make(map[string]interface{})
+	}
+	return result, nil
+}
+
+// Verify that Parser implements the interfaces at compile time
+var (
+	_ formats.Parser         = (*Parser)(nil)
+	_ formats.DocumentParser = (*Parser)(nil)
+)
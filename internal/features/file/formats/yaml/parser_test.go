@@ -0,0 +1,143 @@
+package yaml
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thedataflows/confedit/internal/utils"
+)
+
+const composeFile = `# Compose file for the media stack
+services:
+  web:
+    image: "nginx:1.25" # pinned
+    ports:
+      - "8080:80"
+    environment:
+      TZ: Europe/Berlin
+      PUID: 1000
+
+  # Database, do not expose
+  db:
+    image: postgres:15
+    volumes: [db:/var/lib/postgresql/data]
+
+volumes:
+  db: {}
+`
+
+// patch unmarshals src, merges content into it like the file executor does and
+// returns the marshaled document
+func patch(t *testing.T, src string, content map[string]interface{}) string {
+	t.Helper()
+	parser := New()
+	current, err := parser.Unmarshal([]byte(src))
+	require.NoError(t, err)
+	require.NoError(t, utils.DeepMerge(current, content))
+
+	var buf bytes.Buffer
+	require.NoError(t, parser.Marshal(current, &buf))
+	return buf.String()
+}
+
+func TestParser_UnchangedDocumentIsIdentical(t *testing.T) {
+	out := patch(t, composeFile, map[string]interface{}{
+		"services": map[string]interface{}{
+			"web": map[string]interface{}{"image": "nginx:1.25"},
+		},
+	})
+	assert.Equal(t, composeFile, out)
+}
+
+func TestParser_ReplacesScalarInPlace(t *testing.T) {
+	out := patch(t, composeFile, map[string]interface{}{
+		"services": map[string]interface{}{
+			"web": map[string]interface{}{"image": "nginx:1.27"},
+			"db":  map[string]interface{}{"image": "postgres:16"},
+		},
+	})
+
+	expected := bytes.NewBufferString(composeFile).String()
+	expected = replaceOnce(t, expected, `image: "nginx:1.25" # pinned`, `image: "nginx:1.27" # pinned`)
+	expected = replaceOnce(t, expected, `image: postgres:15`, `image: postgres:16`)
+	assert.Equal(t, expected, out)
+}
+
+func TestParser_AddsKeysWithSiblingIndentation(t *testing.T) {
+	out := patch(t, composeFile, map[string]interface{}{
+		"services": map[string]interface{}{
+			"web": map[string]interface{}{
+				"environment": map[string]interface{}{"LOG_LEVEL": "debug"},
+				"restart":     "unless-stopped",
+			},
+		},
+	})
+
+	expected := replaceOnce(t, composeFile, "      PUID: 1000\n", "      PUID: 1000\n      LOG_LEVEL: debug\n    restart: unless-stopped\n")
+	assert.Equal(t, expected, out)
+}
+
+func TestParser_KeepsMergedKeys(t *testing.T) {
+	src := "base: &base\n  x: 1\nchild:\n  <<: *base\n  y: 2\n"
+
+	out := patch(t, src, map[string]interface{}{
+		"child": map[string]interface{}{"y": 3},
+	})
+	assert.Equal(t, "base: &base\n  x: 1\nchild:\n  <<: *base\n  y: 3\n", out)
+
+	out = patch(t, src, map[string]interface{}{
+		"child": map[string]interface{}{"x": 5},
+	})
+	assert.Equal(t, src+"  x: 5\n", out)
+}
+
+func TestParser_ReplacesCollections(t *testing.T) {
+	out := patch(t, composeFile, map[string]interface{}{
+		"services": map[string]interface{}{
+			"web": map[string]interface{}{"ports": []interface{}{"8080:80", "8443:443"}},
+			"db":  map[string]interface{}{"volumes": []interface{}{"pg:/data"}},
+		},
+	})
+
+	expected := replaceOnce(t, composeFile, "      - \"8080:80\"\n", "      - 8080:80\n      - 8443:443\n")
+	expected = replaceOnce(t, expected, "[db:/var/lib/postgresql/data]", "[pg:/data]")
+	assert.Equal(t, expected, out)
+}
+
+func TestParser_RemovesKeysMissingFromData(t *testing.T) {
+	parser := New()
+	current, err := parser.Unmarshal([]byte(composeFile))
+	require.NoError(t, err)
+	delete(current["services"].(map[string]interface{}), "db")
+
+	var buf bytes.Buffer
+	require.NoError(t, parser.Marshal(current, &buf))
+
+	expected := replaceOnce(t, composeFile, "  db:\n    image: postgres:15\n    volumes: [db:/var/lib/postgresql/data]\n", "")
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestParser_CommentOnlyDocument(t *testing.T) {
+	out := patch(t, "# managed by confedit\n", map[string]interface{}{"key": "value"})
+	assert.Equal(t, "# managed by confedit\nkey: value\n", out)
+}
+
+func TestParser_ResetStartsFromScratch(t *testing.T) {
+	parser := New()
+	_, err := parser.Unmarshal([]byte(composeFile))
+	require.NoError(t, err)
+	parser.(*Parser).Reset()
+
+	var buf bytes.Buffer
+	require.NoError(t, parser.Marshal(map[string]interface{}{"key": "value"}, &buf))
+	assert.Equal(t, "key: value\n", buf.String())
+}
+
+// replaceOnce replaces old, which must occur exactly once, with new
+func replaceOnce(t *testing.T, s, old, new string) string {
+	t.Helper()
+	require.Equal(t, 1, bytes.Count([]byte(s), []byte(old)), "expected exactly one %q", old)
+	return string(bytes.Replace([]byte(s), []byte(old), []byte(new), 1))
+}
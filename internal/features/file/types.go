@@ -2,6 +2,7 @@ package file
 
 import (
 	"fmt"
+	"maps"
 
 	"github.com/thedataflows/confedit/internal/types"
 	"github.com/thedataflows/confedit/internal/utils"
@@ -9,14 +10,18 @@ import (
 
 // Config represents the configuration for a file target
 type Config struct {
-	Path    string                 `json:"path"`
-	Format  string                 `json:"format"` // "ini" | "yaml" | "toml" | "json" | "xml"
-	Owner   string                 `json:"owner,omitempty"`
-	Group   string                 `json:"group,omitempty"`
-	Mode    string                 `json:"mode,omitempty"`
-	Backup  bool                   `json:"backup,omitempty"`
-	Content map[string]interface{} `json:"content"`
-	Options map[string]interface{} `json:"options,omitempty"` // Format-specific options
+	Path            string                      `json:"path"`
+	Format          string                      `json:"format"` // "ini" | "yaml" | "toml" | "json" | "jsonc" | "xml" | "properties" | "env" | "whitespace"
+	Owner           string                      `json:"owner,omitempty"`
+	Group           string                      `json:"group,omitempty"`
+	Mode            string                      `json:"mode,omitempty"`
+	Backup          bool                        `json:"backup,omitempty"`
+	ReplaceSymlinks bool                        `json:"replace_symlinks,omitempty"` // Replace a symlink at Path instead of writing through it
+	Content         map[string]interface{}      `json:"content"`
+	Options         map[string]interface{}      `json:"options,omitempty"`     // Format-specific options
+	Arrays          map[string]utils.ArrayMerge `json:"arrays,omitempty"`      // Merge strategies of lists in Content by dotted key path
+	MergePatch      map[string]interface{}      `json:"merge_patch,omitempty"` // RFC 7386 merge patch applied after Content
+	Patch           []utils.PatchOperation      `json:"patch,omitempty"`       // RFC 6902 operations applied last
 }
 
 // Type implements TargetConfig interface
@@ -35,14 +40,30 @@ func (c *Config) Validate() error {
 
 	// Validate format is supported
 	supportedFormats := map[string]bool{
-		"ini":  true,
-		"yaml": true,
-		"toml": true,
-		"json": true,
-		"xml":  true,
+		"ini":        true,
+		"yaml":       true,
+		"toml":       true,
+		"json":       true,
+		"jsonc":      true,
+		"xml":        true,
+		"properties": true,
+		"env":        true,
+		"whitespace": true,
 	}
 	if !supportedFormats[c.Format] {
-		return fmt.Errorf("unsupported format: %s (supported: ini, yaml, toml, json, xml)", c.Format)
+		return fmt.Errorf("unsupported format: %s (supported: ini, yaml, toml, json, jsonc, xml, properties, env, whitespace)", c.Format)
+	}
+
+	for path, strategy := range c.Arrays {
+		if err := strategy.Validate(); err != nil {
+			return fmt.Errorf("arrays %s: %w", path, err)
+		}
+	}
+
+	for i, op := range c.Patch {
+		if err := op.Validate(); err != nil {
+			return fmt.Errorf("patch operation %d: %w", i, err)
+		}
 	}
 
 	return nil
@@ -98,6 +119,25 @@ func MergeConfig(existing, newTarget *Config) error {
 	if newTarget.Mode != "" {
 		existing.Mode = newTarget.Mode
 	}
+	if newTarget.ReplaceSymlinks {
+		existing.ReplaceSymlinks = true
+	}
+	if newTarget.MergePatch != nil {
+		if existing.MergePatch == nil {
+			existing.MergePatch = make(map[string]interface{})
+		}
+		if err := utils.DeepMerge(existing.MergePatch, newTarget.MergePatch); err != nil {
+			return fmt.Errorf("merge merge_patch: %w", err)
+		}
+	}
+	// Operations depend on the document they run against, so they accumulate in order
+	existing.Patch = append(existing.Patch, newTarget.Patch...)
+	if len(newTarget.Arrays) > 0 {
+		if existing.Arrays == nil {
+			existing.Arrays = make(map[string]utils.ArrayMerge)
+		}
+		maps.Copy(existing.Arrays, newTarget.Arrays)
+	}
 
 	return nil
 }
@@ -4,8 +4,10 @@ import (
 	"testing"
 
 	"github.com/thedataflows/confedit/internal/features"
+	"github.com/thedataflows/confedit/internal/features/block"
 	"github.com/thedataflows/confedit/internal/features/dconf"
 	"github.com/thedataflows/confedit/internal/features/file"
+	"github.com/thedataflows/confedit/internal/features/line"
 	"github.com/thedataflows/confedit/internal/features/sed"
 	"github.com/thedataflows/confedit/internal/features/systemd"
 )
@@ -18,6 +20,8 @@ func TestAllFeatures_Registration(t *testing.T) {
 	registry.Register(dconf.New())
 	registry.Register(systemd.New())
 	registry.Register(sed.New())
+	registry.Register(block.New())
+	registry.Register(line.New())
 
 	// Verify all features are registered
 	expectedTypes := map[string]bool{
@@ -25,6 +29,8 @@ func TestAllFeatures_Registration(t *testing.T) {
 		"dconf":   true,
 		"systemd": true,
 		"sed":     true,
+		"block":   true,
+		"line":    true,
 	}
 
 	registeredTypes := registry.Types()
@@ -54,9 +60,11 @@ func TestAllFeatures_GetExecutor(t *testing.T) {
 	registry.Register(dconf.New())
 	registry.Register(systemd.New())
 	registry.Register(sed.New())
+	registry.Register(block.New())
+	registry.Register(line.New())
 
 	// Test getting executors for each feature
-	featureTypes := []string{"file", "dconf", "systemd", "sed"}
+	featureTypes := []string{"file", "dconf", "systemd", "sed", "block", "line"}
 
 	for _, featureType := range featureTypes {
 		t.Run(featureType, func(tb *testing.T) {
@@ -79,9 +87,11 @@ func TestAllFeatures_GetFeature(t *testing.T) {
 	registry.Register(dconf.New())
 	registry.Register(systemd.New())
 	registry.Register(sed.New())
+	registry.Register(block.New())
+	registry.Register(line.New())
 
 	// Test getting features
-	featureTypes := []string{"file", "dconf", "systemd", "sed"}
+	featureTypes := []string{"file", "dconf", "systemd", "sed", "block", "line"}
 
 	for _, featureType := range featureTypes {
 		t.Run(featureType, func(tb *testing.T) {
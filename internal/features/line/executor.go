@@ -0,0 +1,178 @@
+package line
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/thedataflows/confedit/internal/engine"
+	"github.com/thedataflows/confedit/internal/state"
+	"github.com/thedataflows/confedit/internal/types"
+	"github.com/thedataflows/confedit/internal/utils"
+)
+
+// Executor implements the engine.Executor interface for line-in-file targets
+type Executor struct{}
+
+// NewExecutor creates a new line-in-file executor
+func NewExecutor() engine.Executor {
+	return &Executor{}
+}
+
+// Apply ensures the line is present or absent, leaving the rest of the file untouched
+func (e *Executor) Apply(target types.AnyTarget, diff *state.ConfigDiff) error {
+	if diff != nil && diff.IsEmpty() {
+		return nil
+	}
+
+	if err := e.Validate(target); err != nil {
+		return err
+	}
+
+	config := target.(*Target).GetConfig()
+	original, exists, err := readFile(config)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		if config.IsAbsent() {
+			return nil
+		}
+		if !config.Create {
+			return missingFileError(config)
+		}
+	}
+
+	content := render(config, original)
+	if exists && content == original {
+		return nil
+	}
+
+	// Create backup if requested
+	if config.Backup {
+		if err := utils.CreateBackup(config.Path); err != nil {
+			return fmt.Errorf("create backup: %w", err)
+		}
+	}
+
+	if err := utils.WriteFileAtomic(config.Path, []byte(content), utils.WriteOptions{ReplaceSymlinks: config.ReplaceSymlinks}); err != nil {
+		return fmt.Errorf("write file %s: %w", config.Path, err)
+	}
+
+	return nil
+}
+
+// readFile returns the content of the target file and whether it exists
+func readFile(config *Config) (string, bool, error) {
+	content, err := os.ReadFile(config.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", false, nil
+		}
+		return "", false, fmt.Errorf("read file %s: %w", config.Path, err)
+	}
+	return string(content), true, nil
+}
+
+// missingFileError reports a missing file that the target may not create
+func missingFileError(config *Config) error {
+	return fmt.Errorf("file %s does not exist and create is disabled", config.Path)
+}
+
+// render returns content with the line ensured present or absent
+func render(config *Config, content string) string {
+	lines := utils.SplitLines(content)
+
+	var match *regexp.Regexp
+	if config.Match != "" {
+		match = regexp.MustCompile(config.Match)
+	}
+
+	if config.IsAbsent() {
+		lines.RemoveLines(config.Line, match)
+		return lines.String()
+	}
+
+	anchor := utils.LineAnchor{}
+	if config.InsertAfter != "" {
+		anchor.After = regexp.MustCompile(config.InsertAfter)
+	}
+	if config.InsertBefore != "" {
+		anchor.Before = regexp.MustCompile(config.InsertBefore)
+	}
+	lines.SetLine(config.Line, match, anchor)
+	return lines.String()
+}
+
+// DesiredState returns the file content with the line ensured, so the state diff shows
+// exactly which lines change in their context
+func (e *Executor) DesiredState(target types.AnyTarget) (map[string]interface{}, error) {
+	if err := e.Validate(target); err != nil {
+		return nil, err
+	}
+
+	config := target.(*Target).GetConfig()
+	content, exists, err := readFile(config)
+	if err != nil {
+		return nil, err
+	}
+
+	// Absent lines need nothing from missing files, and files are only created on request
+	if !exists {
+		if config.IsAbsent() {
+			return map[string]interface{}{
+				"content": "",
+				"exists":  false,
+			}, nil
+		}
+		if !config.Create {
+			return nil, missingFileError(config)
+		}
+	}
+
+	return map[string]interface{}{
+		"content": render(config, content),
+		"exists":  true,
+	}, nil
+}
+
+// Validate checks if the target is valid
+func (e *Executor) Validate(target types.AnyTarget) error {
+	if target.GetType() != types.TYPE_LINE {
+		return fmt.Errorf("expected line target, got %s", target.GetType())
+	}
+
+	lineTarget, ok := target.(*Target)
+	if !ok {
+		return fmt.Errorf("target is not a line target")
+	}
+
+	if lineTarget.GetConfig() == nil {
+		return fmt.Errorf("line target is missing")
+	}
+
+	return lineTarget.GetConfig().Validate()
+}
+
+// CurrentState retrieves the current content of the target file
+func (e *Executor) CurrentState(target types.AnyTarget) (map[string]interface{}, error) {
+	if err := e.Validate(target); err != nil {
+		return nil, err
+	}
+
+	content, exists, err := readFile(target.(*Target).GetConfig())
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"content": content,
+		"exists":  exists,
+	}, nil
+}
+
+// Verify that Executor implements the engine interfaces at compile time
+var (
+	_ engine.Executor      = (*Executor)(nil)
+	_ engine.DesiredStater = (*Executor)(nil)
+)
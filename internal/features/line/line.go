@@ -0,0 +1,59 @@
+package line
+
+import (
+	"fmt"
+
+	"github.com/thedataflows/confedit/internal/engine"
+	"github.com/thedataflows/confedit/internal/features"
+	"github.com/thedataflows/confedit/internal/types"
+)
+
+// Feature implements the features.Feature interface for line-in-file targets
+type Feature struct {
+	executor engine.Executor
+}
+
+// New creates a new line-in-file feature
+func New() features.Feature {
+	return &Feature{
+		executor: NewExecutor(),
+	}
+}
+
+// Type returns the feature type identifier
+func (f *Feature) Type() string {
+	return types.TYPE_LINE
+}
+
+// Executor returns the executor implementation for this feature
+func (f *Feature) Executor() engine.Executor {
+	return f.executor
+}
+
+// NewTarget creates a new line-in-file target instance
+func (f *Feature) NewTarget(name string, config interface{}) (types.AnyTarget, error) {
+	lineConfig, ok := config.(*Config)
+	if !ok {
+		return nil, fmt.Errorf("invalid config type for line target, expected *line.Config")
+	}
+
+	return &Target{
+		Name:     name,
+		Type:     types.TYPE_LINE,
+		Metadata: make(map[string]interface{}),
+		Config:   lineConfig,
+	}, nil
+}
+
+// Validate validates the line-specific target
+func (f *Feature) Validate(config interface{}) error {
+	lineConfig, ok := config.(*Config)
+	if !ok {
+		return fmt.Errorf("invalid config type for line target, expected *line.Config")
+	}
+
+	return lineConfig.Validate()
+}
+
+// Verify that Feature implements the features.Feature interface at compile time
+var _ features.Feature = (*Feature)(nil)
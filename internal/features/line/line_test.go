@@ -0,0 +1,170 @@
+package line_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thedataflows/confedit/internal/engine"
+	"github.com/thedataflows/confedit/internal/features/line"
+	"github.com/thedataflows/confedit/internal/state"
+	"github.com/thedataflows/confedit/internal/types"
+)
+
+func TestLineFeature_Type(t *testing.T) {
+	feature := line.New()
+	assert.Equal(t, types.TYPE_LINE, feature.Type())
+	assert.NotNil(t, feature.Executor())
+}
+
+func TestLineFeature_Validate(t *testing.T) {
+	feature := line.New()
+
+	tests := []struct {
+		name    string
+		config  *line.Config
+		wantErr bool
+	}{
+		{"valid config", &line.Config{Path: "/etc/hosts", Line: "127.0.0.1 app"}, false},
+		{"absent by match", &line.Config{Path: "/etc/hosts", State: line.StateAbsent, Match: "^10\\."}, false},
+		{"missing path", &line.Config{Line: "x"}, true},
+		{"missing line", &line.Config{Path: "/etc/hosts"}, true},
+		{"absent without line or match", &line.Config{Path: "/etc/hosts", State: line.StateAbsent}, true},
+		{"invalid state", &line.Config{Path: "/etc/hosts", Line: "x", State: "gone"}, true},
+		{"invalid match", &line.Config{Path: "/etc/hosts", Line: "x", Match: "("}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(tb *testing.T) {
+			err := feature.Validate(tt.config)
+			if tt.wantErr {
+				assert.Error(tb, err)
+			} else {
+				assert.NoError(tb, err)
+			}
+		})
+	}
+}
+
+// converge computes the drift of target, applies it and returns the diff
+func converge(t *testing.T, target *line.Target) *state.ConfigDiff {
+	t.Helper()
+	executor := line.NewExecutor()
+
+	current, err := executor.CurrentState(target)
+	require.NoError(t, err)
+	desired, err := executor.(engine.DesiredStater).DesiredState(target)
+	require.NoError(t, err)
+	diff, err := state.NewManager("").ComputeDiffWithCurrent(target.GetName(), desired, current)
+	require.NoError(t, err)
+
+	require.NoError(t, executor.Apply(target, diff))
+	return diff
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return string(content)
+}
+
+func TestLineExecutor(t *testing.T) {
+	const sshdConfig = "Include /etc/ssh/sshd_config.d/*.conf\n#Port 22\nPermitRootLogin yes\nSubsystem sftp internal-sftp\n"
+
+	tests := []struct {
+		name     string
+		config   line.Config
+		expected string
+	}{
+		{
+			name:     "replace the first matching line",
+			config:   line.Config{Line: "Port 2222", Match: `^#?Port\s`},
+			expected: "Include /etc/ssh/sshd_config.d/*.conf\nPort 2222\nPermitRootLogin yes\nSubsystem sftp internal-sftp\n",
+		},
+		{
+			name:     "insert after the anchor",
+			config:   line.Config{Line: "UseDNS no", InsertAfter: `^PermitRootLogin\s`},
+			expected: "Include /etc/ssh/sshd_config.d/*.conf\n#Port 22\nPermitRootLogin yes\nUseDNS no\nSubsystem sftp internal-sftp\n",
+		},
+		{
+			name:     "insert before the anchor",
+			config:   line.Config{Line: "UseDNS no", InsertBefore: `^Subsystem\s`},
+			expected: "Include /etc/ssh/sshd_config.d/*.conf\n#Port 22\nPermitRootLogin yes\nUseDNS no\nSubsystem sftp internal-sftp\n",
+		},
+		{
+			name:     "append when nothing matches",
+			config:   line.Config{Line: "UseDNS no", Match: `^UseDNS\s`, InsertAfter: `^Missing`},
+			expected: sshdConfig + "UseDNS no\n",
+		},
+		{
+			name:     "existing line is kept",
+			config:   line.Config{Line: "PermitRootLogin yes"},
+			expected: sshdConfig,
+		},
+		{
+			name:     "absent line",
+			config:   line.Config{Line: "PermitRootLogin yes", State: line.StateAbsent},
+			expected: "Include /etc/ssh/sshd_config.d/*.conf\n#Port 22\nSubsystem sftp internal-sftp\n",
+		},
+		{
+			name:     "absent by match",
+			config:   line.Config{Match: `^(#?Port|Include)\s`, State: line.StateAbsent},
+			expected: "PermitRootLogin yes\nSubsystem sftp internal-sftp\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(tb *testing.T) {
+			path := filepath.Join(tb.TempDir(), "sshd_config")
+			require.NoError(tb, os.WriteFile(path, []byte(sshdConfig), 0600))
+
+			config := tt.config
+			config.Path = path
+			target := line.NewTarget("sshd", path, "")
+			target.Config = &config
+
+			diff := converge(t, target)
+			assert.Equal(tb, tt.expected, readFile(t, path))
+			assert.Equal(tb, tt.expected == sshdConfig, diff.IsEmpty())
+			assert.True(tb, converge(t, target).IsEmpty(), "second run must not change anything")
+		})
+	}
+}
+
+func TestLineExecutor_LineDiff(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts")
+	require.NoError(t, os.WriteFile(path, []byte("127.0.0.1 localhost\n::1 localhost\n"), 0644))
+
+	target := line.NewTarget("build", path, "10.0.0.1 build")
+	target.Config.InsertAfter = `^127\.`
+
+	diff := converge(t, target)
+	assert.Equal(t, `  Change:
+    ~ content:
+      @@ -1,2 +1,3 @@
+       127.0.0.1 localhost
+      +10.0.0.1 build
+       ::1 localhost`, diff.FormatPlain())
+}
+
+func TestLineExecutor_MissingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "profile")
+	target := line.NewTarget("umask", path, "umask 027")
+	executor := line.NewExecutor()
+
+	_, err := executor.(engine.DesiredStater).DesiredState(target)
+	assert.ErrorContains(t, err, "does not exist")
+	assert.Error(t, executor.Apply(target, nil))
+
+	target.Config.Create = true
+	converge(t, target)
+	assert.Equal(t, "umask 027\n", readFile(t, path))
+
+	require.NoError(t, os.Remove(path))
+	target.Config.State = line.StateAbsent
+	assert.True(t, converge(t, target).IsEmpty())
+	assert.NoFileExists(t, path)
+}
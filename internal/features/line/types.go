@@ -0,0 +1,115 @@
+package line
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/thedataflows/confedit/internal/types"
+)
+
+const (
+	// StatePresent ensures the line exists in the file
+	StatePresent = "present"
+	// StateAbsent removes the line from the file
+	StateAbsent = "absent"
+)
+
+// Config represents the configuration for a line-in-file target.
+// When present, the first line matching Match is replaced by Line; otherwise Line is
+// inserted after the last line matching InsertAfter, before the first line matching
+// InsertBefore, or at the end of the file. When absent, every line equal to Line or
+// matching Match is removed.
+type Config struct {
+	Path            string `json:"path"`
+	Line            string `json:"line,omitempty"`
+	State           string `json:"state,omitempty"`
+	Match           string `json:"match,omitempty"`
+	InsertAfter     string `json:"insert_after,omitempty"`
+	InsertBefore    string `json:"insert_before,omitempty"`
+	Create          bool   `json:"create,omitempty"`
+	Backup          bool   `json:"backup,omitempty"`
+	ReplaceSymlinks bool   `json:"replace_symlinks,omitempty"` // Replace a symlink at Path instead of writing through it
+}
+
+// Type implements TargetConfig interface
+func (c *Config) Type() string {
+	return types.TYPE_LINE
+}
+
+// Validate checks if the line configuration is valid
+func (c *Config) Validate() error {
+	if c.Path == "" {
+		return fmt.Errorf("path is required for line target")
+	}
+	switch c.State {
+	case "", StatePresent:
+		if c.Line == "" {
+			return fmt.Errorf("line is required for present line target")
+		}
+	case StateAbsent:
+		if c.Line == "" && c.Match == "" {
+			return fmt.Errorf("line or match is required for absent line target")
+		}
+	default:
+		return fmt.Errorf("invalid line state %q, expected %s or %s", c.State, StatePresent, StateAbsent)
+	}
+	for name, pattern := range map[string]string{"match": c.Match, "insert_after": c.InsertAfter, "insert_before": c.InsertBefore} {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid %s expression: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// IsAbsent reports whether the line must be removed
+func (c *Config) IsAbsent() bool {
+	return c.State == StateAbsent
+}
+
+// Target is a type alias for line-in-file targets
+type Target = types.BaseTarget[*Config]
+
+// NewTarget creates a new line-in-file target
+func NewTarget(name, path, line string) *Target {
+	return &Target{
+		Name:     name,
+		Type:     types.TYPE_LINE,
+		Metadata: make(map[string]interface{}),
+		Config: &Config{
+			Path: path,
+			Line: line,
+		},
+	}
+}
+
+// MergeConfig merges line target configs, later non-empty values win
+func MergeConfig(existing, newTarget *Config) error {
+	if newTarget.Path != "" {
+		existing.Path = newTarget.Path
+	}
+	if newTarget.Line != "" {
+		existing.Line = newTarget.Line
+	}
+	if newTarget.State != "" {
+		existing.State = newTarget.State
+	}
+	if newTarget.Match != "" {
+		existing.Match = newTarget.Match
+	}
+	if newTarget.InsertAfter != "" {
+		existing.InsertAfter = newTarget.InsertAfter
+	}
+	if newTarget.InsertBefore != "" {
+		existing.InsertBefore = newTarget.InsertBefore
+	}
+	if newTarget.Create {
+		existing.Create = true
+	}
+	if newTarget.Backup {
+		existing.Backup = true
+	}
+	if newTarget.ReplaceSymlinks {
+		existing.ReplaceSymlinks = true
+	}
+	return nil
+}
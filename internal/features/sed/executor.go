@@ -1,6 +1,7 @@
 package sed
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"os"
@@ -45,8 +46,19 @@ func (e *Executor) Apply(target types.AnyTarget, diff *state.ConfigDiff) error {
 		return fmt.Errorf("sed target path is required")
 	}
 
-	if len(config.Commands) == 0 {
-		return fmt.Errorf("sed commands are required")
+	content, err := os.ReadFile(config.Path)
+	if err != nil {
+		return fmt.Errorf("open file %s: %w", config.Path, err)
+	}
+
+	output, err := simulate(config, content)
+	if err != nil {
+		return err
+	}
+
+	// Scripts that no longer change anything must not touch the file
+	if bytes.Equal(output, content) {
+		return nil
 	}
 
 	// Create backup if requested
@@ -56,32 +68,63 @@ func (e *Executor) Apply(target types.AnyTarget, diff *state.ConfigDiff) error {
 		}
 	}
 
-	// Open the file for reading
-	fileHandle, err := os.Open(config.Path)
-	if err != nil {
-		return fmt.Errorf("open file %s: %w", config.Path, err)
+	if err := utils.WriteFileAtomic(config.Path, output, utils.WriteOptions{ReplaceSymlinks: config.ReplaceSymlinks}); err != nil {
+		return fmt.Errorf("write processed content: %w", err)
 	}
-	defer fileHandle.Close()
 
-	// Apply sed commands using memory buffer
-	script := strings.Join(config.Commands, "\n")
-	sedEngine, err := goSed.New(strings.NewReader(script))
-	if err != nil {
-		return fmt.Errorf("create sed engine: %w", err)
+	return nil
+}
+
+// simulate runs the sed commands and operations of config over content in memory
+func simulate(config *Config, content []byte) ([]byte, error) {
+	if len(config.Commands) > 0 {
+		script := strings.Join(config.Commands, "\n")
+		sedEngine, err := goSed.New(strings.NewReader(script))
+		if err != nil {
+			return nil, fmt.Errorf("create sed engine: %w", err)
+		}
+
+		var output bytes.Buffer
+		if _, err := io.Copy(&output, sedEngine.Wrap(bytes.NewReader(content))); err != nil {
+			return nil, fmt.Errorf("run sed commands: %w", err)
+		}
+		content = output.Bytes()
+	}
+
+	if len(config.Operations) > 0 {
+		output, err := applyOperations(config.Operations, string(content))
+		if err != nil {
+			return nil, err
+		}
+		content = []byte(output)
 	}
 
-	// Process content in memory
-	var output strings.Builder
-	if _, err := io.Copy(&output, sedEngine.Wrap(fileHandle)); err != nil {
-		return fmt.Errorf("run sed commands: %w", err)
+	return content, nil
+}
+
+// DesiredState returns the file content the sed commands would produce from the
+// current content, so that only files the commands would actually change show drift.
+// Like Apply, it fails when the file does not exist.
+func (e *Executor) DesiredState(target types.AnyTarget) (map[string]interface{}, error) {
+	if err := e.Validate(target); err != nil {
+		return nil, err
 	}
 
-	// Write processed content directly to the original file
-	if err := os.WriteFile(config.Path, []byte(output.String()), 0644); err != nil {
-		return fmt.Errorf("write processed content: %w", err)
+	config := target.(*Target).GetConfig()
+	content, err := os.ReadFile(config.Path)
+	if err != nil {
+		return nil, fmt.Errorf("open file %s: %w", config.Path, err)
 	}
 
-	return nil
+	output, err := simulate(config, content)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"content": string(output),
+		"exists":  true,
+	}, nil
 }
 
 // Validate checks if the target is valid
@@ -104,15 +147,16 @@ func (e *Executor) Validate(target types.AnyTarget) error {
 		return fmt.Errorf("sed target path is required")
 	}
 
-	if len(config.Commands) == 0 {
-		return fmt.Errorf("sed commands are required")
+	if err := config.Validate(); err != nil {
+		return err
 	}
 
 	// Test if commands are valid by creating a sed engine
-	script := strings.Join(config.Commands, "\n")
-	_, err := goSed.New(strings.NewReader(script))
-	if err != nil {
-		return fmt.Errorf("invalid sed commands: %w", err)
+	if len(config.Commands) > 0 {
+		script := strings.Join(config.Commands, "\n")
+		if _, err := goSed.New(strings.NewReader(script)); err != nil {
+			return fmt.Errorf("invalid sed commands: %w", err)
+		}
 	}
 
 	return nil
@@ -145,5 +189,8 @@ func (e *Executor) CurrentState(target types.AnyTarget) (map[string]interface{},
 	}, nil
 }
 
-// Verify that Executor implements the engine.Executor interface at compile time
-var _ engine.Executor = (*Executor)(nil)
+// Verify that Executor implements the engine interfaces at compile time
+var (
+	_ engine.Executor      = (*Executor)(nil)
+	_ engine.DesiredStater = (*Executor)(nil)
+)
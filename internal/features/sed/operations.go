@@ -0,0 +1,131 @@
+package sed
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+
+	"github.com/thedataflows/confedit/internal/utils"
+)
+
+// Operation is a structured line edit using Go RE2 regular expressions, as an
+// alternative to raw sed commands. Each operation performs exactly one action:
+//   - replace: substitute the text matched by Match on the selected lines ($1 expands groups)
+//   - delete: remove the selected lines matching Match
+//   - ensure_present: keep the line exactly once, replacing lines matching Match or
+//     inserting it after InsertAfter / before InsertBefore (default: end of file)
+type Operation struct {
+	Match         string  `json:"match,omitempty"`
+	Replace       *string `json:"replace,omitempty"`
+	Occurrence    int     `json:"occurrence,omitempty"`
+	Delete        bool    `json:"delete,omitempty"`
+	EnsurePresent string  `json:"ensure_present,omitempty"`
+	InsertAfter   string  `json:"insert_after,omitempty"`
+	InsertBefore  string  `json:"insert_before,omitempty"`
+}
+
+// compiledOperation is an Operation with its regular expressions compiled
+type compiledOperation struct {
+	*Operation
+	match        *regexp.Regexp
+	insertAfter  *regexp.Regexp
+	insertBefore *regexp.Regexp
+}
+
+// compile validates the operation and compiles its regular expressions
+func (o *Operation) compile() (*compiledOperation, error) {
+	actions := 0
+	for _, set := range []bool{o.Replace != nil, o.Delete, o.EnsurePresent != ""} {
+		if set {
+			actions++
+		}
+	}
+	if actions != 1 {
+		return nil, fmt.Errorf("exactly one of replace, delete or ensure_present is required")
+	}
+
+	if o.Match == "" && o.EnsurePresent == "" {
+		return nil, fmt.Errorf("match is required")
+	}
+	if (o.InsertAfter != "" || o.InsertBefore != "") && o.EnsurePresent == "" {
+		return nil, fmt.Errorf("insert_after and insert_before require ensure_present")
+	}
+	if o.Occurrence != 0 && o.EnsurePresent != "" {
+		return nil, fmt.Errorf("occurrence cannot be combined with ensure_present")
+	}
+	if o.Occurrence < -1 {
+		return nil, fmt.Errorf("occurrence must be -1 (last), 0 (all) or a 1-based line occurrence")
+	}
+
+	compiled := &compiledOperation{Operation: o}
+	for _, expression := range []struct {
+		name    string
+		pattern string
+		target  **regexp.Regexp
+	}{
+		{"match", o.Match, &compiled.match},
+		{"insert_after", o.InsertAfter, &compiled.insertAfter},
+		{"insert_before", o.InsertBefore, &compiled.insertBefore},
+	} {
+		if expression.pattern == "" {
+			continue
+		}
+		re, err := regexp.Compile(expression.pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s expression: %w", expression.name, err)
+		}
+		*expression.target = re
+	}
+
+	return compiled, nil
+}
+
+// apply performs the operation on lines
+func (o *compiledOperation) apply(lines *utils.TextLines) {
+	if o.EnsurePresent != "" {
+		lines.EnsureLine(o.EnsurePresent, o.match, utils.LineAnchor{After: o.insertAfter, Before: o.insertBefore})
+		return
+	}
+
+	selected := selectOccurrence(lines.MatchingLines(o.match), o.Occurrence)
+	if o.Delete {
+		for _, index := range slices.Backward(selected) {
+			lines.Lines = slices.Delete(lines.Lines, index, index+1)
+		}
+		return
+	}
+
+	for _, index := range selected {
+		lines.Lines[index] = o.match.ReplaceAllString(lines.Lines[index], *o.Replace)
+	}
+}
+
+// selectOccurrence picks the matching line indexes an operation applies to:
+// all of them (0), the last one (-1) or the n-th one (1-based)
+func selectOccurrence(indexes []int, occurrence int) []int {
+	switch {
+	case occurrence == 0:
+		return indexes
+	case len(indexes) == 0:
+		return nil
+	case occurrence == -1:
+		return indexes[len(indexes)-1:]
+	case occurrence <= len(indexes):
+		return indexes[occurrence-1 : occurrence]
+	default:
+		return nil
+	}
+}
+
+// applyOperations runs the operations in order over content
+func applyOperations(operations []Operation, content string) (string, error) {
+	lines := utils.SplitLines(content)
+	for i := range operations {
+		compiled, err := operations[i].compile()
+		if err != nil {
+			return "", fmt.Errorf("operation %d: %w", i+1, err)
+		}
+		compiled.apply(lines)
+	}
+	return lines.String(), nil
+}
@@ -0,0 +1,129 @@
+package sed
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+func TestApplyOperations(t *testing.T) {
+	const sshdConfig = "#PermitRootLogin prohibit-password\nPort 22\nX11Forwarding yes\nX11Forwarding no\nSubsystem sftp internal-sftp\n"
+
+	tests := []struct {
+		name       string
+		content    string
+		operations []Operation
+		expected   string
+	}{
+		{
+			name:    "replace all occurrences with groups",
+			content: "host=a.example\nhost=b.example\n",
+			operations: []Operation{
+				{Match: `^host=(\w+)\.example$`, Replace: stringPtr("host=$1.internal")},
+			},
+			expected: "host=a.internal\nhost=b.internal\n",
+		},
+		{
+			name:    "replace only the last occurrence",
+			content: "a=1\na=1\na=1\n",
+			operations: []Operation{
+				{Match: `^a=1$`, Replace: stringPtr("a=2"), Occurrence: -1},
+			},
+			expected: "a=1\na=1\na=2\n",
+		},
+		{
+			name:    "delete the second occurrence",
+			content: "x\nx\nx\n",
+			operations: []Operation{
+				{Match: `^x$`, Delete: true, Occurrence: 2},
+			},
+			expected: "x\nx\n",
+		},
+		{
+			name:    "ensure present replaces the commented directive",
+			content: sshdConfig,
+			operations: []Operation{
+				{Match: `^#?PermitRootLogin\s`, EnsurePresent: "PermitRootLogin no"},
+			},
+			expected: "PermitRootLogin no\nPort 22\nX11Forwarding yes\nX11Forwarding no\nSubsystem sftp internal-sftp\n",
+		},
+		{
+			name:    "ensure present collapses duplicates into one line",
+			content: sshdConfig,
+			operations: []Operation{
+				{Match: `^X11Forwarding\s`, EnsurePresent: "X11Forwarding no"},
+			},
+			expected: "#PermitRootLogin prohibit-password\nPort 22\nX11Forwarding no\nSubsystem sftp internal-sftp\n",
+		},
+		{
+			name:    "ensure present inserts after the anchor",
+			content: sshdConfig,
+			operations: []Operation{
+				{EnsurePresent: "ListenAddress 0.0.0.0", InsertAfter: `^Port\s`},
+			},
+			expected: "#PermitRootLogin prohibit-password\nPort 22\nListenAddress 0.0.0.0\nX11Forwarding yes\nX11Forwarding no\nSubsystem sftp internal-sftp\n",
+		},
+		{
+			name:    "ensure present inserts before the anchor",
+			content: sshdConfig,
+			operations: []Operation{
+				{EnsurePresent: "UseDNS no", InsertBefore: `^Subsystem\s`},
+			},
+			expected: "#PermitRootLogin prohibit-password\nPort 22\nX11Forwarding yes\nX11Forwarding no\nUseDNS no\nSubsystem sftp internal-sftp\n",
+		},
+		{
+			name:       "ensure present appends to files without trailing newline",
+			content:    "a\nb",
+			operations: []Operation{{EnsurePresent: "c"}},
+			expected:   "a\nb\nc",
+		},
+		{
+			name:       "ensure present in an empty file",
+			content:    "",
+			operations: []Operation{{EnsurePresent: "c"}},
+			expected:   "c\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(tb *testing.T) {
+			output, err := applyOperations(tt.operations, tt.content)
+			require.NoError(tb, err)
+			assert.Equal(tb, tt.expected, output)
+
+			if tt.operations[0].EnsurePresent != "" || tt.operations[0].Delete && tt.operations[0].Occurrence == 0 {
+				again, err := applyOperations(tt.operations, output)
+				require.NoError(tb, err)
+				assert.Equal(tb, output, again, "operation is not idempotent")
+			}
+		})
+	}
+}
+
+func TestOperation_Validate(t *testing.T) {
+	invalid := []Operation{
+		{Match: "x"},
+		{Match: "x", Delete: true, Replace: stringPtr("y")},
+		{Replace: stringPtr("y")},
+		{Match: "(", Delete: true},
+		{Match: "x", Delete: true, InsertAfter: "y"},
+		{EnsurePresent: "x", InsertAfter: "["},
+		{EnsurePresent: "x", Occurrence: 1},
+		{Match: "x", Delete: true, Occurrence: -2},
+	}
+	for _, operation := range invalid {
+		config := &Config{Path: "/tmp/file", Operations: []Operation{operation}}
+		assert.Error(t, config.Validate(), "%+v", operation)
+	}
+
+	config := &Config{Path: "/tmp/file", Operations: []Operation{
+		{Match: `^#?Port\s`, EnsurePresent: "Port 2222"},
+		{Match: `^UseDNS`, Delete: true},
+	}}
+	assert.NoError(t, config.Validate())
+}
@@ -1,9 +1,14 @@
 package sed_test
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/thedataflows/confedit/internal/engine"
 	"github.com/thedataflows/confedit/internal/features/sed"
+	"github.com/thedataflows/confedit/internal/state"
 	"github.com/thedataflows/confedit/internal/types"
 )
 
@@ -91,3 +96,98 @@ func TestSedFeature_NewTarget(t *testing.T) {
 		t.Errorf("expected type %s, got %s", types.TYPE_SED, target.GetType())
 	}
 }
+
+func TestSedExecutor_Idempotent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sshd_config")
+	if err := os.WriteFile(path, []byte("PermitRootLogin yes\nPort 22\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	executor := sed.NewExecutor()
+	stater := executor.(engine.DesiredStater)
+	target := sed.NewTarget("sshd", path, []string{"s/^PermitRootLogin .*/PermitRootLogin no/"})
+	manager := state.NewManager("")
+
+	drift := func() *state.ConfigDiff {
+		t.Helper()
+		current, err := executor.CurrentState(target)
+		if err != nil {
+			t.Fatalf("CurrentState() error = %v", err)
+		}
+		desired, err := stater.DesiredState(target)
+		if err != nil {
+			t.Fatalf("DesiredState() error = %v", err)
+		}
+		diff, err := manager.ComputeDiffWithCurrent(target.GetName(), desired, current)
+		if err != nil {
+			t.Fatalf("ComputeDiffWithCurrent() error = %v", err)
+		}
+		return diff
+	}
+
+	diff := drift()
+	if _, ok := diff.Modified["content"]; !ok {
+		t.Fatalf("expected content drift, got %+v", diff.Changes)
+	}
+
+	if err := executor.Apply(target, diff); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "PermitRootLogin no\nPort 22\n" {
+		t.Errorf("unexpected content %q", content)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("expected mode 0600 to be kept, got %v", info.Mode().Perm())
+	}
+
+	// Once converged the commands report no drift and leave the file alone
+	if diff := drift(); !diff.IsEmpty() {
+		t.Errorf("expected no drift after apply, got %+v", diff.Changes)
+	}
+
+	past := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(path, past, past); err != nil {
+		t.Fatal(err)
+	}
+	if err := executor.Apply(target, nil); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	info, err = os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(past) {
+		t.Error("file was rewritten although the commands changed nothing")
+	}
+}
+
+func TestSedExecutor_MissingFile(t *testing.T) {
+	executor := sed.NewExecutor()
+	target := sed.NewTarget("sshd", filepath.Join(t.TempDir(), "sshd_config"), []string{"s/yes/no/"})
+
+	current, err := executor.CurrentState(target)
+	if err != nil {
+		t.Fatalf("CurrentState() error = %v", err)
+	}
+	if current["exists"] != false {
+		t.Errorf("expected missing file, got %+v", current)
+	}
+
+	// A missing file cannot be edited, so desired state fails like Apply does
+	if _, err := executor.(engine.DesiredStater).DesiredState(target); err == nil {
+		t.Error("DesiredState() expected an error for a missing file")
+	}
+	if err := executor.Apply(target, nil); err == nil {
+		t.Error("Apply() expected an error for a missing file")
+	}
+}
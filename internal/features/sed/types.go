@@ -6,12 +6,15 @@ import (
 	"github.com/thedataflows/confedit/internal/types"
 )
 
-// Config represents the configuration for a sed target
+// Config represents the configuration for a sed target.
+// Raw sed Commands run first, followed by the structured Operations.
 type Config struct {
-	Path     string            `json:"path"`
-	Commands []string          `json:"commands"`
-	Backup   bool              `json:"backup,omitempty"`
-	Options  map[string]string `json:"options,omitempty"`
+	Path            string            `json:"path"`
+	Commands        []string          `json:"commands"`
+	Operations      []Operation       `json:"operations,omitempty"`
+	Backup          bool              `json:"backup,omitempty"`
+	ReplaceSymlinks bool              `json:"replace_symlinks,omitempty"` // Replace a symlink at Path instead of writing through it
+	Options         map[string]string `json:"options,omitempty"`
 }
 
 // Type implements TargetConfig interface
@@ -24,8 +27,13 @@ func (c *Config) Validate() error {
 	if c.Path == "" {
 		return fmt.Errorf("path is required for sed target")
 	}
-	if len(c.Commands) == 0 {
-		return fmt.Errorf("at least one sed command is required")
+	if len(c.Commands) == 0 && len(c.Operations) == 0 {
+		return fmt.Errorf("at least one sed command or operation is required")
+	}
+	for i := range c.Operations {
+		if _, err := c.Operations[i].compile(); err != nil {
+			return fmt.Errorf("invalid sed operation %d: %w", i+1, err)
+		}
 	}
 	return nil
 }
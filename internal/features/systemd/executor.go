@@ -2,20 +2,41 @@ package systemd
 
 import (
 	"fmt"
+	"maps"
+	"os"
 	"os/exec"
+	"strings"
 
 	"github.com/thedataflows/confedit/internal/engine"
 	"github.com/thedataflows/confedit/internal/state"
 	"github.com/thedataflows/confedit/internal/types"
 	"github.com/thedataflows/confedit/internal/utils"
+	log "github.com/thedataflows/go-lib-log"
 )
 
 // Executor implements the engine.Executor interface for systemd targets
-type Executor struct{}
+type Executor struct {
+	systemctl func(args ...string) ([]byte, error)
+}
 
 // NewExecutor creates a new systemd executor
 func NewExecutor() engine.Executor {
-	return &Executor{}
+	return &Executor{
+		systemctl: runSystemctl,
+	}
+}
+
+// run runs systemctl for the service manager of the target scope
+func (e *Executor) run(config *Config, args ...string) ([]byte, error) {
+	if config.Scope == ScopeUser {
+		args = append([]string{"--user"}, args...)
+	}
+	return e.systemctl(args...)
+}
+
+// runSystemctl runs systemctl with the given arguments and returns its standard output
+func runSystemctl(args ...string) ([]byte, error) {
+	return exec.Command("systemctl", args...).Output()
 }
 
 // Apply applies the changes to systemd
@@ -37,39 +58,59 @@ func (e *Executor) Apply(target types.AnyTarget, diff *state.ConfigDiff) error {
 		return fmt.Errorf("systemd target is missing")
 	}
 
-	unitFile := systemdTarget.GetConfig().Unit
-	if unitFile == "" {
+	config := systemdTarget.GetConfig()
+	if config.Unit == "" {
 		return fmt.Errorf("systemd unit file not specified")
 	}
 
+	unitPath := config.UnitFilePath()
+
 	// Create backup if requested
-	if systemdTarget.GetConfig().Backup {
-		if err := utils.CreateBackup(unitFile); err != nil {
+	if config.Backup {
+		if err := utils.CreateBackup(unitPath); err != nil {
 			return fmt.Errorf("create backup: %w", err)
 		}
 	}
 
-	// Update unit file with changes from target properties
-	if len(diff.Changes) > 0 {
-		err := e.updateUnitFile(unitFile, systemdTarget.GetConfig().Properties)
+	// Update unit file or drop-in with the target properties
+	unitChanged := false
+	if diff == nil || len(diff.Changes) > 0 {
+		changed, err := updateUnitFile(unitPath, config.Section, config.Properties, utils.WriteOptions{ReplaceSymlinks: config.ReplaceSymlinks})
 		if err != nil {
 			return err
 		}
 
-		// Reload systemd daemon
-		cmd := exec.Command("systemctl", "daemon-reload")
-		if err := cmd.Run(); err != nil {
-			return fmt.Errorf("reload systemd: %w", err)
+		if changed {
+			log.Debugf("systemd-executor", "Updated %s, reloading systemd daemon", unitPath)
+			if _, err := e.run(config, "daemon-reload"); err != nil {
+				return fmt.Errorf("reload systemd: %w", err)
+			}
+			unitChanged = true
 		}
 	}
 
-	// Handle service state changes
-	if systemdTarget.GetConfig().Reload {
-		cmd := exec.Command("systemctl", "reload-or-restart", unitFile)
-		return cmd.Run()
+	// Converge enablement, mask and running state
+	return e.convergeLifecycle(config, unitChanged)
+}
+
+// DesiredState returns the target properties together with the desired lifecycle state
+func (e *Executor) DesiredState(target types.AnyTarget) (map[string]interface{}, error) {
+	if err := e.Validate(target); err != nil {
+		return nil, err
+	}
+
+	config := target.(*Target).GetConfig()
+
+	desired := make(map[string]interface{}, len(config.Properties)+2)
+	maps.Copy(desired, config.Properties)
+	if enabled := config.DesiredEnabled(); enabled != "" {
+		desired[StateEnabled] = enabled
+	}
+	if active := config.DesiredActive(); active != "" {
+		desired[StateActive] = active
 	}
 
-	return nil
+	return desired, nil
 }
 
 // Validate checks if the target is valid
@@ -93,33 +134,70 @@ func (e *Executor) Validate(target types.AnyTarget) error {
 	return nil
 }
 
-// CurrentState retrieves the current state from systemd
+// CurrentState retrieves the current state from systemd.
+// Lifecycle state is reported under StateEnabled and StateActive when the target manages it.
+// Properties are resolved from the unit file and its drop-ins (`systemctl cat`,
+// falling back to the managed file when systemctl is unavailable), then from
+// `systemctl show` for values that are not set explicitly. Only the properties
+// of the target are reported, normalized to the representation of the desired values.
 func (e *Executor) CurrentState(target types.AnyTarget) (map[string]interface{}, error) {
 	if err := e.Validate(target); err != nil {
 		return nil, err
 	}
 
-	systemdTarget := target.(*Target)
-	unitFile := systemdTarget.GetConfig().Unit
+	config := target.(*Target).GetConfig()
 
-	// Get unit file status
-	cmd := exec.Command("systemctl", "show", unitFile)
-	output, err := cmd.Output()
+	unitContent, err := e.run(config, "cat", config.Unit)
 	if err != nil {
-		return make(map[string]interface{}), nil
+		unitContent, err = os.ReadFile(config.UnitFilePath())
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("read unit file: %w", err)
+		}
+	}
+
+	assigned, err := parseUnitSection(unitContent, config.Section)
+	if err != nil {
+		return nil, fmt.Errorf("parse unit file: %w", err)
+	}
+
+	var show map[string]string
+	if output, err := e.run(config, "show", config.Unit); err == nil {
+		show = parseShowOutput(output)
 	}
 
 	result := make(map[string]interface{})
-	result["_status"] = string(output)
+	for key, desired := range config.Properties {
+		if values, ok := assigned[key]; ok {
+			result[key] = normalizeProperty(key, desired, values, false)
+			continue
+		}
 
-	return result, nil
-}
+		value, ok := lookupShowProperty(show, key)
+		if !ok {
+			continue
+		}
+		values := []string{value}
+		if _, isList := desired.([]interface{}); isList {
+			values = strings.Fields(value)
+		}
+		result[key] = normalizeProperty(key, desired, values, true)
+	}
+
+	if config.DesiredEnabled() != "" || config.DesiredActive() != "" {
+		enabled, active := e.unitStatus(config)
+		if config.DesiredEnabled() != "" {
+			result[StateEnabled] = enabled
+		}
+		if config.DesiredActive() != "" {
+			result[StateActive] = active
+		}
+	}
 
-// updateUnitFile updates the systemd unit file
-func (e *Executor) updateUnitFile(unitFile string, changes map[string]interface{}) error {
-	// Simplified implementation - in real scenario, parse and update unit file
-	return fmt.Errorf("unit file update not implemented")
+	return result, nil
 }
 
-// Verify that Executor implements the engine.Executor interface at compile time
-var _ engine.Executor = (*Executor)(nil)
+// Verify that Executor implements the engine interfaces at compile time
+var (
+	_ engine.Executor      = (*Executor)(nil)
+	_ engine.DesiredStater = (*Executor)(nil)
+)
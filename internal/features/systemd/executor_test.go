@@ -0,0 +1,486 @@
+package systemd
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thedataflows/confedit/internal/state"
+)
+
+// fakeSystemctl records systemctl invocations and returns canned output
+type fakeSystemctl struct {
+	calls  [][]string
+	output map[string]string
+}
+
+// run fails for subcommands without canned output, like systemctl on a host without systemd
+func (f *fakeSystemctl) run(args ...string) ([]byte, error) {
+	f.calls = append(f.calls, args)
+	command := args[0]
+	if command == "--user" {
+		command = args[1]
+	}
+	output, ok := f.output[command]
+	if !ok {
+		return nil, fmt.Errorf("systemctl %s: not available", command)
+	}
+	return []byte(output), nil
+}
+
+func newTestExecutor() (*Executor, *fakeSystemctl) {
+	fake := &fakeSystemctl{output: map[string]string{
+		"daemon-reload":     "",
+		"reload-or-restart": "",
+	}}
+	return &Executor{systemctl: fake.run}, fake
+}
+
+func TestConfig_UnitFilePath(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   Config
+		expected string
+	}{
+		{
+			name:     "default drop-in",
+			config:   Config{Unit: "nginx.service"},
+			expected: "/etc/systemd/system/nginx.service.d/confedit.conf",
+		},
+		{
+			name:     "named drop-in in custom dir",
+			config:   Config{Unit: "nginx.service", DropIn: "10-limits", UnitDir: "/run/systemd/system"},
+			expected: "/run/systemd/system/nginx.service.d/10-limits.conf",
+		},
+		{
+			name:     "in place",
+			config:   Config{Unit: "nginx.service", InPlace: true},
+			expected: "/etc/systemd/system/nginx.service",
+		},
+		{
+			name:     "in place absolute",
+			config:   Config{Unit: "/opt/app/app.service", InPlace: true},
+			expected: "/opt/app/app.service",
+		},
+		{
+			name:     "template instance",
+			config:   Config{Unit: "getty@tty1.service"},
+			expected: "/etc/systemd/system/getty@tty1.service.d/confedit.conf",
+		},
+		{
+			name:     "template of instance",
+			config:   Config{Unit: "getty@tty1.service", Template: true},
+			expected: "/etc/systemd/system/getty@.service.d/confedit.conf",
+		},
+		{
+			name:     "user scope",
+			config:   Config{Unit: "syncthing.service", Scope: ScopeUser},
+			expected: "/home/test/.config/systemd/user/syncthing.service.d/confedit.conf",
+		},
+	}
+
+	t.Setenv("XDG_CONFIG_HOME", "/home/test/.config")
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(tb *testing.T) {
+			assert.Equal(tb, tt.expected, tt.config.UnitFilePath())
+		})
+	}
+}
+
+func TestExecutor_ApplyWritesDropIn(t *testing.T) {
+	executor, fake := newTestExecutor()
+	unitDir := t.TempDir()
+
+	target := NewTarget("nginx", "nginx.service", "Service")
+	target.Config.UnitDir = unitDir
+	target.Config.Properties = map[string]interface{}{
+		"ExecStart":       []interface{}{"", "/usr/sbin/nginx -g 'daemon off;'"},
+		"Restart":         "always",
+		"RestartSec":      int64(5),
+		"NoNewPrivileges": true,
+	}
+
+	require.NoError(t, executor.Apply(target, nil))
+
+	content, err := os.ReadFile(filepath.Join(unitDir, "nginx.service.d", "confedit.conf"))
+	require.NoError(t, err)
+	assert.Equal(t, `[Service]
+ExecStart=
+ExecStart=/usr/sbin/nginx -g 'daemon off;'
+NoNewPrivileges=yes
+Restart=always
+RestartSec=5
+`, string(content))
+	assert.Equal(t, [][]string{{"daemon-reload"}}, fake.calls)
+
+	// A second apply with the same properties must not touch systemd again
+	fake.calls = nil
+	require.NoError(t, executor.Apply(target, nil))
+	assert.Empty(t, fake.calls)
+}
+
+func TestExecutor_ApplyReplacesSymlinkedDropIn(t *testing.T) {
+	executor, _ := newTestExecutor()
+	unitDir := t.TempDir()
+	shared := filepath.Join(t.TempDir(), "shared.conf")
+	require.NoError(t, os.WriteFile(shared, []byte("[Service]\nNice=1\n"), 0644))
+	dropIn := filepath.Join(unitDir, "app.service.d", "confedit.conf")
+	require.NoError(t, os.MkdirAll(filepath.Dir(dropIn), 0755))
+	require.NoError(t, os.Symlink(shared, dropIn))
+
+	target := NewTarget("app", "app.service", "Service")
+	target.Config.UnitDir = unitDir
+	target.Config.ReplaceSymlinks = true
+	target.Config.Properties = map[string]interface{}{"Nice": int64(5)}
+
+	require.NoError(t, executor.Apply(target, nil))
+
+	info, err := os.Lstat(dropIn)
+	require.NoError(t, err)
+	assert.True(t, info.Mode().IsRegular())
+	content, err := os.ReadFile(shared)
+	require.NoError(t, err)
+	assert.Equal(t, "[Service]\nNice=1\n", string(content))
+}
+
+func TestExecutor_ApplyInPlacePreservesUnit(t *testing.T) {
+	executor, _ := newTestExecutor()
+	unitDir := t.TempDir()
+	unitPath := filepath.Join(unitDir, "app.service")
+
+	require.NoError(t, os.WriteFile(unitPath, []byte(`# Local application
+[Unit]
+Description=App
+
+[Service]
+ExecStart=/usr/bin/app
+User=app
+
+[Install]
+WantedBy=multi-user.target
+`), 0644))
+
+	target := NewTarget("app", "app.service", "Service")
+	target.Config.UnitDir = unitDir
+	target.Config.InPlace = true
+	target.Config.Properties = map[string]interface{}{
+		"User":  "nobody",
+		"Group": "nogroup",
+	}
+
+	require.NoError(t, executor.Apply(target, nil))
+
+	content, err := os.ReadFile(unitPath)
+	require.NoError(t, err)
+	assert.Equal(t, `# Local application
+[Unit]
+Description=App
+
+[Service]
+ExecStart=/usr/bin/app
+User=nobody
+Group=nogroup
+
+[Install]
+WantedBy=multi-user.target
+`, string(content))
+}
+
+func TestExecutor_CurrentStateMatchesAppliedDropIn(t *testing.T) {
+	executor, _ := newTestExecutor()
+
+	target := NewTarget("nginx", "nginx.service", "Service")
+	target.Config.UnitDir = t.TempDir()
+	target.Config.Properties = map[string]interface{}{
+		"ExecStart":       []interface{}{"", "/usr/sbin/nginx"},
+		"RestartSec":      "1min 30s",
+		"NoNewPrivileges": true,
+	}
+
+	current, err := executor.CurrentState(target)
+	require.NoError(t, err)
+	assert.Empty(t, current)
+
+	require.NoError(t, executor.Apply(target, nil))
+
+	current, err = executor.CurrentState(target)
+	require.NoError(t, err)
+
+	diff, err := state.NewManager("").ComputeDiffWithCurrent("nginx", target.Config.Properties, current)
+	require.NoError(t, err)
+	assert.True(t, diff.IsEmpty(), "unexpected diff: %s", diff.FormatPlain())
+}
+
+func TestExecutor_CurrentStateFromSystemctl(t *testing.T) {
+	executor, fake := newTestExecutor()
+	fake.output["cat"] = `# /usr/lib/systemd/system/nginx.service
+[Service]
+ExecStart=/usr/sbin/nginx -g 'daemon on;'
+Restart=on-failure
+
+# /etc/systemd/system/nginx.service.d/override.conf
+[Service]
+ExecStart=
+ExecStart=/usr/sbin/nginx
+`
+	fake.output["show"] = `Id=nginx.service
+Restart=on-failure
+RestartUSec=1min 30s
+NoNewPrivileges=yes
+After=system.slice network.target
+LimitNOFILE=1024
+`
+
+	target := NewTarget("nginx", "nginx.service", "Service")
+	target.Config.Properties = map[string]interface{}{
+		"ExecStart":       []interface{}{"", "/usr/sbin/nginx"},
+		"Restart":         "always",
+		"RestartSec":      int64(90),
+		"NoNewPrivileges": true,
+		"After":           []interface{}{"network.target", "system.slice"},
+		"LimitNOFILE":     int64(65536),
+		"ProtectHome":     "yes",
+	}
+
+	current, err := executor.CurrentState(target)
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]interface{}{
+		"ExecStart":       []interface{}{"", "/usr/sbin/nginx"},
+		"Restart":         "on-failure",
+		"RestartSec":      int64(90),
+		"NoNewPrivileges": true,
+		"After":           []interface{}{"network.target", "system.slice"},
+		"LimitNOFILE":     "1024",
+	}, current)
+}
+
+func TestParseTimespan(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected time.Duration
+		ok       bool
+	}{
+		{"90", 90 * time.Second, true},
+		{"1min 30s", 90 * time.Second, true},
+		{"1min30s", 90 * time.Second, true},
+		{"1.5h", 90 * time.Minute, true},
+		{"100ms", 100 * time.Millisecond, true},
+		{"2 weeks", 14 * 24 * time.Hour, true},
+		{"infinity", math.MaxInt64, true},
+		{"soon", 0, false},
+		{"", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(tb *testing.T) {
+			got, ok := parseTimespan(tt.input)
+			assert.Equal(tb, tt.ok, ok)
+			assert.Equal(tb, tt.expected, got)
+		})
+	}
+}
+
+func TestExecutor_ApplyConvergesLifecycle(t *testing.T) {
+	enabled, disabled := true, false
+
+	tests := []struct {
+		name      string
+		isEnabled string
+		isActive  string
+		configure func(config *Config)
+		expected  [][]string
+	}{
+		{
+			name:      "enable and start",
+			isEnabled: "disabled",
+			isActive:  "inactive",
+			configure: func(config *Config) {
+				config.Enabled = &enabled
+				config.Active = &enabled
+			},
+			expected: [][]string{{"enable", "app.service"}, {"start", "app.service"}},
+		},
+		{
+			name:      "already converged",
+			isEnabled: "enabled",
+			isActive:  "active",
+			configure: func(config *Config) {
+				config.Enabled = &enabled
+				config.Active = &enabled
+				config.Reload = true
+			},
+			expected: nil,
+		},
+		{
+			name:      "unmask before enabling",
+			isEnabled: "masked",
+			isActive:  "inactive",
+			configure: func(config *Config) {
+				config.Enabled = &enabled
+			},
+			expected: [][]string{{"unmask", "app.service"}, {"enable", "app.service"}},
+		},
+		{
+			name:      "mask and stop",
+			isEnabled: "enabled",
+			isActive:  "failed",
+			configure: func(config *Config) {
+				config.Masked = &enabled
+				config.Active = &disabled
+			},
+			expected: [][]string{{"mask", "app.service"}},
+		},
+		{
+			name:      "disable and stop",
+			isEnabled: "enabled",
+			isActive:  "active",
+			configure: func(config *Config) {
+				config.Enabled = &disabled
+				config.Active = &disabled
+			},
+			expected: [][]string{{"disable", "app.service"}, {"stop", "app.service"}},
+		},
+		{
+			name:      "static unit is not enabled",
+			isEnabled: "static",
+			isActive:  "active",
+			configure: func(config *Config) {
+				config.Enabled = &enabled
+			},
+			expected: nil,
+		},
+		{
+			name:      "runtime enabled unit is left as is",
+			isEnabled: "enabled-runtime",
+			isActive:  "active",
+			configure: func(config *Config) {
+				config.Enabled = &enabled
+			},
+			expected: nil,
+		},
+		{
+			name:      "missing unit is already disabled",
+			isEnabled: "not-found",
+			isActive:  "inactive",
+			configure: func(config *Config) {
+				config.Enabled = &disabled
+			},
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(tb *testing.T) {
+			executor, fake := newTestExecutor()
+			for _, command := range []string{"enable", "disable", "start", "stop", "mask", "unmask"} {
+				fake.output[command] = ""
+			}
+			fake.output["is-enabled"] = tt.isEnabled + "\n"
+			fake.output["is-active"] = tt.isActive + "\n"
+
+			target := NewTarget("app", "app.service", "Service")
+			target.Config.UnitDir = tb.TempDir()
+			tt.configure(target.Config)
+
+			require.NoError(tb, executor.Apply(target, nil))
+
+			var calls [][]string
+			for _, call := range fake.calls {
+				if call[0] != "is-enabled" && call[0] != "is-active" {
+					calls = append(calls, call)
+				}
+			}
+			assert.Equal(tb, tt.expected, calls)
+		})
+	}
+}
+
+func TestExecutor_ReloadOnlyWhenUnitChanged(t *testing.T) {
+	executor, fake := newTestExecutor()
+	fake.output["is-enabled"] = "enabled\n"
+	fake.output["is-active"] = "active\n"
+
+	target := NewTarget("app", "app.service", "Service")
+	target.Config.UnitDir = t.TempDir()
+	target.Config.Reload = true
+	target.Config.Properties = map[string]interface{}{"Nice": int64(5)}
+
+	require.NoError(t, executor.Apply(target, nil))
+	assert.Contains(t, fake.calls, []string{"reload-or-restart", "app.service"})
+
+	fake.calls = nil
+	require.NoError(t, executor.Apply(target, nil))
+	assert.NotContains(t, fake.calls, []string{"reload-or-restart", "app.service"})
+}
+
+func TestExecutor_LifecycleState(t *testing.T) {
+	executor, fake := newTestExecutor()
+	fake.output["is-enabled"] = "enabled-runtime\n"
+	fake.output["is-active"] = "activating\n"
+
+	enabled := true
+	target := NewTarget("app", "app.service", "Service")
+	target.Config.UnitDir = t.TempDir()
+	target.Config.Enabled = &enabled
+	target.Config.Active = &enabled
+
+	desired, err := executor.DesiredState(target)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{StateEnabled: "enabled", StateActive: "active"}, desired)
+
+	current, err := executor.CurrentState(target)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{StateEnabled: "enabled", StateActive: "active"}, current)
+}
+
+func TestExecutor_EnableMissingUnit(t *testing.T) {
+	executor, fake := newTestExecutor()
+	fake.output["enable"] = ""
+	fake.output["is-enabled"] = "not-found\n"
+	fake.output["is-active"] = "inactive\n"
+
+	enabled := true
+	target := NewTarget("app", "app.service", "Service")
+	target.Config.UnitDir = t.TempDir()
+	target.Config.Enabled = &enabled
+
+	err := executor.Apply(target, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unit not found")
+	assert.NotContains(t, fake.calls, []string{"enable", "app.service"})
+}
+
+func TestTemplateName(t *testing.T) {
+	assert.Equal(t, "getty@.service", TemplateName("getty@tty1.service"))
+	assert.Equal(t, "getty@.service", TemplateName("getty@.service"))
+	assert.Equal(t, "container@.service", TemplateName("container@my.app.service"))
+	assert.Equal(t, "nginx.service", TemplateName("nginx.service"))
+}
+
+func TestExecutor_UserScope(t *testing.T) {
+	executor, fake := newTestExecutor()
+	fake.output["is-enabled"] = "disabled\n"
+	fake.output["is-active"] = "inactive\n"
+	fake.output["enable"] = ""
+
+	enabled := true
+	target := NewTarget("syncthing", "syncthing.service", "Service")
+	target.Config.Scope = ScopeUser
+	target.Config.UnitDir = t.TempDir()
+	target.Config.Enabled = &enabled
+	target.Config.Properties = map[string]interface{}{"Nice": int64(10)}
+
+	require.NoError(t, executor.Apply(target, nil))
+
+	for _, call := range fake.calls {
+		assert.Equal(t, "--user", call[0], "call %v should target the user manager", call)
+	}
+	assert.Contains(t, fake.calls, []string{"--user", "enable", "syncthing.service"})
+}
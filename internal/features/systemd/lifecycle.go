@@ -0,0 +1,116 @@
+package systemd
+
+import (
+	"fmt"
+	"strings"
+
+	log "github.com/thedataflows/go-lib-log"
+)
+
+// unitStatus returns the normalized `systemctl is-enabled` and `is-active` status of the unit.
+// Both commands exit non-zero for disabled or inactive units, so only their output is used.
+func (e *Executor) unitStatus(config *Config) (string, string) {
+	enabledOutput, _ := e.run(config, "is-enabled", config.Unit)
+	activeOutput, _ := e.run(config, "is-active", config.Unit)
+	return normalizeEnabled(string(enabledOutput), config.DesiredEnabled()), normalizeActive(string(activeOutput))
+}
+
+// normalizeEnabled maps `systemctl is-enabled` output onto the values produced by
+// Config.DesiredEnabled. Units whose enablement is not controlled by enable/disable
+// (static, indirect, generated, transient) are reported as enabled or disabled as desired,
+// and so is a missing unit that should be disabled.
+func normalizeEnabled(output, desired string) string {
+	status := strings.TrimSpace(output)
+	switch status {
+	case "enabled", "enabled-runtime", "alias":
+		status = "enabled"
+	case "masked", "masked-runtime":
+		status = "masked"
+	case "static", "indirect", "generated", "transient":
+		if desired == "enabled" || desired == "disabled" {
+			status = desired
+		}
+	case "", "not-found":
+		status = "not-found"
+		if desired == "disabled" {
+			status = desired
+		}
+	}
+
+	if desired == "unmasked" && status != "masked" {
+		return "unmasked"
+	}
+	return status
+}
+
+// normalizeActive maps `systemctl is-active` output onto "active" or "inactive"
+func normalizeActive(output string) string {
+	switch strings.TrimSpace(output) {
+	case "active", "activating", "reloading", "refreshing":
+		return "active"
+	}
+	return "inactive"
+}
+
+// convergeLifecycle enables/disables, masks/unmasks and starts/stops the unit when its
+// current status differs from the desired one. The unit is reloaded or restarted only when
+// reload is requested, its configuration changed and it was already running.
+func (e *Executor) convergeLifecycle(config *Config, unitChanged bool) error {
+	if config.DesiredEnabled() == "" && config.DesiredActive() == "" && !(config.Reload && unitChanged) {
+		return nil
+	}
+
+	enabled, active := e.unitStatus(config)
+
+	if desired := config.DesiredEnabled(); desired != "" && desired != enabled {
+		var commands [][]string
+		switch desired {
+		case "masked":
+			commands = append(commands, []string{"mask", config.Unit})
+		case "unmasked":
+			commands = append(commands, []string{"unmask", config.Unit})
+		case "enabled":
+			if enabled == "not-found" {
+				return fmt.Errorf("enable %s: unit not found", config.Unit)
+			}
+			if enabled == "masked" {
+				commands = append(commands, []string{"unmask", config.Unit})
+			}
+			commands = append(commands, []string{"enable", config.Unit})
+		case "disabled":
+			if enabled == "masked" {
+				commands = append(commands, []string{"unmask", config.Unit})
+			}
+			commands = append(commands, []string{"disable", config.Unit})
+		}
+
+		for _, args := range commands {
+			log.Debugf("systemd-executor", "Running systemctl %s", strings.Join(args, " "))
+			if _, err := e.run(config, args...); err != nil {
+				return fmt.Errorf("%s %s: %w", args[0], config.Unit, err)
+			}
+		}
+	}
+
+	if desired := config.DesiredActive(); desired != "" && desired != active {
+		command := "start"
+		if desired == "inactive" {
+			command = "stop"
+		}
+		log.Debugf("systemd-executor", "Running systemctl %s %s", command, config.Unit)
+		if _, err := e.run(config, command, config.Unit); err != nil {
+			return fmt.Errorf("%s %s: %w", command, config.Unit, err)
+		}
+		// A freshly started unit already runs with the new configuration
+		return nil
+	}
+
+	if config.Reload && unitChanged && active == "active" {
+		log.Debugf("systemd-executor", "Running systemctl reload-or-restart %s", config.Unit)
+		if _, err := e.run(config, "reload-or-restart", config.Unit); err != nil {
+			return fmt.Errorf("reload-or-restart %s: %w", config.Unit, err)
+		}
+	}
+
+	return nil
+}
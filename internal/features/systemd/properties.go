@@ -0,0 +1,204 @@
+package systemd
+
+import (
+	"bufio"
+	"bytes"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/thedataflows/confedit/internal/features/file/formats/ini/iniparser"
+)
+
+// parseShowOutput parses `systemctl show` output (one Key=Value per line) into a map
+func parseShowOutput(output []byte) map[string]string {
+	result := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		key, value, found := strings.Cut(scanner.Text(), "=")
+		if !found || key == "" {
+			continue
+		}
+		result[key] = value
+	}
+	return result
+}
+
+// parseUnitSection returns all assignments of section from unit file content, keyed
+// by property name in file order. Content may be the concatenation of a unit and its
+// drop-ins as printed by `systemctl cat`, since file separators are plain comments.
+func parseUnitSection(data []byte, section string) (map[string][]string, error) {
+	lines, err := iniparser.NewRelaxedINIParser().Parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string][]string)
+	for _, line := range lines {
+		if line.IsSection || line.CommentPrefix != "" || line.Key == "" || line.Section != section {
+			continue
+		}
+		result[line.Key] = append(result[line.Key], strings.TrimSpace(line.Value))
+	}
+	return result, nil
+}
+
+// lookupShowProperty finds the `systemctl show` counterpart of a unit file property.
+// Time settings such as RestartSec= are exposed by systemctl as RestartUSec.
+func lookupShowProperty(show map[string]string, key string) (string, bool) {
+	if value, ok := show[key]; ok {
+		return value, true
+	}
+	if base, ok := strings.CutSuffix(key, "Sec"); ok {
+		value, ok := show[base+"USec"]
+		return value, ok
+	}
+	return "", false
+}
+
+// effectiveValues applies systemd reset semantics to a sequence of assignments:
+// an empty assignment clears everything assigned before it
+func effectiveValues(values []string) []string {
+	result := []string{}
+	for _, value := range values {
+		if value == "" {
+			result = result[:0]
+			continue
+		}
+		result = append(result, value)
+	}
+	return result
+}
+
+// normalizeProperty converts the current assignments of a property into the
+// representation used by the desired value, so that semantically equal values
+// (e.g. "yes" and true, "1min 30s" and "90s") compare equal in the state diff.
+// When the values differ, the current value is returned as found on the system.
+// Set unordered when the assignments come from `systemctl show`, which joins lists
+// with spaces in its own order.
+func normalizeProperty(key string, desired interface{}, current []string, unordered bool) interface{} {
+	if desiredList, ok := desired.([]interface{}); ok {
+		want := effectiveValues(propertyValues(desiredList))
+		have := effectiveValues(current)
+		if unordered {
+			want = slices.Sorted(slices.Values(want))
+			have = slices.Sorted(slices.Values(have))
+		}
+		if slices.Equal(want, have) {
+			return desired
+		}
+		result := make([]interface{}, 0, len(have))
+		for _, value := range have {
+			result = append(result, value)
+		}
+		return result
+	}
+
+	have := ""
+	if len(current) > 0 {
+		have = current[len(current)-1]
+	}
+	if propertyValueEqual(key, formatProperty(desired), have) {
+		return desired
+	}
+	return have
+}
+
+// propertyValueEqual compares two scalar property values using systemd's parsing rules
+func propertyValueEqual(key, want, have string) bool {
+	if want == have {
+		return true
+	}
+
+	if wantBool, ok := parseBool(want); ok {
+		if haveBool, ok := parseBool(have); ok {
+			return wantBool == haveBool
+		}
+	}
+
+	if strings.HasSuffix(key, "Sec") || strings.HasSuffix(key, "USec") {
+		if wantSpan, ok := parseTimespan(want); ok {
+			if haveSpan, ok := parseTimespan(have); ok {
+				return wantSpan == haveSpan
+			}
+		}
+	}
+
+	return false
+}
+
+// parseBool parses a boolean the way systemd does
+func parseBool(value string) (bool, bool) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "1", "yes", "y", "true", "t", "on":
+		return true, true
+	case "0", "no", "n", "false", "f", "off":
+		return false, true
+	}
+	return false, false
+}
+
+// timespanUnits maps systemd.time(7) unit suffixes to their duration
+var timespanUnits = map[string]time.Duration{
+	"us": time.Microsecond, "usec": time.Microsecond, "µs": time.Microsecond,
+	"ms": time.Millisecond, "msec": time.Millisecond,
+	"s": time.Second, "sec": time.Second, "second": time.Second, "seconds": time.Second,
+	"m": time.Minute, "min": time.Minute, "minute": time.Minute, "minutes": time.Minute,
+	"h": time.Hour, "hr": time.Hour, "hour": time.Hour, "hours": time.Hour,
+	"d": 24 * time.Hour, "day": 24 * time.Hour, "days": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour, "week": 7 * 24 * time.Hour, "weeks": 7 * 24 * time.Hour,
+	"M": 2629800 * time.Second, "month": 2629800 * time.Second, "months": 2629800 * time.Second,
+	"y": 31557600 * time.Second, "year": 31557600 * time.Second, "years": 31557600 * time.Second,
+}
+
+// parseTimespan parses a systemd time span such as "90", "1min 30s", "1.5h" or "infinity".
+// Numbers without a unit are seconds. Infinity is returned as math.MaxInt64.
+func parseTimespan(value string) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if value == "infinity" {
+		return math.MaxInt64, true
+	}
+
+	var total time.Duration
+	for i := 0; i < len(value); {
+		for i < len(value) && value[i] == ' ' {
+			i++
+		}
+		if i >= len(value) {
+			break
+		}
+
+		start := i
+		for i < len(value) && (value[i] >= '0' && value[i] <= '9' || value[i] == '.') {
+			i++
+		}
+		number, err := strconv.ParseFloat(value[start:i], 64)
+		if err != nil {
+			return 0, false
+		}
+
+		for i < len(value) && value[i] == ' ' {
+			i++
+		}
+		unitStart := i
+		for i < len(value) && (value[i] < '0' || value[i] > '9') && value[i] != ' ' && value[i] != '.' {
+			i++
+		}
+
+		unit := time.Second
+		if suffix := value[unitStart:i]; suffix != "" {
+			var ok bool
+			if unit, ok = timespanUnits[suffix]; !ok {
+				return 0, false
+			}
+		}
+		total += time.Duration(number * float64(unit))
+	}
+	return total, true
+}
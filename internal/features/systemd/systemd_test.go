@@ -26,6 +26,7 @@ func TestSystemdFeature_Executor(t *testing.T) {
 
 func TestSystemdFeature_Validate(t *testing.T) {
 	feature := systemd.New()
+	enabled := true
 
 	tests := []struct {
 		name    string
@@ -54,6 +55,34 @@ func TestSystemdFeature_Validate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "invalid scope",
+			config: &systemd.Config{
+				Unit:    "nginx.service",
+				Section: "Service",
+				Scope:   "global",
+			},
+			wantErr: true,
+		},
+		{
+			name: "template without instance",
+			config: &systemd.Config{
+				Unit:     "nginx.service",
+				Section:  "Service",
+				Template: true,
+			},
+			wantErr: true,
+		},
+		{
+			name: "masked and enabled",
+			config: &systemd.Config{
+				Unit:    "nginx.service",
+				Section: "Service",
+				Enabled: &enabled,
+				Masked:  &enabled,
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
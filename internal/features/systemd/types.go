@@ -2,11 +2,31 @@ package systemd
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/thedataflows/confedit/internal/types"
 	"github.com/thedataflows/confedit/internal/utils"
 )
 
+const (
+	// DefaultUnitDir is where administrator unit files and drop-ins live
+	DefaultUnitDir = "/etc/systemd/system"
+	// DefaultDropIn is the drop-in file name used when none is configured
+	DefaultDropIn = "confedit"
+
+	// ScopeSystem manages units of the system service manager
+	ScopeSystem = "system"
+	// ScopeUser manages units of the calling user's service manager (systemctl --user)
+	ScopeUser = "user"
+
+	// StateEnabled is the state key holding the `systemctl is-enabled` status
+	StateEnabled = "is-enabled"
+	// StateActive is the state key holding the `systemctl is-active` status
+	StateActive = "is-active"
+)
+
 // Config represents the configuration for a systemd target
 type Config struct {
 	Unit       string                 `json:"unit"`
@@ -14,6 +34,16 @@ type Config struct {
 	Properties map[string]interface{} `json:"properties"`
 	Backup     bool                   `json:"backup,omitempty"`
 	Reload     bool                   `json:"reload,omitempty"`
+	DropIn     string                 `json:"drop_in,omitempty"`  // Drop-in name (without .conf), defaults to "confedit"
+	InPlace    bool                   `json:"in_place,omitempty"` // Edit the unit file itself instead of a drop-in
+	UnitDir    string                 `json:"unit_dir,omitempty"` // Defaults to /etc/systemd/system or ~/.config/systemd/user
+	Scope      string                 `json:"scope,omitempty"`    // "system" (default) or "user"
+	Template   bool                   `json:"template,omitempty"` // Write overrides for the template of an instance unit
+	Enabled    *bool                  `json:"enabled,omitempty"`  // Desired `systemctl enable`/`disable` state
+	Active     *bool                  `json:"active,omitempty"`   // Desired `systemctl start`/`stop` state
+	Masked     *bool                  `json:"masked,omitempty"`   // Desired `systemctl mask`/`unmask` state
+	// Replace a symlinked unit file or drop-in instead of writing through it
+	ReplaceSymlinks bool `json:"replace_symlinks,omitempty"`
 }
 
 // Type implements TargetConfig interface
@@ -29,9 +59,113 @@ func (c *Config) Validate() error {
 	if c.Section == "" {
 		return fmt.Errorf("section is required for systemd target")
 	}
+	if strings.ContainsRune(c.DropIn, '/') {
+		return fmt.Errorf("drop_in must be a file name, got %s", c.DropIn)
+	}
+	if c.Scope != "" && c.Scope != ScopeSystem && c.Scope != ScopeUser {
+		return fmt.Errorf("invalid scope %s for systemd target (supported: system, user)", c.Scope)
+	}
+	if c.Template && !strings.Contains(filepath.Base(c.Unit), "@") {
+		return fmt.Errorf("template is set but %s is not a template instance", c.Unit)
+	}
+	if c.Masked != nil && *c.Masked {
+		if c.Enabled != nil && *c.Enabled {
+			return fmt.Errorf("masked unit %s cannot be enabled", c.Unit)
+		}
+		if c.Active != nil && *c.Active {
+			return fmt.Errorf("masked unit %s cannot be active", c.Unit)
+		}
+	}
 	return nil
 }
 
+// DesiredEnabled returns the desired `systemctl is-enabled` status, or "" if not managed
+func (c *Config) DesiredEnabled() string {
+	switch {
+	case c.Masked != nil && *c.Masked:
+		return "masked"
+	case c.Enabled != nil && *c.Enabled:
+		return "enabled"
+	case c.Enabled != nil:
+		return "disabled"
+	case c.Masked != nil:
+		// Unmasking only, whatever the enablement is
+		return "unmasked"
+	}
+	return ""
+}
+
+// DesiredActive returns the desired `systemctl is-active` status, or "" if not managed
+func (c *Config) DesiredActive() string {
+	switch {
+	case c.Active == nil:
+		return ""
+	case *c.Active:
+		return "active"
+	}
+	return "inactive"
+}
+
+// UnitFilePath returns the path of the file managed for this unit:
+// <unit_dir>/<unit>.d/<drop_in>.conf, or the unit file itself when in_place is set.
+// With template set, the template of an instance unit is used instead
+// (getty@tty1.service -> getty@.service). An absolute unit path is edited as-is
+// in in_place mode.
+func (c *Config) UnitFilePath() string {
+	if c.InPlace && filepath.IsAbs(c.Unit) {
+		return c.Unit
+	}
+
+	unit := filepath.Base(c.Unit)
+	if c.Template {
+		unit = TemplateName(unit)
+	}
+
+	if c.InPlace {
+		return filepath.Join(c.unitDir(), unit)
+	}
+
+	dropIn := c.DropIn
+	if dropIn == "" {
+		dropIn = DefaultDropIn
+	}
+	return filepath.Join(c.unitDir(), unit+".d", dropIn+".conf")
+}
+
+// unitDir returns the configured unit directory or the default one for the scope
+func (c *Config) unitDir() string {
+	if c.UnitDir != "" {
+		return c.UnitDir
+	}
+	if c.Scope != ScopeUser {
+		return DefaultUnitDir
+	}
+
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			home = "~"
+		}
+		configHome = filepath.Join(home, ".config")
+	}
+	return filepath.Join(configHome, "systemd", "user")
+}
+
+// TemplateName returns the template unit of an instance unit
+// (getty@tty1.service -> getty@.service). Other units are returned unchanged.
+func TemplateName(unit string) string {
+	prefix, rest, found := strings.Cut(unit, "@")
+	if !found {
+		return unit
+	}
+	dot := strings.LastIndex(rest, ".")
+	if dot == -1 {
+		return prefix + "@"
+	}
+	return prefix + "@" + rest[dot:]
+}
+
 // Target is a type alias for systemd targets
 type Target = types.BaseTarget[*Config]
 
@@ -64,6 +198,33 @@ func MergeConfig(existing, newTarget *Config) error {
 	if newTarget.Reload {
 		existing.Reload = true
 	}
+	if newTarget.DropIn != "" {
+		existing.DropIn = newTarget.DropIn
+	}
+	if newTarget.InPlace {
+		existing.InPlace = true
+	}
+	if newTarget.UnitDir != "" {
+		existing.UnitDir = newTarget.UnitDir
+	}
+	if newTarget.Scope != "" {
+		existing.Scope = newTarget.Scope
+	}
+	if newTarget.Template {
+		existing.Template = true
+	}
+	if newTarget.ReplaceSymlinks {
+		existing.ReplaceSymlinks = true
+	}
+	if newTarget.Enabled != nil {
+		existing.Enabled = newTarget.Enabled
+	}
+	if newTarget.Active != nil {
+		existing.Active = newTarget.Active
+	}
+	if newTarget.Masked != nil {
+		existing.Masked = newTarget.Masked
+	}
 
 	return nil
 }
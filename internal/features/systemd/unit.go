@@ -0,0 +1,91 @@
+package systemd
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/thedataflows/confedit/internal/features/file/formats/ini/iniparser"
+	"github.com/thedataflows/confedit/internal/utils"
+)
+
+// updateUnitFile writes properties into section of the unit file or drop-in at path.
+// Lines of unmanaged keys, comments and other sections are preserved. Returns true
+// when the file content changed.
+func updateUnitFile(path, section string, properties map[string]interface{}, opts utils.WriteOptions) (bool, error) {
+	original, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return false, fmt.Errorf("read unit file %s: %w", path, err)
+	}
+
+	parser := iniparser.NewRelaxedINIParser()
+	parser.SetUseSpacing(false)
+
+	lines, err := parser.Parse(original)
+	if err != nil {
+		return false, fmt.Errorf("parse unit file %s: %w", path, err)
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(properties)) {
+		lines = parser.SetValues(lines, section, key, propertyValues(properties[key]))
+	}
+
+	var buf bytes.Buffer
+	if err := parser.Serialize(lines, &buf); err != nil {
+		return false, fmt.Errorf("serialize unit file %s: %w", path, err)
+	}
+
+	if bytes.Equal(buf.Bytes(), original) {
+		return false, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return false, fmt.Errorf("create directory: %w", err)
+	}
+
+	if err := utils.WriteFileAtomic(path, buf.Bytes(), opts); err != nil {
+		return false, fmt.Errorf("write unit file %s: %w", path, err)
+	}
+
+	return true, nil
+}
+
+// propertyValues converts a property value into the unit file assignments for it.
+// Lists produce one assignment per item, so an empty string item emits a reset
+// (e.g. ["", "/usr/bin/foo"] clears ExecStart= before setting the new command).
+func propertyValues(value interface{}) []string {
+	switch v := value.(type) {
+	case nil:
+		return []string{""}
+	case []string:
+		return v
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			values = append(values, formatProperty(item))
+		}
+		return values
+	default:
+		return []string{formatProperty(v)}
+	}
+}
+
+// formatProperty renders a single scalar property value
+func formatProperty(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		if v {
+			return "yes"
+		}
+		return "no"
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -4,8 +4,10 @@ import (
 	"testing"
 
 	"github.com/thedataflows/confedit/internal/features"
+	"github.com/thedataflows/confedit/internal/features/block"
 	"github.com/thedataflows/confedit/internal/features/dconf"
 	"github.com/thedataflows/confedit/internal/features/file"
+	"github.com/thedataflows/confedit/internal/features/line"
 	"github.com/thedataflows/confedit/internal/features/sed"
 	"github.com/thedataflows/confedit/internal/features/systemd"
 	"github.com/thedataflows/confedit/internal/loader"
@@ -49,6 +51,8 @@ func TestInternalPackagesIntegration(t *testing.T) {
 	registry.Register(dconf.New())
 	registry.Register(sed.New())
 	registry.Register(systemd.New())
+	registry.Register(block.New())
+	registry.Register(line.New())
 
 	if !registry.Has(types.TYPE_FILE) {
 		t.Error("registry should have file feature")
@@ -62,6 +66,12 @@ func TestInternalPackagesIntegration(t *testing.T) {
 	if !registry.Has(types.TYPE_SYSTEMD) {
 		t.Error("registry should have systemd feature")
 	}
+	if !registry.Has(types.TYPE_BLOCK) {
+		t.Error("registry should have block feature")
+	}
+	if !registry.Has(types.TYPE_LINE) {
+		t.Error("registry should have line feature")
+	}
 
 	// Test getting executor from registry
 	executor, err := registry.Executor(types.TYPE_FILE)
@@ -94,6 +104,8 @@ func TestInternalPackagesIsolation(t *testing.T) {
 	_ = types.TYPE_DCONF
 	_ = types.TYPE_SED
 	_ = types.TYPE_SYSTEMD
+	_ = types.TYPE_BLOCK
+	_ = types.TYPE_LINE
 
 	t.Log("Internal packages are properly isolated")
 }
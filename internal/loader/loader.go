@@ -13,8 +13,10 @@ import (
 	"cuelang.org/go/cue"
 	"cuelang.org/go/cue/cuecontext"
 	"cuelang.org/go/cue/load"
+	"github.com/thedataflows/confedit/internal/features/block"
 	"github.com/thedataflows/confedit/internal/features/dconf"
 	"github.com/thedataflows/confedit/internal/features/file"
+	"github.com/thedataflows/confedit/internal/features/line"
 	"github.com/thedataflows/confedit/internal/features/sed"
 	"github.com/thedataflows/confedit/internal/features/systemd"
 	"github.com/thedataflows/confedit/internal/schema"
@@ -210,6 +212,14 @@ func (ccl *CueDataLoader) mergeTargets(existing types.AnyTarget, newTarget types
 		existingSystemd := existing.(*systemd.Target)
 		newSystemd := newTarget.(*systemd.Target)
 		return systemd.MergeConfig(existingSystemd.Config, newSystemd.Config)
+	case types.TYPE_BLOCK:
+		existingBlock := existing.(*block.Target)
+		newBlock := newTarget.(*block.Target)
+		return block.MergeConfig(existingBlock.Config, newBlock.Config)
+	case types.TYPE_LINE:
+		existingLine := existing.(*line.Target)
+		newLine := newTarget.(*line.Target)
+		return line.MergeConfig(existingLine.Config, newLine.Config)
 	default:
 		return fmt.Errorf("unsupported target type for merging: %s", existing.GetType())
 	}
@@ -376,6 +386,30 @@ func (ccl *CueDataLoader) createTarget(commonFields CommonTargetFields, configVa
 			Config:   config,
 		}, nil
 
+	case types.TYPE_BLOCK:
+		config := &block.Config{}
+		if err := configValue.Decode(config); err != nil {
+			return nil, fmt.Errorf("decode %s target config: %w", commonFields.Type, err)
+		}
+		return &block.Target{
+			Name:     commonFields.Name,
+			Type:     commonFields.Type,
+			Metadata: commonFields.Metadata,
+			Config:   config,
+		}, nil
+
+	case types.TYPE_LINE:
+		config := &line.Config{}
+		if err := configValue.Decode(config); err != nil {
+			return nil, fmt.Errorf("decode %s target config: %w", commonFields.Type, err)
+		}
+		return &line.Target{
+			Name:     commonFields.Name,
+			Type:     commonFields.Type,
+			Metadata: commonFields.Metadata,
+			Config:   config,
+		}, nil
+
 	default:
 		return nil, fmt.Errorf("unsupported target type: %s", commonFields.Type)
 	}
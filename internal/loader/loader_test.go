@@ -472,3 +472,40 @@ func TestCueConfigLoader_WithCustomSchema(t *testing.T) {
 	// Verify that validator was initialized (or warning was logged)
 	// This test mainly checks that the API works correctly
 }
+
+func TestCueConfigLoader_PatchValues(t *testing.T) {
+	newLoader := func(operations string) *CueDataLoader {
+		path := filepath.Join(t.TempDir(), "patch.cue")
+		content := `package config
+
+targets: [
+	{
+		name: "settings"
+		type: "file"
+		config: {
+			path: "/etc/app/settings.json"
+			format: "json"
+			content: {}
+			patch: [` + operations + `]
+		}
+	}
+]
+`
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		return NewCueDataLoader(path)
+	}
+
+	config, err := newLoader(`{op: "add", path: "/port", value: 8080}, {op: "test", path: "/proxy", value: null}`).Load()
+	require.NoError(t, err)
+	patch := config.Targets[0].(*file.Target).Config.Patch
+	require.Len(t, patch, 2)
+	assert.Equal(t, int64(8080), patch[0].Value)
+	assert.Nil(t, patch[1].Value)
+	assert.NoError(t, patch[1].Validate())
+
+	// A missing value is not taken for null
+	for _, op := range []string{"add", "replace", "test"} {
+		_, err := newLoader(`{op: "` + op + `", path: "/port"}`).Load()
+		assert.Error(t, err, op)
+	}
+}
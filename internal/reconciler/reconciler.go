@@ -3,11 +3,9 @@ package reconciler
 import (
 	"fmt"
 
+	"github.com/thedataflows/confedit/internal/engine"
 	"github.com/thedataflows/confedit/internal/features"
-	"github.com/thedataflows/confedit/internal/features/dconf"
 	"github.com/thedataflows/confedit/internal/features/file"
-	"github.com/thedataflows/confedit/internal/features/sed"
-	"github.com/thedataflows/confedit/internal/features/systemd"
 	"github.com/thedataflows/confedit/internal/state"
 	"github.com/thedataflows/confedit/internal/types"
 	"github.com/thedataflows/confedit/internal/utils"
@@ -76,7 +74,10 @@ func (r *ReconciliationEngine) reconcileTarget(target types.AnyTarget) error {
 	}
 
 	// Get the appropriate content based on target type
-	targetContent := r.getTargetContent(target)
+	targetContent, err := r.getTargetContent(target, executor)
+	if err != nil {
+		return fmt.Errorf("get desired state: %w", err)
+	}
 
 	// Compute diff with desired state
 	diff, err := r.stateManager.ComputeDiffWithCurrent(target.GetName(), targetContent, currentSystemState)
@@ -111,29 +112,19 @@ func (r *ReconciliationEngine) reconcileTarget(target types.AnyTarget) error {
 	return nil
 }
 
-func (r *ReconciliationEngine) getTargetContent(target types.AnyTarget) map[string]interface{} {
+func (r *ReconciliationEngine) getTargetContent(target types.AnyTarget, executor engine.Executor) (map[string]interface{}, error) {
+	// Executors that know better than the declared content provide the desired state themselves
+	if stater, ok := executor.(engine.DesiredStater); ok {
+		return stater.DesiredState(target)
+	}
+
 	switch target.GetType() {
 	case types.TYPE_FILE:
 		if fileTarget, ok := target.(*file.Target); ok {
-			return fileTarget.GetConfig().Content
-		}
-	case types.TYPE_DCONF:
-		if dconfTarget, ok := target.(*dconf.Target); ok {
-			return dconfTarget.GetConfig().Settings
-		}
-	case types.TYPE_SYSTEMD:
-		if systemdTarget, ok := target.(*systemd.Target); ok {
-			return systemdTarget.GetConfig().Properties
-		}
-	case types.TYPE_SED:
-		if sedTarget, ok := target.(*sed.Target); ok {
-			return map[string]interface{}{
-				"commands": sedTarget.GetConfig().Commands,
-				"path":     sedTarget.GetConfig().Path,
-			}
+			return fileTarget.GetConfig().Content, nil
 		}
 	}
-	return make(map[string]interface{})
+	return make(map[string]interface{}), nil
 }
 
 // Registry returns the feature registry
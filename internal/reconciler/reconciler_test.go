@@ -4,8 +4,10 @@ import (
 	"testing"
 
 	"github.com/thedataflows/confedit/internal/features"
+	"github.com/thedataflows/confedit/internal/features/block"
 	"github.com/thedataflows/confedit/internal/features/dconf"
 	"github.com/thedataflows/confedit/internal/features/file"
+	"github.com/thedataflows/confedit/internal/features/line"
 	"github.com/thedataflows/confedit/internal/features/sed"
 	"github.com/thedataflows/confedit/internal/features/systemd"
 	"github.com/thedataflows/confedit/internal/reconciler"
@@ -20,6 +22,8 @@ func TestReconciliationEngine_Creation(t *testing.T) {
 	registry.Register(dconf.New())
 	registry.Register(systemd.New())
 	registry.Register(sed.New())
+	registry.Register(block.New())
+	registry.Register(line.New())
 
 	// Create state manager
 	stateManager := state.NewManager("")
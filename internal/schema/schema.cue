@@ -18,10 +18,13 @@ import "list"
 	commented: "; " | "# "
 }
 
-#INIDeletedValue: {
+// Marks a key for removal instead of setting it
+#DeletedValue: {
 	deleted: true
 }
 
+#INIDeletedValue: #DeletedValue
+
 #INIValue: #INISimpleValue | #INICommentedValue | #INIDeletedValue
 
 // INI-specific configuration options with defaults
@@ -43,54 +46,223 @@ import "list"
 	}
 }
 
+// XML element content: its text, or a map of "@attribute" values, child elements
+// (a list when repeated) and "#text". Everything decodes as text, so values are strings.
+#XMLValue: string | #DeletedValue | [...#XMLValue] | {[string]: #XMLValue}
+
+// XML content: the root element name to its content
+#XMLContent: {
+	[string]: #XMLValue
+}
+
+// Java properties content: a flat map of keys such as "log.dirs" to string values
+#PropertiesContent: {
+	[key=string]: string | #DeletedValue
+}
+
+// Shell variable content (.env, /etc/default/*): variable names to string values;
+// names start with a letter or underscore
+#EnvContent: {
+	[string]: string | #DeletedValue
+	[!~"^[A-Za-z_][A-Za-z0-9_.-]*$"]: _|_
+}
+
+// Values of whitespace-delimited keys; a list writes one line per value
+#WhitespaceValue: string | [...string] | #DeletedValue
+
+// Whitespace-delimited content (sshd_config, ssh_config, chrony.conf): keys are
+// case-insensitive, and "Match ..."/"Host ..." keys hold the keys of their block
+#WhitespaceContent: {
+	[!~"^(?i)(match|host)(\\s|$)"]: #WhitespaceValue
+	[=~"^(?i)(match|host)(\\s|$)"]: {[string]: #WhitespaceValue} | #DeletedValue
+}
+
+// How a list in file content combines with the list already in the file
+#ArrayMerge: {
+	strategy: *"replace" | "append_unique" | "remove" | "merge_by_key"
+	// Field identifying items for merge_by_key, e.g. "name"
+	if strategy == "merge_by_key" {
+		key: string & !=""
+	}
+}
+
+// JSON Patch (RFC 6902) operation on the parsed file; paths are JSON Pointers like "/servers/0"
+#PatchOperation: {
+	op:   "add" | "remove" | "replace" | "move" | "copy" | "test"
+	path: "" | =~"^/"
+	if op == "move" || op == "copy" {
+		from: "" | =~"^/"
+	}
+	if op == "add" || op == "replace" || op == "test" {
+		value!: _
+	}
+	value?: _
+}
+
 // File configuration schema
 #FileConfig: {
 	path: string & !=""
-	format: "ini" | "yaml" | "toml" | "json" | "xml"
+	format: "ini" | "yaml" | "toml" | "json" | "jsonc" | "xml" | "properties" | "env" | "whitespace"
 	owner?: string
 	group?: string
 	mode?: string
 	backup: *true | bool
+	// Replace a symlink at path with a regular file instead of writing through it
+	replace_symlinks?: bool
+	// Merge strategies of lists in content, keyed by dotted path (e.g. "services.web.ports")
+	arrays?: [string]: #ArrayMerge
+	// RFC 7386 merge patch applied after content: null removes a key
+	merge_patch?: {...}
+	// RFC 6902 operations applied last; a failing "test" aborts the target
+	patch?: [...#PatchOperation]
 
 	if format == "ini" {
 		options?: #INIOptions
 		content: #INIContent
 	}
 
+	if format == "xml" {
+		content: #XMLContent
+	}
+
+	if format == "properties" {
+		content: #PropertiesContent
+	}
+
+	if format == "env" {
+		content: #EnvContent
+	}
+
+	if format == "whitespace" {
+		content: #WhitespaceContent
+	}
+
 	if format != "ini" {
+		// A #DeletedValue removes the key at any depth, e.g. {server: {legacy: {deleted: true}}}
 		content: {...}
 	}
 }
 
+// Dconf values with an explicit GVariant type, e.g. {value: 5, type: "u"}
+#DconfTypedValue: {
+	value: _
+	type:  string & !=""
+}
+
+// Dconf setting values; untyped list items must all have the type of the first item.
+// {deleted: true} resets a key, or a whole directory when the key ends with "/".
+#DconfValue: string | bool | int | float | [...] | #DconfTypedValue | #DeletedValue
+
 // Dconf configuration schema
 #DconfConfig: {
 	user?: string
 	schema: string & !=""
 	settings: {
-		[key=string]: string | bool | int | float | [...string]
+		[key=string]: #DconfValue
 	}
+
+	// System-wide database: <config_dir>/db/<database>.d/<keyfile> and locks/<keyfile>
+	database?: string & !="" & !~"/"
+	keyfile?: string & !="" & !~"/"
+	locks?: [...string]
+	// Profile in <config_dir>/profile/ that must read the database (e.g. "user")
+	profile?:    string & !="" & !~"/"
+	config_dir?: string & !=""
+	replace_symlinks?: bool
 }
 
+// Systemd property values; lists emit one assignment per item ("" resets the key)
+#SystemdValue: string | bool | int | float | null
+
 // Systemd configuration schema
 #SystemdConfig: {
 	unit: string & !=""
 	section: string & !=""
 	properties: {
-		[key=string]: string | bool | int | float
+		[key=string]: #SystemdValue | [...#SystemdValue]
 	}
 	reload: *false | bool
+	backup?: bool
+
+	// Drop-in file name written to <unit_dir>/<unit>.d/<drop_in>.conf
+	drop_in?: string & !="" & !~"/"
+
+	// Edit the unit file itself instead of writing a drop-in
+	in_place?: bool
+
+	// Directory holding unit files and drop-ins
+	unit_dir?: string & !=""
+
+	// Service manager: "user" maps to `systemctl --user` and ~/.config/systemd/user
+	scope: *"system" | "user"
+
+	// Write overrides for the template (getty@.service) of an instance unit (getty@tty1.service)
+	template?: bool
+
+	// Desired lifecycle state, converged only when it differs from the system
+	enabled?: bool
+	active?:  bool
+	masked?:  bool
+
+	replace_symlinks?: bool
+}
+
+// Structured line edit with Go RE2 expressions; exactly one action per operation
+#SedOperation: {
+	match?:          string & !=""
+	replace?:        string
+	occurrence?:     int & >=-1
+	delete?:         bool
+	ensure_present?: string & !=""
+	insert_after?:   string & !=""
+	insert_before?:  string & !=""
 }
 
-// Sed configuration schema
+// Sed configuration schema; raw commands run before operations
 #SedConfig: {
 	path: string & !=""
-	commands: [...string] & list.MinItems(1)
+	commands?: [...string]
+	operations?: [...#SedOperation]
+	if operations == _|_ {
+		commands: [...string] & list.MinItems(1)
+	}
 	backup: *true | bool
+	replace_symlinks?: bool
 	options?: {
 		[key=string]: string | bool
 	}
 }
 
+// Managed block configuration schema; markers are "<comment_prefix> BEGIN/END confedit <name>"
+#BlockConfig: {
+	path: string & !=""
+	state: *"present" | "absent"
+	if state == "present" {
+		content: string
+	}
+	comment_prefix?: string & !=""
+	insert_after?:   string & !=""
+	insert_before?:  string & !=""
+	backup: *true | bool
+	replace_symlinks?: bool
+}
+
+// Line-in-file configuration schema
+#LineConfig: {
+	path: string & !=""
+	state: *"present" | "absent"
+	if state == "present" {
+		line: string & !=""
+	}
+	line?:          string & !=""
+	match?:         string & !=""
+	insert_after?:  string & !=""
+	insert_before?: string & !=""
+	create:         *false | bool
+	backup:         *true | bool
+	replace_symlinks?: bool
+}
+
 // Shell script validation
 #ShellScript: string & !=""
 
@@ -123,8 +295,22 @@ import "list"
 	config: #SedConfig
 }
 
+#BlockTarget: {
+	name!: string
+	type: "block"
+	metadata?: #Metadata
+	config: #BlockConfig
+}
+
+#LineTarget: {
+	name!: string
+	type: "line"
+	metadata?: #Metadata
+	config: #LineConfig
+}
+
 // Union of all target types
-#ConfigTarget: #FileTarget | #DconfTarget | #SystemdTarget | #SedTarget
+#ConfigTarget: #FileTarget | #DconfTarget | #SystemdTarget | #SedTarget | #BlockTarget | #LineTarget
 
 // Top-level system configuration
 #SystemConfig: {
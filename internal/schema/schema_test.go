@@ -6,8 +6,14 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
+	"github.com/thedataflows/confedit/internal/features/block"
+	"github.com/thedataflows/confedit/internal/features/dconf"
 	"github.com/thedataflows/confedit/internal/features/file"
+	"github.com/thedataflows/confedit/internal/features/line"
+	"github.com/thedataflows/confedit/internal/features/sed"
+	"github.com/thedataflows/confedit/internal/features/systemd"
 	"github.com/thedataflows/confedit/internal/types"
+	"github.com/thedataflows/confedit/internal/utils"
 )
 
 type SchemaTestSuite struct {
@@ -25,6 +31,24 @@ func (s *SchemaTestSuite) SetupTest() {
 	s.validator = validator
 }
 
+// fileConfig returns a system config with a single file target of format and content,
+// further set up by configure
+func fileConfig(format string, content map[string]interface{}, configure ...func(config *file.Config)) *types.SystemConfig {
+	config := &file.Config{
+		Path:    "/etc/app/config",
+		Format:  format,
+		Content: content,
+	}
+	for _, fn := range configure {
+		fn(config)
+	}
+	return &types.SystemConfig{
+		Targets: []types.AnyTarget{
+			&file.Target{Name: "app", Type: types.TYPE_FILE, Config: config},
+		},
+	}
+}
+
 func (s *SchemaTestSuite) TestNewSchemaValidator() {
 	validator, err := NewSchemaValidator()
 	require.NoError(s.T(), err)
@@ -292,6 +316,248 @@ func (s *SchemaTestSuite) TestValidate_InvalidFormat() {
 	assert.Error(s.T(), err)
 }
 
+func (s *SchemaTestSuite) TestValidate_FileXML() {
+	assert.NoError(s.T(), s.validator.Validate(fileConfig("xml", map[string]interface{}{
+		"config": map[string]interface{}{
+			"@version": "2",
+			"server": []interface{}{
+				"primary",
+				map[string]interface{}{"@id": "5", "#text": "backup"},
+			},
+			"legacy": map[string]interface{}{"deleted": true},
+		},
+	})))
+	assert.Error(s.T(), s.validator.Validate(fileConfig("xml", map[string]interface{}{
+		"config": map[string]interface{}{"port": 8080},
+	})))
+	assert.Error(s.T(), s.validator.Validate(fileConfig("xml", map[string]interface{}{
+		"config": map[string]interface{}{"@debug": true},
+	})))
+}
+
+func (s *SchemaTestSuite) TestValidate_FileArrays() {
+	newConfig := func(arrays map[string]utils.ArrayMerge) *types.SystemConfig {
+		return fileConfig("yaml", map[string]interface{}{
+			"services": map[string]interface{}{
+				"web": map[string]interface{}{"ports": []interface{}{"8080:80"}},
+			},
+		}, func(config *file.Config) { config.Arrays = arrays })
+	}
+
+	assert.NoError(s.T(), s.validator.Validate(newConfig(map[string]utils.ArrayMerge{
+		"services.web.ports": {Strategy: utils.ARRAY_APPEND_UNIQUE},
+		"users":              {Strategy: utils.ARRAY_MERGE_BY_KEY, Key: "name"},
+	})))
+	assert.Error(s.T(), s.validator.Validate(newConfig(map[string]utils.ArrayMerge{
+		"users": {Strategy: utils.ARRAY_MERGE_BY_KEY},
+	})))
+	assert.Error(s.T(), s.validator.Validate(newConfig(map[string]utils.ArrayMerge{
+		"users": {Strategy: "prepend"},
+	})))
+}
+
+func (s *SchemaTestSuite) TestValidate_FilePatch() {
+	newConfig := func(operation utils.PatchOperation) *types.SystemConfig {
+		return fileConfig("json", map[string]interface{}{}, func(config *file.Config) {
+			config.Patch = []utils.PatchOperation{operation}
+		})
+	}
+
+	assert.NoError(s.T(), s.validator.Validate(newConfig(utils.PatchOperation{Op: utils.PATCH_ADD, Path: "/port", Value: 8080})))
+	assert.NoError(s.T(), s.validator.Validate(newConfig(utils.PatchOperation{Op: utils.PATCH_REMOVE, Path: "/port"})))
+	assert.Error(s.T(), s.validator.Validate(newConfig(utils.PatchOperation{Op: utils.PATCH_ADD, Path: "/port"})))
+	assert.Error(s.T(), s.validator.Validate(newConfig(utils.PatchOperation{Op: utils.PATCH_TEST, Path: "/port"})))
+}
+
+func (s *SchemaTestSuite) TestValidate_FileDeletedKeys() {
+	assert.NoError(s.T(), s.validator.Validate(fileConfig("toml", map[string]interface{}{
+		"debug": map[string]interface{}{"deleted": true},
+		"server": map[string]interface{}{
+			"legacy": map[string]interface{}{"deleted": true},
+		},
+	})))
+}
+
+func (s *SchemaTestSuite) TestValidate_FileProperties() {
+	assert.NoError(s.T(), s.validator.Validate(fileConfig("properties", map[string]interface{}{
+		"log.dirs":  "/var/lib/kafka",
+		"broker.id": map[string]interface{}{"deleted": true},
+	})))
+	assert.Error(s.T(), s.validator.Validate(fileConfig("properties", map[string]interface{}{
+		"log": map[string]interface{}{"dirs": "/var/lib/kafka"},
+	})))
+}
+
+func (s *SchemaTestSuite) TestValidate_FileEnv() {
+	assert.NoError(s.T(), s.validator.Validate(fileConfig("env", map[string]interface{}{
+		"JAVA_OPTS": "-Xmx1g",
+		"LEGACY":    map[string]interface{}{"deleted": true},
+	})))
+	assert.Error(s.T(), s.validator.Validate(fileConfig("env", map[string]interface{}{
+		"1INVALID": "value",
+	})))
+	assert.Error(s.T(), s.validator.Validate(fileConfig("env", map[string]interface{}{
+		"PORT": 8080,
+	})))
+}
+
+func (s *SchemaTestSuite) TestValidate_FileWhitespace() {
+	assert.NoError(s.T(), s.validator.Validate(fileConfig("whitespace", map[string]interface{}{
+		"PermitRootLogin": "no",
+		"HostKey":         []interface{}{"/etc/ssh/ssh_host_ed25519_key", "/etc/ssh/ssh_host_rsa_key"},
+		"Protocol":        map[string]interface{}{"deleted": true},
+		"Match User backup": map[string]interface{}{
+			"ForceCommand": "internal-sftp",
+		},
+		"match address 10.0.0.0/8": map[string]interface{}{"deleted": true},
+	})))
+	assert.Error(s.T(), s.validator.Validate(fileConfig("whitespace", map[string]interface{}{
+		"Subsystem": map[string]interface{}{"sftp": "internal-sftp"},
+	})))
+	assert.Error(s.T(), s.validator.Validate(fileConfig("whitespace", map[string]interface{}{
+		"Match User backup": "internal-sftp",
+	})))
+}
+
+func (s *SchemaTestSuite) TestValidate_SystemdDropIn() {
+	config := &types.SystemConfig{
+		Targets: []types.AnyTarget{
+			&systemd.Target{
+				Name: "nginx-override",
+				Type: types.TYPE_SYSTEMD,
+				Config: &systemd.Config{
+					Unit:    "nginx.service",
+					Section: "Service",
+					DropIn:  "10-exec",
+					Properties: map[string]interface{}{
+						"ExecStart":  []interface{}{"", "/usr/sbin/nginx"},
+						"RestartSec": 5,
+					},
+				},
+			},
+		},
+	}
+
+	err := s.validator.Validate(config)
+	assert.NoError(s.T(), err)
+
+	config.Targets[0].(*systemd.Target).Config.DropIn = "../escape"
+	err = s.validator.Validate(config)
+	assert.Error(s.T(), err)
+}
+
+func (s *SchemaTestSuite) TestValidate_DconfTypedValues() {
+	config := &types.SystemConfig{
+		Targets: []types.AnyTarget{
+			&dconf.Target{
+				Name: "input-sources",
+				Type: types.TYPE_DCONF,
+				Config: &dconf.Config{
+					Schema: "/org/gnome/desktop/input-sources",
+					Settings: map[string]interface{}{
+						"sources":     []interface{}{[]interface{}{"xkb", "us"}},
+						"per-window":  false,
+						"delay":       map[string]interface{}{"value": 500, "type": "u"},
+						"repeat":      map[string]interface{}{"deleted": true},
+						"old-layout/": map[string]interface{}{"deleted": true},
+					},
+				},
+			},
+		},
+	}
+
+	err := s.validator.Validate(config)
+	assert.NoError(s.T(), err)
+
+	config.Targets[0].(*dconf.Target).Config.Settings["delay"] = map[string]interface{}{"value": 500}
+	err = s.validator.Validate(config)
+	assert.Error(s.T(), err)
+}
+
+func (s *SchemaTestSuite) TestValidate_DconfSystemDatabase() {
+	config := &types.SystemConfig{
+		Targets: []types.AnyTarget{
+			&dconf.Target{
+				Name: "desktop-defaults",
+				Type: types.TYPE_DCONF,
+				Config: &dconf.Config{
+					Schema:   "/org/gnome/desktop/",
+					Database: "local",
+					Locks:    []string{"session/idle-delay"},
+					Profile:  "user",
+					Settings: map[string]interface{}{
+						"session/idle-delay": 300,
+					},
+				},
+			},
+		},
+	}
+
+	err := s.validator.Validate(config)
+	assert.NoError(s.T(), err)
+
+	config.Targets[0].(*dconf.Target).Config.Database = "db/local"
+	err = s.validator.Validate(config)
+	assert.Error(s.T(), err)
+}
+
+func (s *SchemaTestSuite) TestValidate_SedOperations() {
+	replacement := "Port 2222"
+	config := &types.SystemConfig{
+		Targets: []types.AnyTarget{
+			&sed.Target{
+				Name: "sshd",
+				Type: types.TYPE_SED,
+				Config: &sed.Config{
+					Path: "/etc/ssh/sshd_config",
+					Operations: []sed.Operation{
+						{Match: `^#?PermitRootLogin\s`, EnsurePresent: "PermitRootLogin no"},
+						{Match: `^Port\s.*`, Replace: &replacement, Occurrence: -1},
+					},
+				},
+			},
+		},
+	}
+
+	err := s.validator.Validate(config)
+	assert.NoError(s.T(), err)
+
+	config.Targets[0].(*sed.Target).Config.Operations = nil
+	err = s.validator.Validate(config)
+	assert.Error(s.T(), err)
+}
+
+func (s *SchemaTestSuite) TestValidate_Block() {
+	config := &types.SystemConfig{
+		Targets: []types.AnyTarget{
+			block.NewTarget("hosts", "/etc/hosts", "10.0.0.1 build\n10.0.0.2 cache\n"),
+		},
+	}
+
+	err := s.validator.Validate(config)
+	assert.NoError(s.T(), err)
+
+	config.Targets[0].(*block.Target).Config.State = "gone"
+	err = s.validator.Validate(config)
+	assert.Error(s.T(), err)
+}
+
+func (s *SchemaTestSuite) TestValidate_Line() {
+	config := &types.SystemConfig{
+		Targets: []types.AnyTarget{
+			line.NewTarget("sshd-port", "/etc/ssh/sshd_config", "Port 2222"),
+		},
+	}
+	config.Targets[0].(*line.Target).Config.Match = `^#?Port\s`
+
+	err := s.validator.Validate(config)
+	assert.NoError(s.T(), err)
+
+	config.Targets[0].(*line.Target).Config.Line = ""
+	err = s.validator.Validate(config)
+	assert.Error(s.T(), err)
+}
+
 func TestSchemaValidator_RawConfig(t *testing.T) {
 	validator, err := NewSchemaValidator()
 	if err != nil {
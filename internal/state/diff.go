@@ -4,7 +4,9 @@ import (
 	"crypto/sha256"
 	"encoding/json"
 	"fmt"
+	"maps"
 	"reflect"
+	"slices"
 	"strings"
 
 	"github.com/thedataflows/confedit/internal/utils"
@@ -17,6 +19,9 @@ type ConfigDiff struct {
 	Added    map[string]interface{} `json:"added"`
 	Removed  []string               `json:"removed"`
 	Modified map[string]DiffValue   `json:"modified"`
+	// Items breaks modified lists down into their added, removed and changed items,
+	// keyed as "key[index]". It is only used to display the diff.
+	Items map[string]*ConfigDiff `json:"-"`
 }
 
 // DiffValue represents a before/after value pair
@@ -83,11 +88,17 @@ func (d *ConfigDiff) FormatDiff(colorSupport *utils.ColorSupport) string {
 	}
 
 	var parts []string
+	added, modified, removed := d.displayEntries()
 
 	// Format added keys
-	if len(d.Added) > 0 {
+	if len(added) > 0 {
 		parts = append(parts, colorSupport.Bold("  Add:"))
-		for key, value := range d.Added {
+		for key, value := range added {
+			if isMultiline(value) {
+				parts = append(parts, colorSupport.Green(fmt.Sprintf("    + %s:", key)))
+				parts = append(parts, formatUnified("", value.(string), colorSupport)...)
+				continue
+			}
 			formattedValue := formatValue(value, "    ")
 			line := fmt.Sprintf("    + %s = %s", key, formattedValue)
 			parts = append(parts, colorSupport.Green(line))
@@ -95,9 +106,19 @@ func (d *ConfigDiff) FormatDiff(colorSupport *utils.ColorSupport) string {
 	}
 
 	// Format modified keys
-	if len(d.Modified) > 0 {
+	if len(modified) > 0 {
 		parts = append(parts, colorSupport.Bold("  Change:"))
-		for key, diffValue := range d.Modified {
+		for key, diffValue := range modified {
+			// Text such as file content is shown as a unified diff of the lines that change
+			if isMultiline(diffValue.Old) || isMultiline(diffValue.New) {
+				oldText, oldIsText := diffValue.Old.(string)
+				newText, newIsText := diffValue.New.(string)
+				if oldIsText && newIsText {
+					parts = append(parts, colorSupport.Yellow(fmt.Sprintf("    ~ %s:", key)))
+					parts = append(parts, formatUnified(oldText, newText, colorSupport)...)
+					continue
+				}
+			}
 			oldValue := formatValue(diffValue.Old, "    ")
 			newValue := formatValue(diffValue.New, "    ")
 			line := fmt.Sprintf("    ~ %s = %s → %s", key, colorSupport.Red(oldValue), colorSupport.Green(newValue))
@@ -106,9 +127,9 @@ func (d *ConfigDiff) FormatDiff(colorSupport *utils.ColorSupport) string {
 	}
 
 	// Format removed keys
-	if len(d.Removed) > 0 {
+	if len(removed) > 0 {
 		parts = append(parts, colorSupport.Bold("  Remove:"))
-		for _, key := range d.Removed {
+		for _, key := range removed {
 			line := fmt.Sprintf("    - %s", key)
 			parts = append(parts, colorSupport.Red(line))
 		}
@@ -117,6 +138,29 @@ func (d *ConfigDiff) FormatDiff(colorSupport *utils.ColorSupport) string {
 	return strings.Join(parts, "\n")
 }
 
+// displayEntries returns the added, modified and removed keys to display, with
+// modified lists replaced by their item changes
+func (d *ConfigDiff) displayEntries() (map[string]interface{}, map[string]DiffValue, []string) {
+	if len(d.Items) == 0 {
+		return d.Added, d.Modified, d.Removed
+	}
+
+	added := maps.Clone(d.Added)
+	modified := make(map[string]DiffValue, len(d.Modified))
+	removed := slices.Clone(d.Removed)
+	for key, value := range d.Modified {
+		items, ok := d.Items[key]
+		if !ok {
+			modified[key] = value
+			continue
+		}
+		maps.Copy(added, items.Added)
+		maps.Copy(modified, items.Modified)
+		removed = append(removed, items.Removed...)
+	}
+	return added, modified, removed
+}
+
 // FormatPlain returns a plain text representation of the diff (no colors)
 func (d *ConfigDiff) FormatPlain() string {
 	// Use no-op color support to reuse FormatDiff logic
@@ -172,16 +216,101 @@ func ComputeFlatDiff(current, desired map[string]interface{}) *ConfigDiff {
 
 	// Remove deleted keys from desired state - they should only show as removals
 	for key, value := range flatDesired {
-		if iniValue, ok := value.(map[string]interface{}); ok {
-			if deleted, hasDeleted := iniValue["deleted"]; hasDeleted {
-				if deletedBool, ok := deleted.(bool); ok && deletedBool {
-					delete(flatDesired, key)
-				}
+		if utils.IsDeletedMarker(value) {
+			delete(flatDesired, key)
+		}
+	}
+
+	diff := ComputeDiff(flatCurrent, flatDesired)
+	expandArrayChanges(diff)
+	return diff
+}
+
+// expandArrayChanges records the items of changed lists that are added, removed or
+// changed in diff.Items. Lists whose items are only reordered are displayed whole.
+func expandArrayChanges(diff *ConfigDiff) {
+	for _, key := range slices.Sorted(maps.Keys(diff.Modified)) {
+		change := diff.Modified[key]
+		oldItems, oldIsList := change.Old.([]interface{})
+		newItems, newIsList := change.New.([]interface{})
+		if !oldIsList || !newIsList {
+			continue
+		}
+		removed := unmatchedItems(oldItems, newItems)
+		added := unmatchedItems(newItems, oldItems)
+		if len(removed) == 0 && len(added) == 0 {
+			continue
+		}
+
+		items := &ConfigDiff{
+			Changes:  make(map[string]interface{}),
+			Added:    make(map[string]interface{}),
+			Removed:  []string{},
+			Modified: make(map[string]DiffValue),
+		}
+		for _, i := range removed {
+			itemKey := fmt.Sprintf("%s[%d]", key, i)
+			// An item replaced at the same position is shown as a change
+			if slices.Contains(added, i) {
+				items.Modified[itemKey] = DiffValue{Old: oldItems[i], New: newItems[i]}
+				items.Changes[itemKey] = newItems[i]
+				continue
+			}
+			items.Removed = append(items.Removed, itemKey)
+		}
+		for _, i := range added {
+			if slices.Contains(removed, i) {
+				continue
+			}
+			itemKey := fmt.Sprintf("%s[%d]", key, i)
+			items.Added[itemKey] = newItems[i]
+			items.Changes[itemKey] = newItems[i]
+		}
+
+		if diff.Items == nil {
+			diff.Items = make(map[string]*ConfigDiff)
+		}
+		diff.Items[key] = items
+	}
+}
+
+// unmatchedItems returns the indexes of the items that have no equal counterpart
+// in others, each item of others matching at most once
+func unmatchedItems(items, others []interface{}) []int {
+	matched := make([]bool, len(others))
+	var unmatched []int
+	for i, item := range items {
+		found := false
+		for j, other := range others {
+			if !matched[j] && utils.EqualValues(item, other) {
+				matched[j] = true
+				found = true
+				break
 			}
 		}
+		if !found {
+			unmatched = append(unmatched, i)
+		}
 	}
+	return unmatched
+}
 
-	return ComputeDiff(flatCurrent, flatDesired)
+// formatUnified renders the unified diff of two texts as colored, indented lines
+func formatUnified(oldText, newText string, colorSupport *utils.ColorSupport) []string {
+	var lines []string
+	for _, line := range UnifiedDiff(oldText, newText, DiffContext) {
+		indented := "      " + line
+		switch line[0] {
+		case '@':
+			indented = colorSupport.Blue(indented)
+		case '-':
+			indented = colorSupport.Red(indented)
+		case '+':
+			indented = colorSupport.Green(indented)
+		}
+		lines = append(lines, indented)
+	}
+	return lines
 }
 
 // formatValue formats a value for display
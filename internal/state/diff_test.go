@@ -237,6 +237,41 @@ func (s *ComputeDiffTestSuite) TestComputeFlatDiff_WithDeletedKeys() {
 	assert.False(s.T(), diff.IsEmpty())
 }
 
+func (s *ComputeDiffTestSuite) TestComputeFlatDiff_ArrayElements() {
+	current := map[string]interface{}{
+		"plugins": []interface{}{"git", "docker", "ssh"},
+		"ports":   []interface{}{80, 443},
+		"order":   []interface{}{"a", "b"},
+	}
+	desired := map[string]interface{}{
+		"plugins": []interface{}{"git", "ssh", "kubectl"},
+		"ports":   []interface{}{80, 8443},
+		"order":   []interface{}{"b", "a"},
+	}
+
+	diff := ComputeFlatDiff(current, desired)
+
+	// Executors get the changed lists as a whole
+	assert.Equal(s.T(), desired, diff.Changes)
+	assert.Len(s.T(), diff.Modified, 3)
+	assert.Empty(s.T(), diff.Added)
+	assert.Empty(s.T(), diff.Removed)
+
+	// Only their items are displayed
+	assert.Equal(s.T(), []string{"plugins[1]"}, diff.Items["plugins"].Removed)
+	assert.Equal(s.T(), map[string]interface{}{"plugins[2]": "kubectl"}, diff.Items["plugins"].Added)
+	assert.Equal(s.T(), DiffValue{Old: 443, New: 8443}, diff.Items["ports"].Modified["ports[1]"])
+	plain := diff.FormatPlain()
+	assert.Contains(s.T(), plain, "- plugins[1]")
+	assert.Contains(s.T(), plain, `+ plugins[2] = "kubectl"`)
+	assert.Contains(s.T(), plain, "~ ports[1] = 443 → 8443")
+	assert.NotContains(s.T(), plain, "~ plugins =")
+
+	// Reordered lists are displayed as a whole
+	assert.NotContains(s.T(), diff.Items, "order")
+	assert.Contains(s.T(), plain, "~ order =")
+}
+
 func TestComputeDiffTestSuite(t *testing.T) {
 	suite.Run(t, new(ComputeDiffTestSuite))
 }
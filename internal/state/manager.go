@@ -1,5 +1,7 @@
 package state
 
+import "github.com/thedataflows/confedit/internal/utils"
+
 type Manager struct{}
 
 func NewManager(stateDir string) *Manager {
@@ -70,6 +72,11 @@ func (m *Manager) findKeyInCurrent(key string, current map[string]interface{}, r
 
 // filterKeyValue handles filtering of individual key-value pairs, with support for nested structures
 func (m *Manager) filterKeyValue(currentValue, desiredValue interface{}) interface{} {
+	// Keys marked for deletion are compared as a whole, so that all of it shows as removed
+	if utils.IsDeletedMarker(desiredValue) {
+		return currentValue
+	}
+
 	// For nested structures (like INI sections), recursively filter
 	if currentMap, ok := currentValue.(map[string]interface{}); ok {
 		if desiredMap, ok := desiredValue.(map[string]interface{}); ok {
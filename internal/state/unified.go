@@ -0,0 +1,202 @@
+package state
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// DiffContext is the number of unchanged lines shown around each change of a unified diff
+const DiffContext = 3
+
+// maxDiffDistance bounds the edit distance diffLines searches, and so its memory:
+// texts that differ by more lines are diffed as a whole replacement
+const maxDiffDistance = 2000
+
+// noNewline marks a last line without a trailing newline, like diff(1) does
+const noNewline = `\ No newline at end of file`
+
+// lineEdit is a single line of an edit script: ' ' kept, '-' removed, '+' added
+type lineEdit struct {
+	op      byte
+	line    string
+	oldLine int // index in the old text before this edit
+	newLine int // index in the new text before this edit
+}
+
+// UnifiedDiff returns the lines of a unified diff between oldText and newText, with
+// context unchanged lines around each change. Returns nil when the texts are equal.
+func UnifiedDiff(oldText, newText string, context int) []string {
+	if oldText == newText {
+		return nil
+	}
+
+	edits := diffLines(splitLines(oldText), splitLines(newText))
+
+	var result []string
+	for _, hunk := range groupHunks(edits, context) {
+		result = append(result, hunkHeader(hunk))
+		for _, edit := range hunk {
+			result = append(result, string(edit.op)+strings.TrimSuffix(edit.line, "\n"))
+			if !strings.HasSuffix(edit.line, "\n") {
+				result = append(result, noNewline)
+			}
+		}
+	}
+	return result
+}
+
+// isMultiline reports whether value is text that reads better as a unified diff
+func isMultiline(value interface{}) bool {
+	text, ok := value.(string)
+	return ok && strings.Contains(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// splitLines splits text into lines that keep their trailing newline
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines computes a shortest edit script from a to b with the Myers algorithm.
+// The trace keeps, for each distance d, only the diagonals -d..d that the walk back reads.
+func diffLines(a, b []string) []lineEdit {
+	n, m := len(a), len(b)
+	offset := n + m
+	v := make([]int, 2*offset+2)
+	var trace [][]int
+
+search:
+	for d := 0; d <= n+m; d++ {
+		if d > maxDiffDistance {
+			return replaceLines(a, b)
+		}
+		trace = append(trace, slices.Clone(v[offset-d:offset+d+1]))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	// Walk the trace back from the end to recover the edits
+	var edits []lineEdit
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d] // v[d+k] is diagonal k
+		k := x - y
+
+		var prevK int
+		if k == -d || (k != d && v[d+k-1] < v[d+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := 0
+		if d > 0 {
+			prevX = v[d+prevK]
+		}
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			edits = append(edits, lineEdit{op: ' ', line: a[x], oldLine: x, newLine: y})
+		}
+		if d == 0 {
+			break
+		}
+		if x == prevX {
+			y--
+			edits = append(edits, lineEdit{op: '+', line: b[y], oldLine: x, newLine: y})
+		} else {
+			x--
+			edits = append(edits, lineEdit{op: '-', line: a[x], oldLine: x, newLine: y})
+		}
+	}
+
+	slices.Reverse(edits)
+	return edits
+}
+
+// replaceLines returns the edit script that removes all of a and adds all of b
+func replaceLines(a, b []string) []lineEdit {
+	edits := make([]lineEdit, 0, len(a)+len(b))
+	for i, line := range a {
+		edits = append(edits, lineEdit{op: '-', line: line, oldLine: i})
+	}
+	for i, line := range b {
+		edits = append(edits, lineEdit{op: '+', line: line, oldLine: len(a), newLine: i})
+	}
+	return edits
+}
+
+// groupHunks splits an edit script into hunks of changes with surrounding context,
+// merging changes whose context would overlap
+func groupHunks(edits []lineEdit, context int) [][]lineEdit {
+	var hunks [][]lineEdit
+	start, end := -1, -1
+	for i, edit := range edits {
+		if edit.op == ' ' {
+			continue
+		}
+		if start >= 0 && i-context <= end {
+			end = min(i+context+1, len(edits))
+			continue
+		}
+		if start >= 0 {
+			hunks = append(hunks, edits[start:end])
+		}
+		start = max(i-context, 0)
+		end = min(i+context+1, len(edits))
+	}
+	if start >= 0 {
+		hunks = append(hunks, edits[start:end])
+	}
+	return hunks
+}
+
+// hunkHeader returns the "@@ -l,s +l,s @@" header of a hunk
+func hunkHeader(hunk []lineEdit) string {
+	oldCount, newCount := 0, 0
+	for _, edit := range hunk {
+		if edit.op != '+' {
+			oldCount++
+		}
+		if edit.op != '-' {
+			newCount++
+		}
+	}
+	return fmt.Sprintf("@@ -%s +%s @@",
+		hunkRange(hunk[0].oldLine, oldCount), hunkRange(hunk[0].newLine, newCount))
+}
+
+// hunkRange formats the 1-based line range of a hunk side; empty ranges refer to the
+// line before them
+func hunkRange(index, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", index)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", index+1)
+	}
+	return fmt.Sprintf("%d,%d", index+1, count)
+}
@@ -0,0 +1,112 @@
+package state
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		old      string
+		new      string
+		expected []string
+	}{
+		{
+			name:     "equal",
+			old:      "a\nb\n",
+			new:      "a\nb\n",
+			expected: nil,
+		},
+		{
+			name: "single change with context",
+			old:  "1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+			new:  "1\n2\n3\n4\nfive\n6\n7\n8\n9\n",
+			expected: []string{
+				"@@ -2,7 +2,7 @@",
+				" 2", " 3", " 4", "-5", "+five", " 6", " 7", " 8",
+			},
+		},
+		{
+			name: "distant changes make separate hunks",
+			old:  "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			new:  "one\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n",
+			expected: []string{
+				"@@ -1,4 +1,4 @@",
+				"-1", "+one", " 2", " 3", " 4",
+				"@@ -10,3 +10,4 @@",
+				" 10", " 11", " 12", "+13",
+			},
+		},
+		{
+			name: "nearby changes share a hunk",
+			old:  "1\n2\n3\n4\n5\n6\n7\n8\n",
+			new:  "1\ntwo\n3\n4\n5\n6\nseven\n8\n",
+			expected: []string{
+				"@@ -1,8 +1,8 @@",
+				" 1", "-2", "+two", " 3", " 4", " 5", " 6", "-7", "+seven", " 8",
+			},
+		},
+		{
+			name:     "new file",
+			old:      "",
+			new:      "a\nb\n",
+			expected: []string{"@@ -0,0 +1,2 @@", "+a", "+b"},
+		},
+		{
+			name:     "emptied file",
+			old:      "a\n",
+			new:      "",
+			expected: []string{"@@ -1 +0,0 @@", "-a"},
+		},
+		{
+			name: "missing trailing newline",
+			old:  "a\nb",
+			new:  "a\nb\n",
+			expected: []string{
+				"@@ -1,2 +1,2 @@",
+				" a", "-b", `\ No newline at end of file`, "+b",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(tb *testing.T) {
+			assert.Equal(tb, tt.expected, UnifiedDiff(tt.old, tt.new, DiffContext))
+		})
+	}
+}
+
+func TestFormatDiff_UnifiedContent(t *testing.T) {
+	diff := ComputeFlatDiff(
+		map[string]interface{}{"content": "PermitRootLogin yes\nPort 22\n", "exists": true},
+		map[string]interface{}{"content": "PermitRootLogin no\nPort 22\n", "exists": true},
+	)
+
+	assert.Equal(t, strings.Join([]string{
+		"  Change:",
+		"    ~ content:",
+		"      @@ -1,2 +1,2 @@",
+		"      -PermitRootLogin yes",
+		"      +PermitRootLogin no",
+		"       Port 22",
+	}, "\n"), diff.FormatPlain())
+}
+
+func TestUnifiedDiff_BeyondMaxDistance(t *testing.T) {
+	var old, new strings.Builder
+	lines := maxDiffDistance/2 + 1
+	for i := 0; i < lines; i++ {
+		fmt.Fprintf(&old, "old %d\n", i)
+		fmt.Fprintf(&new, "new %d\n", i)
+	}
+
+	diff := UnifiedDiff(old.String(), new.String(), DiffContext)
+	assert.Len(t, diff, 1+2*lines)
+	assert.Equal(t, fmt.Sprintf("@@ -1,%d +1,%d @@", lines, lines), diff[0])
+	assert.Equal(t, "-old 0", diff[1])
+	assert.Equal(t, "+new 0", diff[1+lines])
+}
@@ -7,6 +7,8 @@ const (
 	TYPE_DCONF   = "dconf"
 	TYPE_SYSTEMD = "systemd"
 	TYPE_SED     = "sed"
+	TYPE_BLOCK   = "block"
+	TYPE_LINE    = "line"
 )
 
 // AnyTarget is a union type for all possible target types
@@ -0,0 +1,171 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Array merge strategies for lists in desired content
+const (
+	ARRAY_REPLACE       = "replace"       // the desired list replaces the existing one
+	ARRAY_APPEND_UNIQUE = "append_unique" // desired items missing from the existing list are appended
+	ARRAY_REMOVE        = "remove"        // desired items are removed from the existing list
+	ARRAY_MERGE_BY_KEY  = "merge_by_key"  // desired items are merged into the existing item with the same key field
+)
+
+// ArrayMerge selects how a desired list is combined with the existing list at the same key
+type ArrayMerge struct {
+	Strategy string `json:"strategy,omitempty"`
+	Key      string `json:"key,omitempty"` // Field identifying items for merge_by_key
+}
+
+// Validate checks that the strategy is known and has the fields it needs
+func (a ArrayMerge) Validate() error {
+	switch a.Strategy {
+	case "", ARRAY_REPLACE, ARRAY_APPEND_UNIQUE, ARRAY_REMOVE:
+		return nil
+	case ARRAY_MERGE_BY_KEY:
+		if a.Key == "" {
+			return fmt.Errorf("key is required for the %s strategy", ARRAY_MERGE_BY_KEY)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported array strategy: %s (supported: %s, %s, %s, %s)",
+			a.Strategy, ARRAY_REPLACE, ARRAY_APPEND_UNIQUE, ARRAY_REMOVE, ARRAY_MERGE_BY_KEY)
+	}
+}
+
+// MergeArrays returns a copy of desired in which the lists at the keys of arrays,
+// dot-separated paths such as "services.web.ports", are combined with the lists at
+// the same paths in existing. The result can be deep-merged into existing.
+func MergeArrays(existing, desired map[string]interface{}, arrays map[string]ArrayMerge) (map[string]interface{}, error) {
+	return mergeArrays(existing, desired, arrays, "")
+}
+
+func mergeArrays(existing, desired map[string]interface{}, arrays map[string]ArrayMerge, prefix string) (map[string]interface{}, error) {
+	result := make(map[string]interface{}, len(desired))
+	for key, value := range desired {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+
+		switch v := value.(type) {
+		case map[string]interface{}:
+			existingMap, _ := existing[key].(map[string]interface{})
+			merged, err := mergeArrays(existingMap, v, arrays, path)
+			if err != nil {
+				return nil, err
+			}
+			result[key] = merged
+
+		case []interface{}:
+			strategy, ok := arrays[path]
+			if !ok {
+				result[key] = value
+				continue
+			}
+			existingItems, _ := existing[key].([]interface{})
+			merged, err := mergeItems(existingItems, v, strategy)
+			if err != nil {
+				return nil, fmt.Errorf("merge %s: %w", path, err)
+			}
+			result[key] = merged
+
+		default:
+			result[key] = value
+		}
+	}
+	return result, nil
+}
+
+// mergeItems combines an existing list with desired items according to strategy
+func mergeItems(existing, desired []interface{}, strategy ArrayMerge) ([]interface{}, error) {
+	switch strategy.Strategy {
+	case "", ARRAY_REPLACE:
+		return desired, nil
+
+	case ARRAY_APPEND_UNIQUE:
+		result := append([]interface{}{}, existing...)
+		for _, item := range desired {
+			if indexOf(result, item) < 0 {
+				result = append(result, item)
+			}
+		}
+		return result, nil
+
+	case ARRAY_REMOVE:
+		result := []interface{}{}
+		for _, item := range existing {
+			if indexOf(desired, item) < 0 {
+				result = append(result, item)
+			}
+		}
+		return result, nil
+
+	case ARRAY_MERGE_BY_KEY:
+		result := append([]interface{}{}, existing...)
+		for _, item := range desired {
+			desiredItem, ok := item.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("item %v is not a map with key %s", item, strategy.Key)
+			}
+			id, ok := desiredItem[strategy.Key]
+			if !ok {
+				return nil, fmt.Errorf("item %v has no key %s", item, strategy.Key)
+			}
+
+			i := indexOfKey(result, strategy.Key, id)
+			if i < 0 {
+				result = append(result, item)
+				continue
+			}
+			// Merge into a copy: the existing item belongs to the current state
+			merged := CopyValue(result[i]).(map[string]interface{})
+			if err := DeepMerge(merged, desiredItem); err != nil {
+				return nil, err
+			}
+			result[i] = merged
+		}
+		return result, nil
+
+	default:
+		return nil, ArrayMerge{Strategy: strategy.Strategy}.Validate()
+	}
+}
+
+// indexOf returns the index of the first item equal to value, -1 if there is none
+func indexOf(items []interface{}, value interface{}) int {
+	for i, item := range items {
+		if EqualValues(item, value) {
+			return i
+		}
+	}
+	return -1
+}
+
+// indexOfKey returns the index of the first map item whose key field equals id
+func indexOfKey(items []interface{}, key string, id interface{}) int {
+	for i, item := range items {
+		if m, ok := item.(map[string]interface{}); ok {
+			if value, ok := m[key]; ok && EqualValues(value, id) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// EqualValues reports whether two decoded values encode to the same JSON,
+// which ignores differences between numeric types of the parsers
+func EqualValues(a, b interface{}) bool {
+	left, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	right, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+	return string(left) == string(right)
+}
@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeArrays_Strategies(t *testing.T) {
+	existing := map[string]interface{}{
+		"plugins": []interface{}{"git", "docker"},
+		"servers": map[string]interface{}{
+			"hosts": []interface{}{"a", "b", "c"},
+		},
+		"users": []interface{}{
+			map[string]interface{}{"name": "alice", "shell": "/bin/bash"},
+			map[string]interface{}{"name": "bob", "shell": "/bin/sh"},
+		},
+		"ports": []interface{}{80, 443},
+	}
+	desired := map[string]interface{}{
+		"plugins": []interface{}{"docker", "kubectl"},
+		"servers": map[string]interface{}{
+			"hosts": []interface{}{"b", "d"},
+		},
+		"users": []interface{}{
+			map[string]interface{}{"name": "bob", "shell": "/bin/zsh"},
+			map[string]interface{}{"name": "carol"},
+		},
+		"ports": []interface{}{8080},
+	}
+
+	merged, err := MergeArrays(existing, desired, map[string]ArrayMerge{
+		"plugins":       {Strategy: ARRAY_APPEND_UNIQUE},
+		"servers.hosts": {Strategy: ARRAY_REMOVE},
+		"users":         {Strategy: ARRAY_MERGE_BY_KEY, Key: "name"},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []interface{}{"git", "docker", "kubectl"}, merged["plugins"])
+	assert.Equal(t, []interface{}{"a", "c"}, merged["servers"].(map[string]interface{})["hosts"])
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"name": "alice", "shell": "/bin/bash"},
+		map[string]interface{}{"name": "bob", "shell": "/bin/zsh"},
+		map[string]interface{}{"name": "carol"},
+	}, merged["users"])
+	// Lists without a strategy are replaced
+	assert.Equal(t, []interface{}{8080}, merged["ports"])
+
+	// The current state is left untouched
+	assert.Equal(t, "/bin/sh", existing["users"].([]interface{})[1].(map[string]interface{})["shell"])
+}
+
+func TestMergeArrays_AppendUniqueIgnoresNumericTypes(t *testing.T) {
+	merged, err := MergeArrays(
+		map[string]interface{}{"ids": []interface{}{int64(1), float64(2)}},
+		map[string]interface{}{"ids": []interface{}{float64(1), int64(3)}},
+		map[string]ArrayMerge{"ids": {Strategy: ARRAY_APPEND_UNIQUE}},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{int64(1), float64(2), int64(3)}, merged["ids"])
+}
+
+func TestMergeArrays_MergeByKeyRequiresMaps(t *testing.T) {
+	_, err := MergeArrays(
+		map[string]interface{}{},
+		map[string]interface{}{"users": []interface{}{"alice"}},
+		map[string]ArrayMerge{"users": {Strategy: ARRAY_MERGE_BY_KEY, Key: "name"}},
+	)
+	assert.Error(t, err)
+}
+
+func TestArrayMerge_Validate(t *testing.T) {
+	assert.NoError(t, ArrayMerge{Strategy: ARRAY_APPEND_UNIQUE}.Validate())
+	assert.NoError(t, ArrayMerge{Strategy: ARRAY_MERGE_BY_KEY, Key: "name"}.Validate())
+	assert.Error(t, ArrayMerge{Strategy: ARRAY_MERGE_BY_KEY}.Validate())
+	assert.Error(t, ArrayMerge{Strategy: "prepend"}.Validate())
+}
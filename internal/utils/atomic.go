@@ -0,0 +1,157 @@
+package utils
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// DefaultFileMode is the mode, before the umask, of files created without an explicit mode
+const DefaultFileMode os.FileMode = 0644
+
+// maxSymlinkHops bounds symlink resolution to break symlink loops
+const maxSymlinkHops = 255
+
+// WriteOptions controls how WriteFileAtomic replaces a file
+type WriteOptions struct {
+	// Mode of the written file. Zero keeps the mode of the existing file,
+	// or applies the umask to DefaultFileMode when the file is created.
+	Mode os.FileMode
+	// ReplaceSymlinks replaces a symlink at the path with a regular file.
+	// By default the write goes through the symlink to the file it points to.
+	ReplaceSymlinks bool
+}
+
+// WriteFileAtomic replaces the content of path without ever exposing a partially
+// written file: data goes to a temporary file in the same directory, which is synced
+// and renamed over path. The owner, group and extended attributes of an existing
+// file are carried over, as are its permission, setuid, setgid and sticky bits
+// unless opts.Mode is set.
+func WriteFileAtomic(path string, data []byte, opts WriteOptions) error {
+	target := path
+	if !opts.ReplaceSymlinks {
+		resolved, err := resolveSymlinks(path)
+		if err != nil {
+			return err
+		}
+		target = resolved
+	}
+
+	existing, err := os.Lstat(target)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("stat %s: %w", target, err)
+	}
+	if err == nil && existing.Mode()&os.ModeSymlink != 0 {
+		// The symlink itself is replaced, so it has no metadata worth keeping
+		existing = nil
+	}
+
+	mode := opts.Mode
+	if mode == 0 && existing != nil {
+		mode = existing.Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+	}
+
+	dir := filepath.Dir(target)
+	tmp, err := createTemp(dir, "."+filepath.Base(target)+".confedit-", DefaultFileMode)
+	if err != nil {
+		return fmt.Errorf("create temporary file in %s: %w", dir, err)
+	}
+	tmpPath := tmp.Name()
+	committed := false
+	defer func() {
+		if !committed {
+			_ = tmp.Close()
+			_ = os.Remove(tmpPath)
+		}
+	}()
+
+	if _, err := tmp.Write(data); err != nil {
+		return fmt.Errorf("write temporary file %s: %w", tmpPath, err)
+	}
+
+	// Ownership goes first: changing the owner clears setuid and setgid bits
+	if existing != nil {
+		if err := copyOwner(tmp, existing); err != nil {
+			return fmt.Errorf("preserve owner of %s: %w", target, err)
+		}
+	}
+	if mode != 0 {
+		if err := tmp.Chmod(mode); err != nil {
+			return fmt.Errorf("set mode of %s: %w", tmpPath, err)
+		}
+	}
+	if existing != nil {
+		if err := copyXattrs(target, tmpPath); err != nil {
+			return fmt.Errorf("preserve extended attributes of %s: %w", target, err)
+		}
+	}
+
+	if err := tmp.Sync(); err != nil {
+		return fmt.Errorf("sync temporary file %s: %w", tmpPath, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("close temporary file %s: %w", tmpPath, err)
+	}
+	if err := os.Rename(tmpPath, target); err != nil {
+		return fmt.Errorf("rename %s to %s: %w", tmpPath, target, err)
+	}
+	committed = true
+
+	syncDir(dir)
+	return nil
+}
+
+// createTemp creates a new file in dir like os.CreateTemp, but with the permissions
+// perm minus the umask instead of 0600
+func createTemp(dir, prefix string, perm os.FileMode) (*os.File, error) {
+	for range 10000 {
+		name := filepath.Join(dir, prefix+strconv.FormatUint(uint64(rand.Uint32()), 10))
+		f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, perm)
+		if os.IsExist(err) {
+			continue
+		}
+		return f, err
+	}
+	return nil, fmt.Errorf("no unused temporary file name in %s", dir)
+}
+
+// resolveSymlinks follows symlinks at path to the file they point to. Unlike
+// filepath.EvalSymlinks it also resolves dangling links, so that writing through
+// a link to a missing file creates that file.
+func resolveSymlinks(path string) (string, error) {
+	for range maxSymlinkHops {
+		info, err := os.Lstat(path)
+		if os.IsNotExist(err) {
+			return path, nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("stat %s: %w", path, err)
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			return path, nil
+		}
+
+		link, err := os.Readlink(path)
+		if err != nil {
+			return "", fmt.Errorf("read symlink %s: %w", path, err)
+		}
+		if !filepath.IsAbs(link) {
+			link = filepath.Join(filepath.Dir(path), link)
+		}
+		path = link
+	}
+	return "", fmt.Errorf("too many levels of symbolic links resolving %s", path)
+}
+
+// syncDir flushes the rename to disk. Errors are ignored because some filesystems
+// do not support syncing directories, and the rename already happened.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	_ = d.Sync()
+	_ = d.Close()
+}
@@ -0,0 +1,79 @@
+//go:build linux
+
+package utils
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// copyOwner gives tmp the owner and group of the existing file. Nothing is changed
+// when they already match, so unprivileged users can rewrite their own files.
+func copyOwner(tmp *os.File, existing os.FileInfo) error {
+	want, ok := existing.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	info, err := tmp.Stat()
+	if err != nil {
+		return err
+	}
+	have, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || (have.Uid == want.Uid && have.Gid == want.Gid) {
+		return nil
+	}
+	return tmp.Chown(int(want.Uid), int(want.Gid))
+}
+
+// copyXattrs copies the extended attributes of src to dst, which keeps SELinux
+// labels, ACLs and capabilities. Attributes the filesystem or the current user
+// cannot set are skipped.
+func copyXattrs(src, dst string) error {
+	size, err := syscall.Listxattr(src, nil)
+	if err != nil || size == 0 {
+		return ignoreXattrError(err)
+	}
+	buf := make([]byte, size)
+	size, err = syscall.Listxattr(src, buf)
+	if err != nil {
+		return ignoreXattrError(err)
+	}
+
+	for _, name := range strings.Split(strings.TrimRight(string(buf[:size]), "\x00"), "\x00") {
+		if name == "" {
+			continue
+		}
+		valueSize, err := syscall.Getxattr(src, name, nil)
+		if err != nil {
+			if err := ignoreXattrError(err); err != nil {
+				return err
+			}
+			continue
+		}
+		value := make([]byte, valueSize)
+		if valueSize > 0 {
+			if valueSize, err = syscall.Getxattr(src, name, value); err != nil {
+				if err := ignoreXattrError(err); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if err := syscall.Setxattr(dst, name, value[:valueSize], 0); err != nil {
+			if err := ignoreXattrError(err); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ignoreXattrError drops errors for attributes that are unsupported or not permitted
+func ignoreXattrError(err error) error {
+	if errors.Is(err, syscall.ENOTSUP) || errors.Is(err, syscall.EPERM) || errors.Is(err, syscall.EACCES) || errors.Is(err, syscall.ENODATA) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+
+package utils
+
+import "os"
+
+// copyOwner is a no-op on platforms without Linux ownership semantics
+func copyOwner(_ *os.File, _ os.FileInfo) error {
+	return nil
+}
+
+// copyXattrs is a no-op on platforms without Linux extended attributes
+func copyXattrs(_, _ string) error {
+	return nil
+}
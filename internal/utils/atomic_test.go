@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteFileAtomic_NewFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "new.conf")
+
+	require.NoError(t, WriteFileAtomic(path, []byte("a=1\n"), WriteOptions{}))
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "a=1\n", string(content))
+
+	// New files get the default mode minus the umask, like any other created file
+	reference := filepath.Join(t.TempDir(), "reference.conf")
+	require.NoError(t, os.WriteFile(reference, nil, DefaultFileMode))
+	want, err := os.Stat(reference)
+	require.NoError(t, err)
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, want.Mode().Perm(), info.Mode().Perm())
+}
+
+func TestWriteFileAtomic_PreservesMode(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "secret.conf")
+	require.NoError(t, os.WriteFile(path, []byte("old\n"), 0600))
+	require.NoError(t, os.Chmod(path, 0600))
+
+	require.NoError(t, WriteFileAtomic(path, []byte("new\n"), WriteOptions{}))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	require.NoError(t, WriteFileAtomic(path, []byte("newer\n"), WriteOptions{Mode: 0640}))
+	info, err = os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), info.Mode().Perm())
+
+	// No temporary files are left behind
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestWriteFileAtomic_PreservesSpecialBits(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "helper")
+	require.NoError(t, os.WriteFile(path, []byte("old\n"), 0755))
+	// 04755
+	require.NoError(t, os.Chmod(path, os.ModeSetuid|0755))
+
+	require.NoError(t, WriteFileAtomic(path, []byte("new\n"), WriteOptions{}))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.ModeSetuid|0755, info.Mode()&(os.ModePerm|os.ModeSetuid|os.ModeSetgid|os.ModeSticky))
+}
+
+func TestWriteFileAtomic_Symlinks(t *testing.T) {
+	dir := t.TempDir()
+	realPath := filepath.Join(dir, "real.conf")
+	link := filepath.Join(dir, "link.conf")
+	require.NoError(t, os.WriteFile(realPath, []byte("old\n"), 0644))
+	require.NoError(t, os.Symlink("real.conf", link))
+
+	// Writes go through the symlink by default
+	require.NoError(t, WriteFileAtomic(link, []byte("through\n"), WriteOptions{}))
+	content, err := os.ReadFile(realPath)
+	require.NoError(t, err)
+	assert.Equal(t, "through\n", string(content))
+	info, err := os.Lstat(link)
+	require.NoError(t, err)
+	assert.NotZero(t, info.Mode()&os.ModeSymlink)
+
+	// ReplaceSymlinks turns the link into a regular file and leaves its target alone
+	require.NoError(t, WriteFileAtomic(link, []byte("replaced\n"), WriteOptions{ReplaceSymlinks: true}))
+	info, err = os.Lstat(link)
+	require.NoError(t, err)
+	assert.True(t, info.Mode().IsRegular())
+	content, err = os.ReadFile(realPath)
+	require.NoError(t, err)
+	assert.Equal(t, "through\n", string(content))
+}
+
+func TestWriteFileAtomic_DanglingSymlink(t *testing.T) {
+	dir := t.TempDir()
+	link := filepath.Join(dir, "link.conf")
+	require.NoError(t, os.Symlink("missing.conf", link))
+
+	require.NoError(t, WriteFileAtomic(link, []byte("created\n"), WriteOptions{}))
+
+	content, err := os.ReadFile(filepath.Join(dir, "missing.conf"))
+	require.NoError(t, err)
+	assert.Equal(t, "created\n", string(content))
+}
@@ -0,0 +1,185 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// TextLines is a text file split into lines, remembering whether the text ended with a
+// newline so that unchanged files serialize back byte for byte
+type TextLines struct {
+	Lines           []string
+	TrailingNewline bool
+}
+
+// SplitLines splits text into lines without their line terminators
+func SplitLines(text string) *TextLines {
+	if text == "" {
+		return &TextLines{TrailingNewline: true}
+	}
+	lines := strings.Split(text, "\n")
+	trailing := lines[len(lines)-1] == ""
+	if trailing {
+		lines = lines[:len(lines)-1]
+	}
+	return &TextLines{Lines: lines, TrailingNewline: trailing}
+}
+
+// String joins the lines back into text
+func (t *TextLines) String() string {
+	if len(t.Lines) == 0 {
+		return ""
+	}
+	text := strings.Join(t.Lines, "\n")
+	if t.TrailingNewline {
+		text += "\n"
+	}
+	return text
+}
+
+// LineAnchor positions a new line relative to existing ones. After wins over Before;
+// a missing anchor, or an anchor that matches nothing, appends at the end.
+type LineAnchor struct {
+	After  *regexp.Regexp
+	Before *regexp.Regexp
+}
+
+// EnsureLine makes line present exactly once.
+// When match is set, the first line matching it (or equal to line) is replaced by line
+// and every other line matching it is removed, which turns "key = anything" directives
+// into the desired one. Otherwise, or when nothing matches, line is inserted at anchor.
+// Duplicates of line are always removed.
+func (t *TextLines) EnsureLine(line string, match *regexp.Regexp, anchor LineAnchor) {
+	found := false
+	result := make([]string, 0, len(t.Lines)+1)
+	for _, existing := range t.Lines {
+		if existing == line || (match != nil && match.MatchString(existing)) {
+			if !found {
+				result = append(result, line)
+				found = true
+			}
+			continue
+		}
+		result = append(result, existing)
+	}
+
+	t.Lines = result
+	if !found {
+		t.Lines = slices.Insert(t.Lines, t.insertIndex(anchor), line)
+	}
+}
+
+// SetLine makes the first line matching match equal to line. When no line matches
+// (or match is nil) and line is not present yet, line is inserted at anchor.
+// Unlike EnsureLine, other lines are never removed.
+func (t *TextLines) SetLine(line string, match *regexp.Regexp, anchor LineAnchor) {
+	if match != nil {
+		for i, existing := range t.Lines {
+			if match.MatchString(existing) {
+				t.Lines[i] = line
+				return
+			}
+		}
+	}
+	if !slices.Contains(t.Lines, line) {
+		t.Lines = slices.Insert(t.Lines, t.insertIndex(anchor), line)
+	}
+}
+
+// RemoveLines removes every line equal to line or matching match (when set)
+func (t *TextLines) RemoveLines(line string, match *regexp.Regexp) {
+	t.Lines = slices.DeleteFunc(t.Lines, func(existing string) bool {
+		return (line != "" && existing == line) || (match != nil && match.MatchString(existing))
+	})
+}
+
+// MatchingLines returns the indexes of the lines matching match
+func (t *TextLines) MatchingLines(match *regexp.Regexp) []int {
+	var indexes []int
+	for i, line := range t.Lines {
+		if match.MatchString(line) {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+// insertIndex returns where a new line goes: after the last line matching
+// anchor.After, before the first line matching anchor.Before, or at the end
+func (t *TextLines) insertIndex(anchor LineAnchor) int {
+	if anchor.After != nil {
+		for i := len(t.Lines) - 1; i >= 0; i-- {
+			if anchor.After.MatchString(t.Lines[i]) {
+				return i + 1
+			}
+		}
+	}
+	if anchor.Before != nil {
+		for i, line := range t.Lines {
+			if anchor.Before.MatchString(line) {
+				return i
+			}
+		}
+	}
+	return len(t.Lines)
+}
+
+// FindBlock returns the indexes of the begin and end marker lines of a block.
+// Marker lines are compared ignoring surrounding whitespace. Returns found false when
+// the block is absent, and an error when the begin marker is not followed by its end.
+func (t *TextLines) FindBlock(begin, end string) (int, int, bool, error) {
+	for i, line := range t.Lines {
+		if strings.TrimSpace(line) != begin {
+			continue
+		}
+		for j := i + 1; j < len(t.Lines); j++ {
+			if strings.TrimSpace(t.Lines[j]) == end {
+				return i, j, true, nil
+			}
+		}
+		return 0, 0, false, fmt.Errorf("block marker %q at line %d has no matching %q", begin, i+1, end)
+	}
+	return 0, 0, false, nil
+}
+
+// SetBlock replaces the body of the block between the begin and end markers, or
+// inserts the whole block at anchor when it does not exist yet
+func (t *TextLines) SetBlock(begin, end string, body []string, anchor LineAnchor) error {
+	block := make([]string, 0, len(body)+2)
+	block = append(block, begin)
+	block = append(block, body...)
+	block = append(block, end)
+
+	first, last, found, err := t.FindBlock(begin, end)
+	if err != nil {
+		return err
+	}
+	if found {
+		t.Lines = slices.Replace(t.Lines, first, last+1, block...)
+		return nil
+	}
+
+	t.Lines = slices.Insert(t.Lines, t.insertIndex(anchor), block...)
+	return nil
+}
+
+// RemoveBlock removes the block between the begin and end markers, markers included
+func (t *TextLines) RemoveBlock(begin, end string) error {
+	first, last, found, err := t.FindBlock(begin, end)
+	if err != nil || !found {
+		return err
+	}
+	t.Lines = slices.Delete(t.Lines, first, last+1)
+	return nil
+}
+
+// BlockBody returns the lines between the begin and end markers of a block
+func (t *TextLines) BlockBody(begin, end string) ([]string, bool, error) {
+	first, last, found, err := t.FindBlock(begin, end)
+	if err != nil || !found {
+		return nil, false, err
+	}
+	return slices.Clone(t.Lines[first+1 : last]), true, nil
+}
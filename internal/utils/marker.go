@@ -0,0 +1,26 @@
+package utils
+
+// IsDeletedMarker reports whether value is the {deleted: true} marker used in
+// desired state to request removal of a key instead of setting it
+func IsDeletedMarker(value interface{}) bool {
+	marker, ok := value.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	deleted, ok := marker["deleted"].(bool)
+	return ok && deleted
+}
+
+// RemoveDeleted removes the keys marked for deletion from data at any depth,
+// modifying data in place
+func RemoveDeleted(data map[string]interface{}) {
+	for key, value := range data {
+		if IsDeletedMarker(value) {
+			delete(data, key)
+			continue
+		}
+		if nested, ok := value.(map[string]interface{}); ok {
+			RemoveDeleted(nested)
+		}
+	}
+}
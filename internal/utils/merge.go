@@ -10,7 +10,8 @@ func DeepMerge(existing map[string]interface{}, newContent map[string]interface{
 			existingMap, existingIsMap := existingValue.(map[string]interface{})
 			newMap, newIsMap := newValue.(map[string]interface{})
 
-			if existingIsMap && newIsMap {
+			// A deletion marker replaces the existing value instead of merging into it
+			if existingIsMap && newIsMap && !IsDeletedMarker(newValue) {
 				// Both are maps, merge recursively down to all levels
 				if err := DeepMerge(existingMap, newMap); err != nil {
 					return err
@@ -26,3 +27,23 @@ func DeepMerge(existing map[string]interface{}, newContent map[string]interface{
 	}
 	return nil
 }
+
+// CopyValue returns a deep copy of the maps and lists in value
+func CopyValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			result[key] = CopyValue(item)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = CopyValue(item)
+		}
+		return result
+	default:
+		return value
+	}
+}
@@ -0,0 +1,438 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// JSON Patch (RFC 6902) operations
+const (
+	PATCH_ADD     = "add"
+	PATCH_REMOVE  = "remove"
+	PATCH_REPLACE = "replace"
+	PATCH_MOVE    = "move"
+	PATCH_COPY    = "copy"
+	PATCH_TEST    = "test"
+)
+
+// ErrPatchTestFailed is returned when a test operation does not match the document
+var ErrPatchTestFailed = errors.New("patch test failed")
+
+// errPathNotFound is wrapped by errors about a key or list index that does not exist
+var errPathNotFound = errors.New("path not found")
+
+// PatchOperation is a JSON Patch (RFC 6902) operation. Paths are JSON Pointers
+// (RFC 6901) such as "/servers/0/name".
+type PatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	From  string      `json:"from,omitempty"` // Source path of move and copy
+	Value interface{} `json:"value"`
+
+	// valueSet records a decoded "value", which may be null
+	valueSet bool
+}
+
+// hasValue reports whether the operation has a value: a non-nil Value, or a
+// decoded "value" member, null included
+func (o PatchOperation) hasValue() bool {
+	return o.valueSet || o.Value != nil
+}
+
+// UnmarshalJSON decodes an operation, telling a null value from a missing one.
+// Integers in the value are decoded as int64, like the CUE decoder does.
+func (o *PatchOperation) UnmarshalJSON(data []byte) error {
+	var fields struct {
+		Op    string          `json:"op"`
+		Path  string          `json:"path"`
+		From  string          `json:"from"`
+		Value json.RawMessage `json:"value"`
+	}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	*o = PatchOperation{Op: fields.Op, Path: fields.Path, From: fields.From, valueSet: fields.Value != nil}
+	if !o.valueSet {
+		return nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(fields.Value))
+	decoder.UseNumber()
+	if err := decoder.Decode(&o.Value); err != nil {
+		return fmt.Errorf("value: %w", err)
+	}
+	o.Value = fromJSONNumbers(o.Value)
+	return nil
+}
+
+// MarshalJSON encodes an operation, leaving out the value when it has none
+func (o PatchOperation) MarshalJSON() ([]byte, error) {
+	fields := map[string]interface{}{"op": o.Op, "path": o.Path}
+	if o.From != "" {
+		fields["from"] = o.From
+	}
+	if o.hasValue() {
+		fields["value"] = o.Value
+	}
+	return json.Marshal(fields)
+}
+
+// fromJSONNumbers replaces json.Number values with int64, or float64 when not integral
+func fromJSONNumbers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = fromJSONNumbers(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = fromJSONNumbers(item)
+		}
+	}
+	return value
+}
+
+// Validate checks that the operation is known and has the fields it needs
+func (o PatchOperation) Validate() error {
+	switch o.Op {
+	case PATCH_ADD, PATCH_REPLACE, PATCH_TEST:
+		if !o.hasValue() {
+			return fmt.Errorf("%s operation requires a value", o.Op)
+		}
+	case PATCH_REMOVE:
+	case PATCH_MOVE, PATCH_COPY:
+		if _, err := parsePointer(o.From); err != nil {
+			return fmt.Errorf("from: %w", err)
+		}
+	default:
+		return fmt.Errorf("unsupported patch operation: %s (supported: %s, %s, %s, %s, %s, %s)",
+			o.Op, PATCH_ADD, PATCH_REMOVE, PATCH_REPLACE, PATCH_MOVE, PATCH_COPY, PATCH_TEST)
+	}
+	if _, err := parsePointer(o.Path); err != nil {
+		return fmt.Errorf("path: %w", err)
+	}
+	return nil
+}
+
+// ApplyPatch applies JSON Patch operations in order to doc, which is modified in
+// place, and returns the patched document. A failing test operation aborts with
+// ErrPatchTestFailed.
+// Operations are done when the document already reflects them, so that patching the
+// patched document changes nothing: an add, replace or copy whose value is already at
+// its path, or anywhere in the list for a path ending in "-", a remove of a path that
+// does not exist and a move whose source is gone while its path exists.
+func ApplyPatch(doc map[string]interface{}, operations []PatchOperation) (map[string]interface{}, error) {
+	var root interface{} = doc
+	for i, op := range operations {
+		var err error
+		if root, err = applyOperation(root, op); err != nil {
+			return nil, fmt.Errorf("patch operation %d (%s %s): %w", i, op.Op, op.Path, err)
+		}
+	}
+
+	result, ok := root.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("patched document is not a map")
+	}
+	return result, nil
+}
+
+func applyOperation(root interface{}, op PatchOperation) (interface{}, error) {
+	path, err := parsePointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.Op {
+	case PATCH_ADD:
+		if holdsValue(root, path, op.Value) {
+			return root, nil
+		}
+		return setValue(root, path, CopyValue(op.Value), true)
+
+	case PATCH_REMOVE:
+		if _, err := getValue(root, path); errors.Is(err, errPathNotFound) {
+			return root, nil
+		}
+		root, _, err = removeValue(root, path)
+		return root, err
+
+	case PATCH_REPLACE:
+		if _, err := getValue(root, path); err != nil {
+			return nil, err
+		}
+		if holdsValue(root, path, op.Value) {
+			return root, nil
+		}
+		return setValue(root, path, CopyValue(op.Value), false)
+
+	case PATCH_MOVE, PATCH_COPY:
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, fmt.Errorf("from: %w", err)
+		}
+		value, err := getValue(root, from)
+		if op.Op == PATCH_MOVE && errors.Is(err, errPathNotFound) && targetExists(root, path) {
+			return root, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("from: %w", err)
+		}
+		if op.Op == PATCH_COPY {
+			if holdsValue(root, path, value) {
+				return root, nil
+			}
+			return setValue(root, path, CopyValue(value), true)
+		}
+		if len(path) > len(from) && isPointerPrefix(from, path) {
+			return nil, fmt.Errorf("cannot move %s into itself", op.From)
+		}
+		if root, _, err = removeValue(root, from); err != nil {
+			return nil, fmt.Errorf("from: %w", err)
+		}
+		return setValue(root, path, value, true)
+
+	case PATCH_TEST:
+		value, err := getValue(root, path)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrPatchTestFailed, err)
+		}
+		if !EqualValues(value, op.Value) {
+			return nil, fmt.Errorf("%w: value is %v, expected %v", ErrPatchTestFailed, value, op.Value)
+		}
+		return root, nil
+
+	default:
+		return nil, op.Validate()
+	}
+}
+
+// ApplyMergePatch applies a JSON Merge Patch (RFC 7386) to doc, which is modified
+// in place: null values remove keys, maps are merged recursively and other values
+// replace the existing ones
+func ApplyMergePatch(doc, patch map[string]interface{}) map[string]interface{} {
+	for key, value := range patch {
+		if value == nil {
+			delete(doc, key)
+			continue
+		}
+		patchMap, ok := value.(map[string]interface{})
+		if !ok {
+			doc[key] = CopyValue(value)
+			continue
+		}
+		target, ok := doc[key].(map[string]interface{})
+		if !ok {
+			target = make(map[string]interface{})
+		}
+		doc[key] = ApplyMergePatch(target, patchMap)
+	}
+	return doc
+}
+
+// pointerUnescaper decodes ~1 and ~0 in JSON Pointer tokens, in that order
+var pointerUnescaper = strings.NewReplacer("~1", "/", "~0", "~")
+
+// parsePointer splits a JSON Pointer into its unescaped reference tokens
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid JSON pointer %q: must start with /", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = pointerUnescaper.Replace(token)
+	}
+	return tokens, nil
+}
+
+// isPointerPrefix reports whether prefix is a parent of or equal to path
+func isPointerPrefix(prefix, path []string) bool {
+	if len(prefix) > len(path) {
+		return false
+	}
+	for i := range prefix {
+		if prefix[i] != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// holdsValue reports whether the value at path equals value. For a path ending in
+// "-", it reports whether any item of the list does.
+func holdsValue(root interface{}, path []string, value interface{}) bool {
+	if len(path) == 0 || path[len(path)-1] != "-" {
+		current, err := getValue(root, path)
+		return err == nil && EqualValues(current, value)
+	}
+	parent, err := getValue(root, path[:len(path)-1])
+	list, ok := parent.([]interface{})
+	if err != nil || !ok {
+		return false
+	}
+	return slices.ContainsFunc(list, func(item interface{}) bool {
+		return EqualValues(item, value)
+	})
+}
+
+// targetExists reports whether there is a value at path. A path ending in "-"
+// exists when it addresses a list that is not empty.
+func targetExists(root interface{}, path []string) bool {
+	if len(path) == 0 || path[len(path)-1] != "-" {
+		_, err := getValue(root, path)
+		return err == nil
+	}
+	parent, err := getValue(root, path[:len(path)-1])
+	list, ok := parent.([]interface{})
+	return err == nil && ok && len(list) > 0
+}
+
+// getValue returns the value at path
+func getValue(node interface{}, path []string) (interface{}, error) {
+	for i, token := range path {
+		switch n := node.(type) {
+		case map[string]interface{}:
+			value, ok := n[token]
+			if !ok {
+				return nil, fmt.Errorf("%w: /%s", errPathNotFound, strings.Join(path[:i+1], "/"))
+			}
+			node = value
+		case []interface{}:
+			index, err := arrayIndex(token, len(n), false)
+			if err != nil {
+				return nil, err
+			}
+			node = n[index]
+		default:
+			return nil, fmt.Errorf("path /%s is not a map or list", strings.Join(path[:i], "/"))
+		}
+	}
+	return node, nil
+}
+
+// setValue sets the value at path and returns the updated node. With insert, new map
+// keys are added and list items are inserted; otherwise the target must exist.
+func setValue(node interface{}, path []string, value interface{}, insert bool) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	token := path[0]
+
+	switch n := node.(type) {
+	case map[string]interface{}:
+		child, ok := n[token]
+		if !ok && (len(path) > 1 || !insert) {
+			return nil, fmt.Errorf("key %s does not exist", token)
+		}
+		updated, err := setValue(child, path[1:], value, insert)
+		if err != nil {
+			return nil, err
+		}
+		n[token] = updated
+		return n, nil
+
+	case []interface{}:
+		if len(path) == 1 && insert {
+			index, err := arrayIndex(token, len(n), true)
+			if err != nil {
+				return nil, err
+			}
+			result := make([]interface{}, 0, len(n)+1)
+			result = append(result, n[:index]...)
+			result = append(result, value)
+			return append(result, n[index:]...), nil
+		}
+		index, err := arrayIndex(token, len(n), false)
+		if err != nil {
+			return nil, err
+		}
+		updated, err := setValue(n[index], path[1:], value, insert)
+		if err != nil {
+			return nil, err
+		}
+		n[index] = updated
+		return n, nil
+
+	default:
+		return nil, fmt.Errorf("cannot set %s in a value that is not a map or list", token)
+	}
+}
+
+// removeValue removes the value at path and returns the updated node and the removed value
+func removeValue(node interface{}, path []string) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, nil, fmt.Errorf("cannot remove the whole document")
+	}
+	token := path[0]
+
+	switch n := node.(type) {
+	case map[string]interface{}:
+		child, ok := n[token]
+		if !ok {
+			return nil, nil, fmt.Errorf("key %s does not exist", token)
+		}
+		if len(path) == 1 {
+			delete(n, token)
+			return n, child, nil
+		}
+		updated, removed, err := removeValue(child, path[1:])
+		if err != nil {
+			return nil, nil, err
+		}
+		n[token] = updated
+		return n, removed, nil
+
+	case []interface{}:
+		index, err := arrayIndex(token, len(n), false)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(path) == 1 {
+			result := append(append([]interface{}{}, n[:index]...), n[index+1:]...)
+			return result, n[index], nil
+		}
+		updated, removed, err := removeValue(n[index], path[1:])
+		if err != nil {
+			return nil, nil, err
+		}
+		n[index] = updated
+		return n, removed, nil
+
+	default:
+		return nil, nil, fmt.Errorf("cannot remove %s from a value that is not a map or list", token)
+	}
+}
+
+// arrayIndex parses a list index token. For insertions, "-" and the list length
+// address the end of the list.
+func arrayIndex(token string, length int, insert bool) (int, error) {
+	if token == "-" && insert {
+		return length, nil
+	}
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 || (token != "0" && strings.HasPrefix(token, "0")) {
+		return 0, fmt.Errorf("invalid list index %s", token)
+	}
+	limit := length - 1
+	if insert {
+		limit = length
+	}
+	if index > limit {
+		return 0, fmt.Errorf("%w: list index %d out of range", errPathNotFound, index)
+	}
+	return index, nil
+}
@@ -0,0 +1,150 @@
+package utils
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func patchDocument() map[string]interface{} {
+	return map[string]interface{}{
+		"server": map[string]interface{}{
+			"host":  "localhost",
+			"ports": []interface{}{80, 443},
+		},
+		"a/b":    "slash",
+		"legacy": map[string]interface{}{"timeout": 30},
+	}
+}
+
+func TestApplyPatch_Operations(t *testing.T) {
+	patched, err := ApplyPatch(patchDocument(), []PatchOperation{
+		{Op: PATCH_TEST, Path: "/server/host", Value: "localhost"},
+		{Op: PATCH_ADD, Path: "/server/ports/1", Value: 8080},
+		{Op: PATCH_ADD, Path: "/server/ports/-", Value: 9090},
+		{Op: PATCH_REPLACE, Path: "/server/host", Value: "0.0.0.0"},
+		{Op: PATCH_MOVE, From: "/legacy/timeout", Path: "/server/timeout"},
+		{Op: PATCH_REMOVE, Path: "/legacy"},
+		{Op: PATCH_COPY, From: "/a~1b", Path: "/copied"},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]interface{}{
+		"server": map[string]interface{}{
+			"host":    "0.0.0.0",
+			"ports":   []interface{}{80, 8080, 443, 9090},
+			"timeout": 30,
+		},
+		"a/b":    "slash",
+		"copied": "slash",
+	}, patched)
+}
+
+func TestApplyPatch_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		op   PatchOperation
+	}{
+		{"replace missing key", PatchOperation{Op: PATCH_REPLACE, Path: "/missing", Value: 1}},
+		{"remove below scalar", PatchOperation{Op: PATCH_REMOVE, Path: "/server/host/missing"}},
+		{"move missing key", PatchOperation{Op: PATCH_MOVE, From: "/missing", Path: "/moved"}},
+		{"add below missing key", PatchOperation{Op: PATCH_ADD, Path: "/missing/key", Value: 1}},
+		{"index out of range", PatchOperation{Op: PATCH_ADD, Path: "/server/ports/3", Value: 1}},
+		{"invalid index", PatchOperation{Op: PATCH_REMOVE, Path: "/server/ports/01"}},
+		{"move into itself", PatchOperation{Op: PATCH_MOVE, From: "/server", Path: "/server/nested"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(tb *testing.T) {
+			_, err := ApplyPatch(patchDocument(), []PatchOperation{tt.op})
+			assert.Error(tb, err)
+			assert.NotErrorIs(tb, err, ErrPatchTestFailed)
+		})
+	}
+}
+
+func TestApplyPatch_Converges(t *testing.T) {
+	operations := []PatchOperation{
+		{Op: PATCH_ADD, Path: "/server/ports/1", Value: 8080},
+		{Op: PATCH_ADD, Path: "/server/ports/-", Value: 9090},
+		{Op: PATCH_REPLACE, Path: "/server/host", Value: "0.0.0.0"},
+		{Op: PATCH_MOVE, From: "/legacy/timeout", Path: "/server/timeout"},
+		{Op: PATCH_REMOVE, Path: "/legacy"},
+		{Op: PATCH_REMOVE, Path: "/server/missing"},
+		{Op: PATCH_COPY, From: "/a~1b", Path: "/server/ports/-"},
+		{Op: PATCH_ADD, Path: "/server/ports/-", Value: 80},
+	}
+	patched, err := ApplyPatch(patchDocument(), operations)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{80, 8080, 443, 9090, "slash"}, patched["server"].(map[string]interface{})["ports"])
+
+	// Patching the patched document changes nothing
+	repatched, err := ApplyPatch(CopyValue(patched).(map[string]interface{}), operations)
+	require.NoError(t, err)
+	assert.Equal(t, patched, repatched)
+}
+
+func TestApplyPatch_TestFailure(t *testing.T) {
+	_, err := ApplyPatch(patchDocument(), []PatchOperation{
+		{Op: PATCH_TEST, Path: "/server/ports", Value: []interface{}{80}},
+	})
+	assert.ErrorIs(t, err, ErrPatchTestFailed)
+
+	_, err = ApplyPatch(patchDocument(), []PatchOperation{
+		{Op: PATCH_TEST, Path: "/server/missing", Value: "x"},
+	})
+	assert.ErrorIs(t, err, ErrPatchTestFailed)
+}
+
+func TestApplyMergePatch(t *testing.T) {
+	patched := ApplyMergePatch(patchDocument(), map[string]interface{}{
+		"server": map[string]interface{}{
+			"host":  nil,
+			"ports": []interface{}{8443},
+		},
+		"legacy": nil,
+		"a/b":    map[string]interface{}{"nested": true},
+	})
+
+	assert.Equal(t, map[string]interface{}{
+		"server": map[string]interface{}{"ports": []interface{}{8443}},
+		"a/b":    map[string]interface{}{"nested": true},
+	}, patched)
+}
+
+func TestPatchOperation_Validate(t *testing.T) {
+	assert.NoError(t, PatchOperation{Op: PATCH_ADD, Path: "/a", Value: 1}.Validate())
+	assert.NoError(t, PatchOperation{Op: PATCH_MOVE, From: "/a", Path: "/b"}.Validate())
+	assert.Error(t, PatchOperation{Op: PATCH_MOVE, From: "a", Path: "/b"}.Validate())
+	assert.Error(t, PatchOperation{Op: PATCH_ADD, Path: "a"}.Validate())
+	assert.Error(t, PatchOperation{Op: "merge", Path: "/a"}.Validate())
+
+	// add, replace and test need a value, which may be an explicit null
+	for _, op := range []string{PATCH_ADD, PATCH_REPLACE, PATCH_TEST} {
+		assert.Error(t, PatchOperation{Op: op, Path: "/a"}.Validate(), op)
+	}
+	assert.NoError(t, PatchOperation{Op: PATCH_REMOVE, Path: "/a"}.Validate())
+}
+
+func TestPatchOperation_JSON(t *testing.T) {
+	var operations []PatchOperation
+	require.NoError(t, json.Unmarshal([]byte(`[
+		{"op": "add", "path": "/ports", "value": [80, 1.5, {"n": 2}]},
+		{"op": "test", "path": "/proxy", "value": null},
+		{"op": "add", "path": "/missing"}
+	]`), &operations))
+
+	assert.Equal(t, []interface{}{int64(80), 1.5, map[string]interface{}{"n": int64(2)}}, operations[0].Value)
+	assert.NoError(t, operations[1].Validate())
+	assert.Error(t, operations[2].Validate())
+
+	data, err := json.Marshal(operations)
+	require.NoError(t, err)
+	assert.JSONEq(t, `[
+		{"op": "add", "path": "/ports", "value": [80, 1.5, {"n": 2}]},
+		{"op": "test", "path": "/proxy", "value": null},
+		{"op": "add", "path": "/missing"}
+	]`, string(data))
+}
@@ -0,0 +1,30 @@
+package config
+
+// Example configuration demonstrating managed block targets
+// Each block is kept between "# BEGIN confedit <name>" and "# END confedit <name>" markers
+
+targets: [
+	{
+		name: "sed-example-overrides"
+		type: "block"
+		config: {
+			path: "./testdata/sed-example.conf"
+			// Place the block right after the first matching line, or at the end of the file
+			insert_after: "^port="
+			content: """
+				# Settings owned by confedit
+				timeout=30
+				retries=3
+				"""
+		}
+	},
+	{
+		name: "sed-example-legacy"
+		type: "block"
+		config: {
+			path: "./testdata/sed-example.conf"
+			// Remove a block that an earlier configuration added
+			state: "absent"
+		}
+	},
+]
@@ -0,0 +1,27 @@
+package config
+
+// Example configuration demonstrating line targets
+// Each target keeps a single line present or absent, leaving the rest of the file alone
+
+targets: [
+	{
+		name: "sed-example-timeout"
+		type: "line"
+		config: {
+			path: "./testdata/sed-example.conf"
+			line: "timeout = 30"
+			// Replace an existing timeout, or add it right after the debug flag
+			match:        "^timeout\\s*="
+			insert_after: "^debug\\s*="
+		}
+	},
+	{
+		name: "sed-example-debug-comment"
+		type: "line"
+		config: {
+			path:  "./testdata/sed-example.conf"
+			state: "absent"
+			match: "^# DEBUG"
+		}
+	},
+]